@@ -46,6 +46,11 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 			},
 			SealWrapStorage: []string{
 				PathConfigStorage,
+				PathIssuedTokenStorage,
+				PathRevocationStorage,
+			},
+			Unauthenticated: []string{
+				fmt.Sprintf("%s/*", PathWebhook),
 			},
 		},
 
@@ -58,13 +63,27 @@ func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend,
 				pathConfig(b),
 				pathListConfig(b),
 				pathConfigTokenRotate(b),
+				pathConfigReconcile(b),
+				pathConfigBootstrap(b),
+				pathRevocationsPending(b),
 				pathListRoles(b),
 				pathRoles(b),
+				pathRolePreview(b),
+				pathRoleTest(b),
+				pathRoleRollback(b),
+				pathListRoleHistory(b),
+				pathRoleHistory(b),
+				pathListRoleTemplates(b),
+				pathRoleTemplates(b),
+				pathExport(b),
+				pathImport(b),
 				pathTokenRoles(b),
+				pathWebhook(b),
 			},
 		),
 
-		PeriodicFunc: b.periodicFunc,
+		PeriodicFunc:   b.periodicFunc,
+		InitializeFunc: b.initialize,
 	}
 
 	var err = b.Setup(ctx, conf)
@@ -83,6 +102,15 @@ type Backend struct {
 
 	// roleLocks to protect access for roles, during modifications, deletion
 	roleLocks []*locksutil.LockEntry
+
+	// reconciledAt holds the last time the issued tokens of a config were reconciled
+	reconciledAt sync.Map
+
+	// roleTestedAt holds the last time a role was smoke tested by the periodic function
+	roleTestedAt sync.Map
+
+	// breakers holds the circuit breaker of every config
+	breakers sync.Map
 }
 
 func (b *Backend) periodicFunc(ctx context.Context, req *logical.Request) (err error) {
@@ -99,22 +127,81 @@ func (b *Backend) periodicFunc(ctx context.Context, req *logical.Request) (err e
 		configs, err = req.Storage.List(ctx, fmt.Sprintf("%s/", PathConfigStorage))
 
 		for _, name := range configs {
-			if config, err = getConfig(ctx, req.Storage, name); err == nil {
+			// the errors of the previous configs are kept in err
+			var er error
+			if config, er = getConfig(ctx, req.Storage, name); er != nil {
+				err = errors.Join(err, er)
+			} else {
 				b.Logger().Debug("Trying to rotate the config", "name", name)
 				unlockLockClientMutex()
 				if config != nil {
-					// If we need to autorotate the token, initiate the procedure to autorotate the token
-					if config.AutoRotateToken {
+					// Check if gitlab is available again when the circuit breaker is open
+					err = errors.Join(err, b.probeCircuitBreaker(ctx, req, config))
+
+					// If we need to autorotate the token or finish an interrupted rotation, initiate the procedure to autorotate the token
+					if config.rotationScheduled() || config.PendingToken != nil {
 						err = errors.Join(err, b.checkAndRotateConfigToken(ctx, req, config))
 					}
+
+					// The credentials are rotated with the same settings as the config token
+					if (config.rotationScheduled() || config.credentialsPending()) && len(config.Credentials) > 0 {
+						err = errors.Join(err, b.checkAndRotateCredentials(ctx, req, name))
+					}
+
+					// Retry the revocations that failed before
+					err = errors.Join(err, b.retryRevocations(ctx, req, name))
+
+					// Check the issued tokens against gitlab if it's time to do so
+					if config.ReconcileInterval > 0 {
+						err = errors.Join(err, b.periodicReconcile(ctx, req, config))
+					}
 				}
 			}
 		}
+
+		// the lock is still held when there are no configs or none could be read, issuing the test tokens takes it again
+		unlockLockClientMutex()
+
+		// Smoke test the roles that have a test interval
+		err = errors.Join(err, b.periodicRoleTests(ctx, req))
 	}
 
 	return err
 }
 
+// initialize finishes any config token or credential rotation that was interrupted before the plugin stopped
+func (b *Backend) initialize(ctx context.Context, req *logical.InitializationRequest) (err error) {
+	if !b.WriteSafeReplicationState() {
+		return nil
+	}
+
+	var configs []string
+	if configs, err = req.Storage.List(ctx, fmt.Sprintf("%s/", PathConfigStorage)); err != nil {
+		return err
+	}
+
+	for _, name := range configs {
+		var config, e = getConfig(ctx, req.Storage, name)
+		if e != nil || config == nil {
+			continue
+		}
+		if config.PendingToken != nil {
+			b.Logger().Warn("Recovering an interrupted config token rotation", "name", name)
+			if e := b.checkAndRotateConfigToken(ctx, &logical.Request{Storage: req.Storage}, config); e != nil {
+				b.Logger().Error("Failed to recover the config token rotation", "name", name, "error", e)
+			}
+		}
+		if config.credentialsPending() {
+			b.Logger().Warn("Recovering an interrupted credential rotation", "name", name)
+			if e := b.checkAndRotateCredentials(ctx, &logical.Request{Storage: req.Storage}, name); e != nil {
+				b.Logger().Error("Failed to recover the credential rotation", "name", name, "error", e)
+			}
+		}
+	}
+
+	return nil
+}
+
 // Invalidate invalidates the key if required
 func (b *Backend) Invalidate(ctx context.Context, key string) {
 	b.Logger().Debug("Backend invalidate", "key", key)
@@ -125,6 +212,10 @@ func (b *Backend) Invalidate(ctx context.Context, key string) {
 		b.lockClientMutex.Lock()
 		defer b.lockClientMutex.Unlock()
 		b.clients.Delete(name)
+		b.breakers.Delete(name)
+		for _, capability := range validCapabilities {
+			b.clients.Delete(credentialClientName(name, capability))
+		}
 	}
 }
 
@@ -172,3 +263,111 @@ func (b *Backend) getClient(ctx context.Context, s logical.Storage, name string)
 	}
 	return client, err
 }
+
+// getClientFor returns the client of the first credential of the config that exists for the capabilities,
+// so every operation uses the narrowest credential available. Without any it falls back to the config token.
+func (b *Backend) getClientFor(ctx context.Context, s logical.Storage, name string, capabilities ...Capability) (client Client, err error) {
+	var config *EntryConfig
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, s, name)
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		b.Logger().Error("Failed to retrieve configuration", "error", err.Error())
+		return nil, err
+	}
+	if config == nil {
+		return b.getClient(ctx, s, name)
+	}
+	return b.clientForConfig(ctx, config, capabilities...)
+}
+
+// clientForConfig returns the client for the capabilities of a config that was already loaded, like getClientFor.
+// It doesn't take lockClientMutex, so it can be used by callers that hold it.
+func (b *Backend) clientForConfig(ctx context.Context, config *EntryConfig, capabilities ...Capability) (client Client, err error) {
+	for _, capability := range capabilities {
+		var credConfig *EntryConfig
+		if credConfig = config.credentialConfig(capability); credConfig == nil {
+			continue
+		}
+
+		if c, ok := b.clients.Load(credConfig.Name); ok && clientCacheFromContext(ctx) {
+			client = c.(Client)
+		}
+		if client != nil && client.Valid(ctx) {
+			b.Logger().Debug("Returning existing gitlab client", "capability", capability)
+			return b.guardClient(config, client), nil
+		}
+
+		var httpClient *http.Client
+		httpClient, _ = HttpClientFromContext(ctx)
+		if client, _ = GitlabClientFromContext(ctx); client == nil {
+			if client, err = NewGitlabClient(credConfig, httpClient, b.Logger()); err == nil && clientCacheFromContext(ctx) {
+				b.SetClient(client, credConfig.Name)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return b.guardClient(config, client), nil
+	}
+
+	var name = cmp.Or(config.Name, DefaultConfigName)
+	if c, ok := b.clients.Load(name); ok && clientCacheFromContext(ctx) {
+		client = c.(Client)
+	}
+	if client == nil || !client.Valid(ctx) {
+		var httpClient *http.Client
+		httpClient, _ = HttpClientFromContext(ctx)
+		if client, _ = GitlabClientFromContext(ctx); client == nil {
+			if client, err = NewGitlabClient(config, httpClient, b.Logger()); err != nil {
+				return nil, err
+			}
+			if clientCacheFromContext(ctx) {
+				b.SetClient(client, name)
+			}
+		}
+	}
+	return b.guardClient(config, client), nil
+}
+
+// circuitBreaker returns the circuit breaker of the config, the threshold is only updated if it's set
+func (b *Backend) circuitBreaker(name string, threshold int) *circuitBreaker {
+	var breaker, _ = b.breakers.LoadOrStore(name, newCircuitBreaker(cmp.Or(threshold, DefaultCircuitBreakerThreshold)))
+	if threshold > 0 {
+		breaker.(*circuitBreaker).setThreshold(threshold)
+	}
+	return breaker.(*circuitBreaker)
+}
+
+// guardClient wraps the client with the circuit breaker of the config
+func (b *Backend) guardClient(config *EntryConfig, client Client) Client {
+	var name = config.Name
+	var breaker = b.circuitBreaker(name, config.CircuitBreakerThreshold)
+	return &circuitBreakerClient{
+		client:  client,
+		breaker: breaker,
+		onChange: func(ctx context.Context, state CircuitBreakerState) {
+			b.Logger().Warn("Circuit breaker changed state", "config_name", name, "state", state)
+			var metadata = breaker.metadata()
+			metadata["path"] = fmt.Sprintf("%s/%s", PathConfigStorage, name)
+			metadata["config_name"] = name
+			event(ctx, b.Backend, fmt.Sprintf("config-circuit-breaker-%s", state), metadata)
+		},
+	}
+}
+
+// probeCircuitBreaker lets a single request through an open circuit breaker to check if GitLab is available again
+func (b *Backend) probeCircuitBreaker(ctx context.Context, req *logical.Request, config *EntryConfig) (err error) {
+	var breaker, ok = b.breakers.Load(config.Name)
+	if !ok || !breaker.(*circuitBreaker).probe() {
+		return nil
+	}
+
+	b.Logger().Info("Probing gitlab with a half-open circuit breaker", "config_name", config.Name)
+	var client Client
+	if client, err = b.getClientFor(ctx, req.Storage, config.Name); err != nil {
+		return err
+	}
+	_, err = client.CurrentTokenInfo(ctx)
+	return err
+}
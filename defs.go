@@ -13,6 +13,10 @@ var (
 	ErrFieldRequired        = errors.New("required field")
 	ErrFieldInvalidValue    = errors.New("invalid value for field")
 	ErrBackendNotConfigured = errors.New("backend not configured")
+	ErrRoleNotAllowed       = errors.New("role not allowed by the config")
+	ErrRoleNotAvailable     = errors.New("role not available")
+	ErrRoleTemplateNotFound = errors.New("role template not found")
+	ErrRoleTemplateInUse    = errors.New("role template in use")
 )
 
 type contextKey string
@@ -22,12 +26,24 @@ const (
 	DefaultConfigFieldAccessTokenRotate = DefaultAutoRotateBeforeMinTTL
 	DefaultRoleFieldAccessTokenMaxTTL   = 24 * time.Hour
 	DefaultAccessTokenMinTTL            = 24 * time.Hour
+	DefaultVaultRevokedMinTTL           = time.Hour
+	DefaultVaultRevokedMinTTLFloor      = time.Minute
 	DefaultAccessTokenMaxPossibleTTL    = 365 * 24 * time.Hour
 	DefaultAutoRotateBeforeMinTTL       = 24 * time.Hour
 	DefaultAutoRotateBeforeMaxTTL       = 730 * time.Hour
+	DefaultRotationBackoffMin           = time.Minute
+	DefaultRotationBackoffMax           = 4 * time.Hour
+	DefaultRevocationBackoffMin         = time.Minute
+	DefaultRevocationBackoffMax         = time.Hour
+	DefaultRevocationMaxAttempts        = 48
+	DefaultCircuitBreakerThreshold      = 5
+	DefaultRoleTestMinInterval          = 15 * time.Minute
+	DefaultTokenNameMaxLength           = 255
+	DefaultRoleHistoryRetention         = 10
 	ctxKeyHttpClient                    = contextKey("vpsg-ctx-key-http-client")
 	ctxKeyGitlabClient                  = contextKey("vpsg-ctx-key-gitlab-client")
 	ctxKeyTimeNow                       = contextKey("vpsg-ctx-key-time-now")
+	ctxKeyNoClientCache                 = contextKey("vpsg-ctx-key-no-client-cache")
 	DefaultConfigName                   = "default"
 )
 
@@ -66,3 +82,14 @@ func GitlabClientFromContext(ctx context.Context) (Client, bool) {
 	}
 	return u, ok
 }
+
+// withoutClientCache marks the context so the clients are neither taken from nor stored in the cache of the backend,
+// used when the configs don't come from the storage of the backend
+func withoutClientCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, ctxKeyNoClientCache, true)
+}
+
+func clientCacheFromContext(ctx context.Context) bool {
+	noCache, _ := ctx.Value(ctxKeyNoClientCache).(bool)
+	return !noCache
+}
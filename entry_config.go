@@ -1,9 +1,11 @@
 package gitlab
 
 import (
+	"cmp"
 	"context"
 	"crypto/sha1"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -11,19 +13,155 @@ import (
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/robfig/cron/v3"
+	"github.com/ryanuber/go-glob"
 )
 
 type EntryConfig struct {
-	TokenId          int           `json:"token_id" yaml:"token_id" mapstructure:"token_id"`
-	BaseURL          string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
-	Token            string        `json:"token" structs:"token" mapstructure:"token"`
-	AutoRotateToken  bool          `json:"auto_rotate_token" structs:"auto_rotate_token" mapstructure:"auto_rotate_token"`
-	AutoRotateBefore time.Duration `json:"auto_rotate_before" structs:"auto_rotate_before" mapstructure:"auto_rotate_before"`
-	TokenCreatedAt   time.Time     `json:"token_created_at" structs:"token_created_at" mapstructure:"token_created_at"`
-	TokenExpiresAt   time.Time     `json:"token_expires_at" structs:"token_expires_at" mapstructure:"token_expires_at"`
-	Scopes           []string      `json:"scopes" structs:"scopes" mapstructure:"scopes"`
-	Type             Type          `json:"type" structs:"type" mapstructure:"type"`
-	Name             string        `json:"name" structs:"name" mapstructure:"name"`
+	TokenId               int           `json:"token_id" yaml:"token_id" mapstructure:"token_id"`
+	BaseURL               string        `json:"base_url" structs:"base_url" mapstructure:"base_url"`
+	Token                 string        `json:"token" structs:"token" mapstructure:"token"`
+	AutoRotateToken       bool          `json:"auto_rotate_token" structs:"auto_rotate_token" mapstructure:"auto_rotate_token"`
+	AutoRotateBefore      time.Duration `json:"auto_rotate_before" structs:"auto_rotate_before" mapstructure:"auto_rotate_before"`
+	TokenCreatedAt        time.Time     `json:"token_created_at" structs:"token_created_at" mapstructure:"token_created_at"`
+	TokenExpiresAt        time.Time     `json:"token_expires_at" structs:"token_expires_at" mapstructure:"token_expires_at"`
+	Scopes                []string      `json:"scopes" structs:"scopes" mapstructure:"scopes"`
+	Type                  Type          `json:"type" structs:"type" mapstructure:"type"`
+	Name                  string        `json:"name" structs:"name" mapstructure:"name"`
+	WebhookSecret         string        `json:"webhook_secret" structs:"webhook_secret" mapstructure:"webhook_secret"`
+	ReconcileInterval     time.Duration `json:"reconcile_interval" structs:"reconcile_interval" mapstructure:"reconcile_interval"`
+	ReconcileResolveStale bool          `json:"reconcile_resolve_stale" structs:"reconcile_resolve_stale" mapstructure:"reconcile_resolve_stale"`
+	LastRotationAttempt   time.Time     `json:"last_rotation_attempt" structs:"last_rotation_attempt" mapstructure:"last_rotation_attempt"`
+	LastRotationError     string        `json:"last_rotation_error" structs:"last_rotation_error" mapstructure:"last_rotation_error"`
+	ConsecutiveFailures   int           `json:"consecutive_failures" structs:"consecutive_failures" mapstructure:"consecutive_failures"`
+	NextRotationAttempt   time.Time     `json:"next_rotation_attempt" structs:"next_rotation_attempt" mapstructure:"next_rotation_attempt"`
+	PendingToken          *EntryToken   `json:"pending_token,omitempty" structs:"pending_token" mapstructure:"pending_token"`
+	RotationSchedule      string        `json:"rotation_schedule" structs:"rotation_schedule" mapstructure:"rotation_schedule"`
+	RotationWindow        time.Duration `json:"rotation_window" structs:"rotation_window" mapstructure:"rotation_window"`
+	RotationPeriod        time.Duration `json:"rotation_period" structs:"rotation_period" mapstructure:"rotation_period"`
+	TokenKind             TokenType     `json:"token_kind" structs:"token_kind" mapstructure:"token_kind"`
+	TokenParentId         string        `json:"token_parent_id" structs:"token_parent_id" mapstructure:"token_parent_id"`
+
+	Credentials map[Capability]*EntryCredential `json:"credentials,omitempty" structs:"credentials" mapstructure:"credentials"`
+
+	AllowedTokenTypes   []string      `json:"allowed_token_types" structs:"allowed_token_types" mapstructure:"allowed_token_types"`
+	MaxAccessLevel      AccessLevel   `json:"max_access_level" structs:"max_access_level" mapstructure:"max_access_level"`
+	AllowedScopes       []string      `json:"allowed_scopes" structs:"allowed_scopes" mapstructure:"allowed_scopes"`
+	DeniedScopes        []string      `json:"denied_scopes" structs:"denied_scopes" mapstructure:"denied_scopes"`
+	MaxTTL              time.Duration `json:"max_ttl" structs:"max_ttl" mapstructure:"max_ttl"`
+	AllowedPathPatterns []string      `json:"allowed_path_patterns" structs:"allowed_path_patterns" mapstructure:"allowed_path_patterns"`
+
+	VaultRevokedMinTTL time.Duration `json:"vault_revoked_min_ttl" structs:"vault_revoked_min_ttl" mapstructure:"vault_revoked_min_ttl"`
+
+	CircuitBreakerThreshold int `json:"circuit_breaker_threshold" structs:"circuit_breaker_threshold" mapstructure:"circuit_breaker_threshold"`
+
+	ValidateRoles bool `json:"validate_roles" structs:"validate_roles" mapstructure:"validate_roles"`
+
+	RoleHistoryRetention int `json:"role_history_retention" structs:"role_history_retention" mapstructure:"role_history_retention"`
+}
+
+// EntryCredential is an optional credential of the config that is used instead of the config token
+// for the operations of its capability.
+type EntryCredential struct {
+	Token               string    `json:"token" structs:"token" mapstructure:"token"`
+	TokenId             int       `json:"token_id" structs:"token_id" mapstructure:"token_id"`
+	TokenCreatedAt      time.Time `json:"token_created_at" structs:"token_created_at" mapstructure:"token_created_at"`
+	TokenExpiresAt      time.Time `json:"token_expires_at" structs:"token_expires_at" mapstructure:"token_expires_at"`
+	Scopes              []string  `json:"scopes" structs:"scopes" mapstructure:"scopes"`
+	TokenKind           TokenType `json:"token_kind" structs:"token_kind" mapstructure:"token_kind"`
+	TokenParentId       string    `json:"token_parent_id" structs:"token_parent_id" mapstructure:"token_parent_id"`
+	LastRotationAttempt time.Time `json:"last_rotation_attempt" structs:"last_rotation_attempt" mapstructure:"last_rotation_attempt"`
+	LastRotationError   string    `json:"last_rotation_error" structs:"last_rotation_error" mapstructure:"last_rotation_error"`
+	ConsecutiveFailures int       `json:"consecutive_failures" structs:"consecutive_failures" mapstructure:"consecutive_failures"`
+	NextRotationAttempt time.Time `json:"next_rotation_attempt" structs:"next_rotation_attempt" mapstructure:"next_rotation_attempt"`
+	// PendingToken is the rotated token that hasn't been verified and promoted yet
+	PendingToken *EntryToken `json:"pending_token,omitempty" structs:"pending_token" mapstructure:"pending_token"`
+}
+
+func (c *EntryCredential) LogicalResponseData() map[string]any {
+	var tokenExpiresAt, tokenCreatedAt, nextRotationAttempt = "", "", ""
+	if !c.TokenExpiresAt.IsZero() {
+		tokenExpiresAt = c.TokenExpiresAt.Format(time.RFC3339)
+	}
+	if !c.TokenCreatedAt.IsZero() {
+		tokenCreatedAt = c.TokenCreatedAt.Format(time.RFC3339)
+	}
+	if !c.NextRotationAttempt.IsZero() {
+		nextRotationAttempt = c.NextRotationAttempt.Format(time.RFC3339)
+	}
+	return map[string]any{
+		"token_id":              c.TokenId,
+		"token_created_at":      tokenCreatedAt,
+		"token_expires_at":      tokenExpiresAt,
+		"token_sha1_hash":       fmt.Sprintf("%x", sha1.Sum([]byte(c.Token))),
+		"scopes":                strings.Join(c.Scopes, ", "),
+		"token_kind":            cmp.Or(c.TokenKind, TokenTypePersonal).String(),
+		"last_rotation_error":   c.LastRotationError,
+		"consecutive_failures":  c.ConsecutiveFailures,
+		"next_rotation_attempt": nextRotationAttempt,
+		"rotation_pending":      c.PendingToken != nil,
+		"pending_token_id":      pendingTokenId(c.PendingToken),
+	}
+}
+
+// pendingTokenId is the id of the pending token, while a rotation is pending the stored token is already invalid and
+// the pending token is the one that works
+func pendingTokenId(token *EntryToken) int {
+	if token == nil {
+		return 0
+	}
+	return token.TokenID
+}
+
+// credentialClientName is the name the client of the credential is cached under
+func credentialClientName(name string, capability Capability) string {
+	return fmt.Sprintf("%s/%s", cmp.Or(name, DefaultConfigName), capability)
+}
+
+// credentialConfig returns a copy of the config that uses the credential of the capability as its token,
+// so the client, rotation and scheduling logic of the config token can be used for the credential.
+func (e *EntryConfig) credentialConfig(capability Capability) *EntryConfig {
+	var cred = e.Credentials[capability]
+	if cred == nil {
+		return nil
+	}
+	var config = *e
+	config.Name = credentialClientName(e.Name, capability)
+	config.Token = cred.Token
+	config.TokenId = cred.TokenId
+	config.TokenCreatedAt = cred.TokenCreatedAt
+	config.TokenExpiresAt = cred.TokenExpiresAt
+	config.Scopes = cred.Scopes
+	config.TokenKind = cred.TokenKind
+	config.TokenParentId = cred.TokenParentId
+	config.LastRotationAttempt = cred.LastRotationAttempt
+	config.LastRotationError = cred.LastRotationError
+	config.ConsecutiveFailures = cred.ConsecutiveFailures
+	config.NextRotationAttempt = cred.NextRotationAttempt
+	config.PendingToken = cred.PendingToken
+	config.Credentials = nil
+	return &config
+}
+
+// credentialsPending reports if the rotation of one of the credentials was interrupted
+func (e *EntryConfig) credentialsPending() bool {
+	for _, cred := range e.Credentials {
+		if cred != nil && cred.PendingToken != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// updateFromConfig copies the token information of the credential config back to the credential
+func (c *EntryCredential) updateFromConfig(config *EntryConfig) {
+	c.Token = config.Token
+	c.TokenId = config.TokenId
+	c.TokenCreatedAt = config.TokenCreatedAt
+	c.TokenExpiresAt = config.TokenExpiresAt
+	c.Scopes = config.Scopes
+	c.TokenKind = config.TokenKind
+	c.TokenParentId = config.TokenParentId
 }
 
 func (e *EntryConfig) Merge(data *framework.FieldData) (warnings []string, changes map[string]string, err error) {
@@ -73,9 +211,260 @@ func (e *EntryConfig) Merge(data *framework.FieldData) (warnings []string, chang
 		changes["token"] = strings.Repeat("*", len(e.Token))
 	}
 
+	if val, ok := data.GetOk("webhook_secret"); ok {
+		e.WebhookSecret = val.(string)
+		changes["webhook_secret"] = strings.Repeat("*", len(e.WebhookSecret))
+	}
+
+	if val, ok := data.GetOk("reconcile_interval"); ok {
+		e.ReconcileInterval = time.Duration(val.(int)) * time.Second
+		changes["reconcile_interval"] = e.ReconcileInterval.String()
+	}
+
+	if val, ok := data.GetOk("reconcile_resolve_stale"); ok {
+		e.ReconcileResolveStale = val.(bool)
+		changes["reconcile_resolve_stale"] = strconv.FormatBool(e.ReconcileResolveStale)
+	}
+
+	if val, ok := data.GetOk("rotation_schedule"); ok {
+		e.RotationSchedule = val.(string)
+		changes["rotation_schedule"] = e.RotationSchedule
+	}
+
+	if val, ok := data.GetOk("rotation_window"); ok {
+		e.RotationWindow = time.Duration(val.(int)) * time.Second
+		changes["rotation_window"] = e.RotationWindow.String()
+	}
+
+	if val, ok := data.GetOk("rotation_period"); ok {
+		e.RotationPeriod = time.Duration(val.(int)) * time.Second
+		changes["rotation_period"] = e.RotationPeriod.String()
+	}
+
+	if er := e.validateRotationSchedule(); er != nil {
+		err = multierror.Append(err, er.Errors...)
+	}
+
+	if val, ok := data.GetOk("allowed_token_types"); ok {
+		e.AllowedTokenTypes = val.([]string)
+		changes["allowed_token_types"] = strings.Join(e.AllowedTokenTypes, ", ")
+	}
+
+	if val, ok := data.GetOk("max_access_level"); ok {
+		e.MaxAccessLevel = AccessLevel(val.(string))
+		changes["max_access_level"] = e.MaxAccessLevel.String()
+	}
+
+	if val, ok := data.GetOk("allowed_scopes"); ok {
+		e.AllowedScopes = val.([]string)
+		changes["allowed_scopes"] = strings.Join(e.AllowedScopes, ", ")
+	}
+
+	if val, ok := data.GetOk("denied_scopes"); ok {
+		e.DeniedScopes = val.([]string)
+		changes["denied_scopes"] = strings.Join(e.DeniedScopes, ", ")
+	}
+
+	if val, ok := data.GetOk("max_ttl"); ok {
+		e.MaxTTL = time.Duration(val.(int)) * time.Second
+		changes["max_ttl"] = e.MaxTTL.String()
+	}
+
+	if val, ok := data.GetOk("allowed_path_patterns"); ok {
+		e.AllowedPathPatterns = val.([]string)
+		changes["allowed_path_patterns"] = strings.Join(e.AllowedPathPatterns, ", ")
+	}
+
+	if val, ok := data.GetOk("vault_revoked_min_ttl"); ok {
+		e.VaultRevokedMinTTL = time.Duration(val.(int)) * time.Second
+		changes["vault_revoked_min_ttl"] = e.VaultRevokedMinTTL.String()
+	}
+
+	if val, ok := data.GetOk("circuit_breaker_threshold"); ok {
+		e.CircuitBreakerThreshold = val.(int)
+		changes["circuit_breaker_threshold"] = strconv.Itoa(e.CircuitBreakerThreshold)
+	}
+
+	if val, ok := data.GetOk("validate_roles"); ok {
+		e.ValidateRoles = val.(bool)
+		changes["validate_roles"] = strconv.FormatBool(e.ValidateRoles)
+	}
+
+	if val, ok := data.GetOk("role_history_retention"); ok {
+		e.RoleHistoryRetention = val.(int)
+		changes["role_history_retention"] = strconv.Itoa(e.RoleHistoryRetention)
+	}
+
+	if er := e.validatePolicy(); er != nil {
+		err = multierror.Append(err, er.Errors...)
+	}
+
+	for _, capability := range validCapabilities {
+		var field = fmt.Sprintf("%s_token", capability)
+		if val, ok := data.GetOk(field); ok {
+			e.setCredential(capability, val.(string))
+			changes[field] = strings.Repeat("*", len(val.(string)))
+		}
+	}
+
 	return warnings, changes, err
 }
 
+// setCredential sets the token of the credential for the capability, an empty token removes the credential
+func (e *EntryConfig) setCredential(capability Capability, token string) {
+	if token == "" {
+		delete(e.Credentials, capability)
+		if len(e.Credentials) == 0 {
+			e.Credentials = nil
+		}
+		return
+	}
+	if e.Credentials == nil {
+		e.Credentials = make(map[Capability]*EntryCredential)
+	}
+	e.Credentials[capability] = &EntryCredential{Token: token}
+}
+
+func (e *EntryConfig) validateRotationSchedule() (err *multierror.Error) {
+	if e.RotationSchedule != "" {
+		if _, er := cron.ParseStandard(e.RotationSchedule); er != nil {
+			err = multierror.Append(err, fmt.Errorf("rotation_schedule: %w: %w", er, ErrInvalidValue))
+		}
+		if e.RotationPeriod > 0 {
+			err = multierror.Append(err, fmt.Errorf("rotation_schedule and rotation_period are mutually exclusive: %w", ErrInvalidValue))
+		}
+	} else if e.RotationWindow > 0 {
+		err = multierror.Append(err, fmt.Errorf("rotation_window requires rotation_schedule: %w", ErrInvalidValue))
+	}
+	if e.RotationWindow < 0 || e.RotationPeriod < 0 {
+		err = multierror.Append(err, fmt.Errorf("rotation_window and rotation_period can not be negative: %w", ErrInvalidValue))
+	}
+	return err
+}
+
+func (e *EntryConfig) validatePolicy() (err *multierror.Error) {
+	for _, tokenType := range e.AllowedTokenTypes {
+		if _, er := TokenTypeParse(tokenType); er != nil {
+			err = multierror.Append(err, fmt.Errorf("allowed_token_types: %w", er))
+		}
+	}
+	if e.MaxAccessLevel != AccessLevelUnknown {
+		if _, er := AccessLevelParse(e.MaxAccessLevel.String()); er != nil {
+			err = multierror.Append(err, fmt.Errorf("max_access_level: %w", er))
+		}
+	}
+	if e.MaxTTL < 0 {
+		err = multierror.Append(err, fmt.Errorf("max_ttl can not be negative: %w", ErrInvalidValue))
+	}
+	if e.CircuitBreakerThreshold < 0 {
+		err = multierror.Append(err, fmt.Errorf("circuit_breaker_threshold can not be negative: %w", ErrInvalidValue))
+	}
+	if e.ReconcileInterval < 0 {
+		err = multierror.Append(err, fmt.Errorf("reconcile_interval can not be negative: %w", ErrInvalidValue))
+	}
+	if e.RoleHistoryRetention < 0 {
+		err = multierror.Append(err, fmt.Errorf("role_history_retention can not be negative: %w", ErrInvalidValue))
+	}
+	if e.VaultRevokedMinTTL != 0 && (e.VaultRevokedMinTTL < DefaultVaultRevokedMinTTLFloor || e.VaultRevokedMinTTL > DefaultAccessTokenMinTTL) {
+		err = multierror.Append(err, fmt.Errorf("vault_revoked_min_ttl = %s [%s <= vault_revoked_min_ttl <= %s]: %w", e.VaultRevokedMinTTL, DefaultVaultRevokedMinTTLFloor, DefaultAccessTokenMinTTL, ErrInvalidValue))
+	}
+	return err
+}
+
+// MinVaultRevokedTTL returns the shortest TTL a role can use when Vault revokes the tokens
+func (e *EntryConfig) MinVaultRevokedTTL() time.Duration {
+	return cmp.Or(e.VaultRevokedMinTTL, DefaultVaultRevokedMinTTL)
+}
+
+// CheckRole checks that the role is within the limits of the config, so whoever can write roles can't request
+// more than the config allows.
+func (e *EntryConfig) CheckRole(role EntryRole) (err error) {
+	if len(e.AllowedTokenTypes) > 0 && !slices.Contains(e.AllowedTokenTypes, role.TokenType.String()) {
+		err = multierror.Append(err, fmt.Errorf("token_type='%s' is not one of the allowed token types %v: %w", role.TokenType, e.AllowedTokenTypes, ErrRoleNotAllowed))
+	}
+
+	if e.MaxAccessLevel != AccessLevelUnknown && role.AccessLevel != AccessLevelUnknown && role.AccessLevel.Value() > e.MaxAccessLevel.Value() {
+		err = multierror.Append(err, fmt.Errorf("access_level='%s' is above the max access level '%s': %w", role.AccessLevel, e.MaxAccessLevel, ErrRoleNotAllowed))
+	}
+
+	var notAllowedScopes []string
+	for _, scope := range role.Scopes {
+		if (len(e.AllowedScopes) > 0 && !slices.Contains(e.AllowedScopes, scope)) || slices.Contains(e.DeniedScopes, scope) {
+			notAllowedScopes = append(notAllowedScopes, scope)
+		}
+	}
+	if len(notAllowedScopes) > 0 {
+		err = multierror.Append(err, fmt.Errorf("scopes='%v' are not allowed: %w", notAllowedScopes, ErrRoleNotAllowed))
+	}
+
+	if e.MaxTTL > 0 && role.TTL > e.MaxTTL {
+		err = multierror.Append(err, fmt.Errorf("ttl = %s [ttl <= max_ttl = %s]: %w", role.TTL, e.MaxTTL, ErrRoleNotAllowed))
+	}
+
+	if len(e.AllowedPathPatterns) > 0 && !slices.ContainsFunc(e.AllowedPathPatterns, func(pattern string) bool { return glob.Glob(pattern, role.Path) }) {
+		err = multierror.Append(err, fmt.Errorf("path='%s' does not match any of the allowed path patterns %v: %w", role.Path, e.AllowedPathPatterns, ErrRoleNotAllowed))
+	}
+
+	return err
+}
+
+// NextRotation returns when the config token is planned to be rotated next, or a zero time if the token is not
+// rotated automatically. If a rotation_schedule is set the token is only rotated at the scheduled times, and
+// when a rotation_window is set as well a missed window moves the rotation to the next one.
+func (e *EntryConfig) NextRotation(now time.Time) (next time.Time) {
+	if e.RotationSchedule != "" {
+		var schedule, err = cron.ParseStandard(e.RotationSchedule)
+		if err != nil {
+			return time.Time{}
+		}
+		next = schedule.Next(e.TokenCreatedAt.UTC())
+		if e.RotationWindow > 0 && !now.Before(next.Add(e.RotationWindow)) {
+			next = schedule.Next(now.UTC().Add(-e.RotationWindow))
+		}
+	}
+
+	if e.RotationPeriod > 0 {
+		if period := e.TokenCreatedAt.Add(e.RotationPeriod); next.IsZero() || period.Before(next) {
+			next = period
+		}
+	}
+
+	// the token is rotated before it expires, even when the schedule or the period would rotate it later
+	if e.rotationScheduled() && !e.TokenExpiresAt.IsZero() {
+		if expiry := e.TokenExpiresAt.Add(-cmp.Or(e.AutoRotateBefore, DefaultAutoRotateBeforeMinTTL)); next.IsZero() || expiry.Before(next) {
+			next = expiry
+		}
+	}
+	return next
+}
+
+// CanCreate returns true if the token that creates tokens of the token type, the credential of the capability or
+// the config token, is able to. Group access tokens can only manage their group, its projects and service accounts,
+// and project access tokens only their project.
+func (e *EntryConfig) CanCreate(tokenType TokenType) bool {
+	switch e.tokenKindFor(tokenType) {
+	case TokenTypeGroup:
+		return tokenType == TokenTypeGroup || tokenType == TokenTypeProject || tokenType == TokenTypeGroupServiceAccount
+	case TokenTypeProject:
+		return tokenType == TokenTypeProject
+	default:
+		return true
+	}
+}
+
+// tokenKindFor returns the kind of the token that creates tokens of the token type
+func (e *EntryConfig) tokenKindFor(tokenType TokenType) TokenType {
+	if credConfig := e.credentialConfig(capabilityForTokenType(tokenType)); credConfig != nil {
+		return credConfig.TokenKind
+	}
+	return e.TokenKind
+}
+
+// rotationScheduled returns true if the config token should be rotated automatically
+func (e *EntryConfig) rotationScheduled() bool {
+	return e.AutoRotateToken || e.RotationSchedule != "" || e.RotationPeriod > 0
+}
+
 func (e *EntryConfig) updateAutoRotateBefore(data *framework.FieldData) (warnings []string, err *multierror.Error) {
 	if val, ok := data.GetOk("auto_rotate_before"); ok {
 		atr, _ := convertToInt(val)
@@ -125,6 +514,84 @@ func (e *EntryConfig) UpdateFromFieldData(data *framework.FieldData) (warnings [
 		err = multierror.Append(err, fmt.Errorf("base_url: %w", ErrFieldRequired))
 	}
 
+	if webhookSecret, ok := data.GetOk("webhook_secret"); ok {
+		e.WebhookSecret = webhookSecret.(string)
+	}
+
+	if reconcileInterval, ok := data.GetOk("reconcile_interval"); ok {
+		e.ReconcileInterval = time.Duration(reconcileInterval.(int)) * time.Second
+	}
+
+	if reconcileResolveStale, ok := data.GetOk("reconcile_resolve_stale"); ok {
+		e.ReconcileResolveStale = reconcileResolveStale.(bool)
+	}
+
+	if rotationSchedule, ok := data.GetOk("rotation_schedule"); ok {
+		e.RotationSchedule = rotationSchedule.(string)
+	}
+
+	if rotationWindow, ok := data.GetOk("rotation_window"); ok {
+		e.RotationWindow = time.Duration(rotationWindow.(int)) * time.Second
+	}
+
+	if rotationPeriod, ok := data.GetOk("rotation_period"); ok {
+		e.RotationPeriod = time.Duration(rotationPeriod.(int)) * time.Second
+	}
+
+	if er := e.validateRotationSchedule(); er != nil {
+		err = multierror.Append(err, er.Errors...)
+	}
+
+	if allowedTokenTypes, ok := data.GetOk("allowed_token_types"); ok {
+		e.AllowedTokenTypes = allowedTokenTypes.([]string)
+	}
+
+	if maxAccessLevel, ok := data.GetOk("max_access_level"); ok {
+		e.MaxAccessLevel = AccessLevel(maxAccessLevel.(string))
+	}
+
+	if allowedScopes, ok := data.GetOk("allowed_scopes"); ok {
+		e.AllowedScopes = allowedScopes.([]string)
+	}
+
+	if deniedScopes, ok := data.GetOk("denied_scopes"); ok {
+		e.DeniedScopes = deniedScopes.([]string)
+	}
+
+	if maxTTL, ok := data.GetOk("max_ttl"); ok {
+		e.MaxTTL = time.Duration(maxTTL.(int)) * time.Second
+	}
+
+	if allowedPathPatterns, ok := data.GetOk("allowed_path_patterns"); ok {
+		e.AllowedPathPatterns = allowedPathPatterns.([]string)
+	}
+
+	if vaultRevokedMinTTL, ok := data.GetOk("vault_revoked_min_ttl"); ok {
+		e.VaultRevokedMinTTL = time.Duration(vaultRevokedMinTTL.(int)) * time.Second
+	}
+
+	if circuitBreakerThreshold, ok := data.GetOk("circuit_breaker_threshold"); ok {
+		e.CircuitBreakerThreshold = circuitBreakerThreshold.(int)
+	}
+
+	if validateRoles, ok := data.GetOk("validate_roles"); ok {
+		e.ValidateRoles = validateRoles.(bool)
+	}
+
+	if roleHistoryRetention, ok := data.GetOk("role_history_retention"); ok {
+		e.RoleHistoryRetention = roleHistoryRetention.(int)
+	}
+
+	if er := e.validatePolicy(); er != nil {
+		err = multierror.Append(err, er.Errors...)
+	}
+
+	for _, capability := range validCapabilities {
+		if token, ok := data.GetOk(fmt.Sprintf("%s_token", capability)); ok {
+			e.setCredential(capability, token.(string))
+		}
+	}
+
 	{
 		w, er := e.updateAutoRotateBefore(data)
 		if er != nil {
@@ -136,7 +603,7 @@ func (e *EntryConfig) UpdateFromFieldData(data *framework.FieldData) (warnings [
 	return warnings, err
 }
 
-func (e *EntryConfig) LogicalResponseData() map[string]any {
+func (e *EntryConfig) LogicalResponseData(ctx context.Context) map[string]any {
 	var tokenExpiresAt, tokenCreatedAt = "", ""
 	if !e.TokenExpiresAt.IsZero() {
 		tokenExpiresAt = e.TokenExpiresAt.Format(time.RFC3339)
@@ -144,19 +611,108 @@ func (e *EntryConfig) LogicalResponseData() map[string]any {
 	if !e.TokenCreatedAt.IsZero() {
 		tokenCreatedAt = e.TokenCreatedAt.Format(time.RFC3339)
 	}
+	var lastRotationAttempt, nextRotationAttempt = "", ""
+	if !e.LastRotationAttempt.IsZero() {
+		lastRotationAttempt = e.LastRotationAttempt.Format(time.RFC3339)
+	}
+	if !e.NextRotationAttempt.IsZero() {
+		nextRotationAttempt = e.NextRotationAttempt.Format(time.RFC3339)
+	}
+	var nextPlannedRotation = ""
+	if next := e.NextRotation(TimeFromContext(ctx)); !next.IsZero() {
+		nextPlannedRotation = next.UTC().Format(time.RFC3339)
+	}
+	var credentials = make(map[string]any, len(e.Credentials))
+	for capability, cred := range e.Credentials {
+		credentials[capability.String()] = cred.LogicalResponseData()
+	}
+	var webhookSecretSha1Hash = ""
+	if e.WebhookSecret != "" {
+		webhookSecretSha1Hash = fmt.Sprintf("%x", sha1.Sum([]byte(e.WebhookSecret)))
+	}
 
 	return map[string]any{
-		"base_url":           e.BaseURL,
-		"auto_rotate_token":  e.AutoRotateToken,
-		"auto_rotate_before": e.AutoRotateBefore.String(),
-		"token_id":           e.TokenId,
-		"token_created_at":   tokenCreatedAt,
-		"token_expires_at":   tokenExpiresAt,
-		"token_sha1_hash":    fmt.Sprintf("%x", sha1.Sum([]byte(e.Token))),
-		"scopes":             strings.Join(e.Scopes, ", "),
-		"type":               e.Type.String(),
-		"name":               e.Name,
+		"base_url":                  e.BaseURL,
+		"auto_rotate_token":         e.AutoRotateToken,
+		"auto_rotate_before":        e.AutoRotateBefore.String(),
+		"token_id":                  e.TokenId,
+		"token_created_at":          tokenCreatedAt,
+		"token_expires_at":          tokenExpiresAt,
+		"token_sha1_hash":           fmt.Sprintf("%x", sha1.Sum([]byte(e.Token))),
+		"scopes":                    strings.Join(e.Scopes, ", "),
+		"type":                      e.Type.String(),
+		"name":                      e.Name,
+		"webhook_secret_sha1_hash":  webhookSecretSha1Hash,
+		"reconcile_interval":        e.ReconcileInterval.String(),
+		"reconcile_resolve_stale":   e.ReconcileResolveStale,
+		"last_rotation_attempt":     lastRotationAttempt,
+		"last_rotation_error":       e.LastRotationError,
+		"consecutive_failures":      e.ConsecutiveFailures,
+		"next_rotation_attempt":     nextRotationAttempt,
+		"rotation_pending":          e.PendingToken != nil,
+		"pending_token_id":          pendingTokenId(e.PendingToken),
+		"rotation_schedule":         e.RotationSchedule,
+		"rotation_window":           e.RotationWindow.String(),
+		"rotation_period":           e.RotationPeriod.String(),
+		"next_planned_rotation":     nextPlannedRotation,
+		"token_kind":                cmp.Or(e.TokenKind, TokenTypePersonal).String(),
+		"token_parent_id":           e.TokenParentId,
+		"credentials":               credentials,
+		"allowed_token_types":       e.AllowedTokenTypes,
+		"max_access_level":          e.MaxAccessLevel.String(),
+		"allowed_scopes":            e.AllowedScopes,
+		"denied_scopes":             e.DeniedScopes,
+		"max_ttl":                   e.MaxTTL.String(),
+		"allowed_path_patterns":     e.AllowedPathPatterns,
+		"vault_revoked_min_ttl":     e.MinVaultRevokedTTL().String(),
+		"circuit_breaker_threshold": cmp.Or(e.CircuitBreakerThreshold, DefaultCircuitBreakerThreshold),
+		"validate_roles":            e.ValidateRoles,
+		"role_history_retention":    cmp.Or(e.RoleHistoryRetention, DefaultRoleHistoryRetention),
+	}
+}
+
+// exportData returns the config as the fields of a config write without the credentials, the fields with the
+// default value are left out
+func (e *EntryConfig) exportData() map[string]any {
+	var data = map[string]any{
+		"base_url": e.BaseURL,
+		"type":     e.Type.String(),
+	}
+	setExportValue(data, "auto_rotate_token", e.AutoRotateToken, e.AutoRotateToken)
+	setExportValue(data, "auto_rotate_before", int64(e.AutoRotateBefore/time.Second), e.AutoRotateBefore > 0)
+	setExportValue(data, "reconcile_interval", int64(e.ReconcileInterval/time.Second), e.ReconcileInterval > 0)
+	setExportValue(data, "reconcile_resolve_stale", e.ReconcileResolveStale, e.ReconcileResolveStale)
+	setExportValue(data, "rotation_schedule", e.RotationSchedule, e.RotationSchedule != "")
+	setExportValue(data, "rotation_window", int64(e.RotationWindow/time.Second), e.RotationWindow > 0)
+	setExportValue(data, "rotation_period", int64(e.RotationPeriod/time.Second), e.RotationPeriod > 0)
+	setExportValue(data, "allowed_token_types", e.AllowedTokenTypes, len(e.AllowedTokenTypes) > 0)
+	setExportValue(data, "max_access_level", e.MaxAccessLevel.String(), e.MaxAccessLevel != AccessLevelUnknown)
+	setExportValue(data, "allowed_scopes", e.AllowedScopes, len(e.AllowedScopes) > 0)
+	setExportValue(data, "denied_scopes", e.DeniedScopes, len(e.DeniedScopes) > 0)
+	setExportValue(data, "max_ttl", int64(e.MaxTTL/time.Second), e.MaxTTL > 0)
+	setExportValue(data, "allowed_path_patterns", e.AllowedPathPatterns, len(e.AllowedPathPatterns) > 0)
+	setExportValue(data, "vault_revoked_min_ttl", int64(e.VaultRevokedMinTTL/time.Second), e.VaultRevokedMinTTL > 0)
+	setExportValue(data, "circuit_breaker_threshold", e.CircuitBreakerThreshold, e.CircuitBreakerThreshold > 0)
+	setExportValue(data, "validate_roles", e.ValidateRoles, e.ValidateRoles)
+	setExportValue(data, "role_history_retention", e.RoleHistoryRetention, e.RoleHistoryRetention > 0)
+	return data
+}
+
+// exportCredentials returns the credentials of the config as the fields of a config write
+func (e *EntryConfig) exportCredentials() map[string]string {
+	var credentials = map[string]string{}
+	if e.Token != "" {
+		credentials["token"] = e.Token
+	}
+	if e.WebhookSecret != "" {
+		credentials["webhook_secret"] = e.WebhookSecret
+	}
+	for capability, credential := range e.Credentials {
+		if credential != nil && credential.Token != "" {
+			credentials[fmt.Sprintf("%s_token", capability)] = credential.Token
+		}
 	}
+	return credentials
 }
 
 func getConfig(ctx context.Context, s logical.Storage, name string) (cfg *EntryConfig, err error) {
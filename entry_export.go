@@ -0,0 +1,198 @@
+package gitlab
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ExportFormatJSON = "json"
+	ExportFormatYAML = "yaml"
+
+	ImportModeMerge   = "merge"
+	ImportModeReplace = "replace"
+	ImportModeDryRun  = "dry-run"
+
+	importActionCreate    = "create"
+	importActionUpdate    = "update"
+	importActionDelete    = "delete"
+	importActionUnchanged = "unchanged"
+
+	// sensitiveValue replaces the credentials in the diff of an import
+	sensitiveValue = "<sensitive>"
+)
+
+var (
+	ErrInvalidDocument = errors.New("invalid document")
+
+	validExportFormats = []string{ExportFormatJSON, ExportFormatYAML}
+	validImportModes   = []string{ImportModeMerge, ImportModeReplace, ImportModeDryRun}
+)
+
+// ExportDocument is the state of the backend, every entry holds the fields of a write to the config, role template
+// or role. Credentials are only in the document when they are encrypted, under encrypted_credentials of the config.
+type ExportDocument struct {
+	Configs       map[string]map[string]any `json:"configs" yaml:"configs"`
+	RoleTemplates map[string]map[string]any `json:"role_templates" yaml:"role_templates"`
+	Roles         map[string]map[string]any `json:"roles" yaml:"roles"`
+}
+
+// Marshal encodes the document in the format
+func (d ExportDocument) Marshal(format string) (out []byte, err error) {
+	switch format {
+	case ExportFormatYAML:
+		return yaml.Marshal(d)
+	case ExportFormatJSON:
+		return json.MarshalIndent(d, "", "  ")
+	}
+	return nil, fmt.Errorf("format='%s', should be one of %v: %w", format, validExportFormats, ErrFieldInvalidValue)
+}
+
+// ExportDocumentParse decodes a JSON or YAML document
+func ExportDocumentParse(value string) (doc ExportDocument, err error) {
+	// JSON is valid YAML, so both formats are decoded the same way
+	if err = yaml.Unmarshal([]byte(value), &doc); err != nil {
+		return doc, fmt.Errorf("%w: %w", ErrInvalidDocument, err)
+	}
+	return doc, nil
+}
+
+// importItem is the change an import makes to a config, role template or role
+type importItem struct {
+	Kind    string
+	Name    string
+	Action  string
+	Changes map[string]any
+
+	data map[string]any
+}
+
+func (i importItem) LogicalResponseData() map[string]any {
+	return map[string]any{
+		"kind":    i.Kind,
+		"name":    i.Name,
+		"action":  i.Action,
+		"changes": i.Changes,
+	}
+}
+
+// setExportValue sets the value only when it isn't the default, to keep the exported documents short
+func setExportValue(data map[string]any, key string, value any, set bool) {
+	if set {
+		data[key] = value
+	}
+}
+
+// diffExportData returns the fields that differ between the exported data, with the old and the new value
+func diffExportData(before, after map[string]any) map[string]any {
+	var changes = map[string]any{}
+	var keys = make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		if !reflect.DeepEqual(before[key], after[key]) {
+			changes[key] = map[string]any{"old": before[key], "new": after[key]}
+		}
+	}
+	return changes
+}
+
+// diffCredentials returns the credentials that changed, without their values
+func diffCredentials(before, after map[string]string) map[string]any {
+	var changes = map[string]any{}
+	for key, value := range after {
+		if before[key] != value {
+			changes[key] = map[string]any{"old": sensitive(before[key]), "new": sensitive(value)}
+		}
+	}
+	return changes
+}
+
+func sensitive(value string) string {
+	if value == "" {
+		return ""
+	}
+	return sensitiveValue
+}
+
+// importAction returns the action for an item that existed or not, and the changes to it
+func importAction(existed bool, changes map[string]any) string {
+	switch {
+	case !existed:
+		return importActionCreate
+	case len(changes) > 0:
+		return importActionUpdate
+	}
+	return importActionUnchanged
+}
+
+// parseRSAPublicKey parses a PEM encoded RSA public key
+func parseRSAPublicKey(value string) (*rsa.PublicKey, error) {
+	var block, _ = pem.Decode([]byte(strings.TrimSpace(value)))
+	if block == nil {
+		return nil, fmt.Errorf("public_key is not PEM encoded: %w", ErrFieldInvalidValue)
+	}
+	var key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("public_key: %w: %w", err, ErrFieldInvalidValue)
+	}
+	if rsaKey, ok := key.(*rsa.PublicKey); ok {
+		return rsaKey, nil
+	}
+	return nil, fmt.Errorf("public_key is not an RSA key: %w", ErrFieldInvalidValue)
+}
+
+// encryptCredentials encrypts every credential with RSA-OAEP and SHA-256, and encodes it with base64
+func encryptCredentials(key *rsa.PublicKey, credentials map[string]string) (encrypted map[string]string, err error) {
+	encrypted = make(map[string]string, len(credentials))
+	for name, value := range credentials {
+		var ciphertext []byte
+		if ciphertext, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, key, []byte(value), nil); err != nil {
+			return nil, fmt.Errorf("encrypting %s: %w", name, err)
+		}
+		encrypted[name] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+	return encrypted, nil
+}
+
+// stagingStorage copies the configs, role templates and roles to an in memory storage, so an import can be checked
+// without changing anything
+func stagingStorage(ctx context.Context, s logical.Storage) (staging logical.Storage, err error) {
+	staging = new(logical.InmemStorage)
+	for _, prefix := range []string{PathConfigStorage, PathRoleTemplateStorage, PathRoleStorage} {
+		var keys []string
+		if keys, err = logical.CollectKeysWithPrefix(ctx, s, prefix+"/"); err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			var entry *logical.StorageEntry
+			if entry, err = s.Get(ctx, key); err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				continue
+			}
+			if err = staging.Put(ctx, entry); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return staging, nil
+}
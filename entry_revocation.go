@@ -0,0 +1,100 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRevocationStorage = "revocations"
+)
+
+// EntryRevocation is a token that failed to be revoked and is retried by the periodic function
+type EntryRevocation struct {
+	Token         EntryToken `json:"token"`
+	LeaseID       string     `json:"lease_id"`
+	QueuedAt      time.Time  `json:"queued_at"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+}
+
+func (e EntryRevocation) LogicalResponseData() map[string]any {
+	return map[string]any{
+		"config_name":     e.Token.ConfigName,
+		"token_id":        e.Token.TokenID,
+		"token_type":      e.Token.TokenType.String(),
+		"parent_id":       e.Token.ParentID,
+		"lease_id":        e.LeaseID,
+		"queued_at":       e.QueuedAt.Format(time.RFC3339),
+		"attempts":        e.Attempts,
+		"last_error":      e.LastError,
+		"next_attempt_at": e.NextAttemptAt.Format(time.RFC3339),
+	}
+}
+
+// metadata returns the event metadata of the queued revocation
+func (e EntryRevocation) metadata() map[string]string {
+	return map[string]string{
+		"path":        fmt.Sprintf("%s/%s", PathRevocationStorage, PathRevocationsPending),
+		"config_name": e.Token.ConfigName,
+		"lease_id":    e.LeaseID,
+		"token_id":    strconv.Itoa(e.Token.TokenID),
+		"token_type":  e.Token.TokenType.String(),
+		"attempts":    strconv.Itoa(e.Attempts),
+		"error":       e.LastError,
+	}
+}
+
+func revocationStoragePath(configName string, tokenId int) string {
+	return fmt.Sprintf("%s/%s/%d", PathRevocationStorage, configName, tokenId)
+}
+
+func getRevocation(ctx context.Context, s logical.Storage, configName string, tokenId int) (revocation *EntryRevocation, err error) {
+	var entry *logical.StorageEntry
+	if entry, err = s.Get(ctx, revocationStoragePath(configName, tokenId)); err == nil {
+		if entry == nil {
+			return nil, nil
+		}
+		revocation = new(EntryRevocation)
+		_ = entry.DecodeJSON(revocation)
+	}
+	return revocation, err
+}
+
+func listRevocations(ctx context.Context, s logical.Storage, configName string) (revocations []*EntryRevocation, err error) {
+	var ids []string
+	if ids, err = s.List(ctx, fmt.Sprintf("%s/%s/", PathRevocationStorage, configName)); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		var tokenId int
+		if tokenId, err = strconv.Atoi(id); err != nil {
+			continue
+		}
+		var revocation *EntryRevocation
+		if revocation, err = getRevocation(ctx, s, configName, tokenId); err != nil {
+			return nil, err
+		}
+		if revocation != nil {
+			revocations = append(revocations, revocation)
+		}
+	}
+	return revocations, nil
+}
+
+func saveRevocation(ctx context.Context, revocation EntryRevocation, s logical.Storage) (err error) {
+	var storageEntry *logical.StorageEntry
+	if storageEntry, err = logical.StorageEntryJSON(revocationStoragePath(revocation.Token.ConfigName, revocation.Token.TokenID), revocation); err == nil {
+		err = s.Put(ctx, storageEntry)
+	}
+	return err
+}
+
+func deleteRevocation(ctx context.Context, s logical.Storage, configName string, tokenId int) error {
+	return s.Delete(ctx, revocationStoragePath(configName, tokenId))
+}
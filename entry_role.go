@@ -1,10 +1,16 @@
 package gitlab
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -18,22 +24,192 @@ type EntryRole struct {
 	TokenType           TokenType     `json:"token_type" structs:"token_type" mapstructure:"token_type"`
 	GitlabRevokesTokens bool          `json:"gitlab_revokes_token" structs:"gitlab_revokes_token" mapstructure:"gitlab_revokes_token"`
 	ConfigName          string        `json:"config_name" structs:"config_name" mapstructure:"config_name"`
+	PathId              int           `json:"path_id" structs:"path_id" mapstructure:"path_id"`
+	FullPath            string        `json:"full_path" structs:"full_path" mapstructure:"full_path"`
+	TestInterval        time.Duration `json:"test_interval" structs:"test_interval" mapstructure:"test_interval"`
+
+	Disabled             bool      `json:"disabled" structs:"disabled" mapstructure:"disabled"`
+	NotBefore            time.Time `json:"not_before" structs:"not_before" mapstructure:"not_before"`
+	NotAfter             time.Time `json:"not_after" structs:"not_after" mapstructure:"not_after"`
+	AvailabilityWindows  []string  `json:"availability_windows" structs:"availability_windows" mapstructure:"availability_windows"`
+	AvailabilityTimezone string    `json:"availability_timezone" structs:"availability_timezone" mapstructure:"availability_timezone"`
+
+	Description string            `json:"description" structs:"description" mapstructure:"description"`
+	Owner       string            `json:"owner" structs:"owner" mapstructure:"owner"`
+	Tags        map[string]string `json:"tags" structs:"tags" mapstructure:"tags"`
+	Extends     string            `json:"extends" structs:"extends" mapstructure:"extends"`
+
+	Version   int       `json:"version" structs:"version" mapstructure:"version"`
+	UpdatedAt time.Time `json:"updated_at" structs:"updated_at" mapstructure:"updated_at"`
+	UpdatedBy string    `json:"updated_by" structs:"updated_by" mapstructure:"updated_by"`
 }
 
 func (e EntryRole) LogicalResponseData() map[string]any {
 	return map[string]any{
-		"role_name":            e.RoleName,
-		"path":                 e.Path,
-		"name":                 e.Name,
-		"scopes":               e.Scopes,
-		"access_level":         e.AccessLevel.String(),
-		"ttl":                  int64(e.TTL / time.Second),
-		"token_type":           e.TokenType.String(),
-		"gitlab_revokes_token": e.GitlabRevokesTokens,
-		"config_name":          e.ConfigName,
+		"role_name":             e.RoleName,
+		"path":                  e.Path,
+		"name":                  e.Name,
+		"scopes":                e.Scopes,
+		"access_level":          e.AccessLevel.String(),
+		"ttl":                   int64(e.TTL / time.Second),
+		"token_type":            e.TokenType.String(),
+		"gitlab_revokes_token":  e.GitlabRevokesTokens,
+		"config_name":           e.ConfigName,
+		"path_id":               e.PathId,
+		"full_path":             e.FullPath,
+		"test_interval":         int64(e.TestInterval / time.Second),
+		"enabled":               !e.Disabled,
+		"not_before":            formatOptionalTime(e.NotBefore),
+		"not_after":             formatOptionalTime(e.NotAfter),
+		"availability_windows":  e.AvailabilityWindows,
+		"availability_timezone": cmp.Or(e.AvailabilityTimezone, time.UTC.String()),
+		"description":           e.Description,
+		"owner":                 e.Owner,
+		"tags":                  e.tags(),
+		"extends":               e.Extends,
+		"version":               e.Version,
+		"updated_at":            formatOptionalTime(e.UpdatedAt),
+		"updated_by":            e.UpdatedBy,
+	}
+}
+
+func (e EntryRole) tags() map[string]string {
+	if e.Tags == nil {
+		return map[string]string{}
+	}
+	return e.Tags
+}
+
+// listInfo is the information of the role shown in the detailed list of roles
+func (e EntryRole) listInfo() map[string]any {
+	return map[string]any{
+		"description": e.Description,
+		"owner":       e.Owner,
+		"tags":        e.tags(),
+		"extends":     e.Extends,
+		"token_type":  e.TokenType.String(),
+		"config_name": e.ConfigName,
+		"path":        e.Path,
+		"enabled":     !e.Disabled,
 	}
 }
 
+// matchesTags checks if the role has all the tags, a tag is either `key` to match any value or `key=value`
+func (e EntryRole) matchesTags(tags []string) bool {
+	for _, tag := range tags {
+		var key, value, hasValue = strings.Cut(tag, "=")
+		if current, ok := e.Tags[key]; !ok || (hasValue && current != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// tagsMetadata returns the tags as a sorted list of key=value pairs, used for the event metadata
+func (e EntryRole) tagsMetadata() string {
+	var tags = make([]string, 0, len(e.Tags))
+	for key, value := range e.Tags {
+		tags = append(tags, fmt.Sprintf("%s=%s", key, value))
+	}
+	slices.Sort(tags)
+	return strings.Join(tags, ",")
+}
+
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// available checks if the role can issue tokens at the time, a role can be disabled, limited to a validity range
+// and to recurring availability windows
+func (e EntryRole) available(t time.Time) error {
+	if e.Disabled {
+		return fmt.Errorf("role %s is disabled: %w", e.RoleName, ErrRoleNotAvailable)
+	}
+	if !e.NotBefore.IsZero() && t.Before(e.NotBefore) {
+		return fmt.Errorf("role %s is not valid before %s: %w", e.RoleName, e.NotBefore.Format(time.RFC3339), ErrRoleNotAvailable)
+	}
+	if !e.NotAfter.IsZero() && !t.Before(e.NotAfter) {
+		return fmt.Errorf("role %s expired at %s: %w", e.RoleName, e.NotAfter.Format(time.RFC3339), ErrRoleNotAvailable)
+	}
+	if len(e.AvailabilityWindows) == 0 {
+		return nil
+	}
+
+	var timezone = cmp.Or(e.AvailabilityTimezone, time.UTC.String())
+	var location, err = time.LoadLocation(timezone)
+	if err != nil {
+		return fmt.Errorf("role %s has an invalid availability timezone %s: %w", e.RoleName, timezone, ErrRoleNotAvailable)
+	}
+	for _, value := range e.AvailabilityWindows {
+		if window, er := AvailabilityWindowParse(value); er == nil && window.Contains(t.In(location)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("role %s is only available %s in %s: %w", e.RoleName, strings.Join(e.AvailabilityWindows, ", "), timezone, ErrRoleNotAvailable)
+}
+
+// gitlabPath returns the path used for the GitLab API, the pinned id if the path was resolved when the role was written
+func (e EntryRole) gitlabPath() string {
+	if e.PathId != 0 && (e.TokenType == TokenTypeGroup || e.TokenType == TokenTypeProject) {
+		return strconv.Itoa(e.PathId)
+	}
+	return e.Path
+}
+
+// exportData returns the role as the fields of a role write, the fields with the default value are left out
+func (e EntryRole) exportData() map[string]any {
+	var data = map[string]any{
+		"path":       e.Path,
+		"token_type": e.TokenType.String(),
+	}
+	setExportValue(data, "name", e.Name, e.Name != "")
+	setExportValue(data, "ttl", int64(e.TTL/time.Second), e.TTL > 0)
+	setExportValue(data, "scopes", e.Scopes, len(e.Scopes) > 0)
+	setExportValue(data, "access_level", e.AccessLevel.String(), e.AccessLevel != AccessLevelUnknown)
+	setExportValue(data, "gitlab_revokes_token", e.GitlabRevokesTokens, e.GitlabRevokesTokens)
+	setExportValue(data, "config_name", e.ConfigName, e.ConfigName != "")
+	setExportValue(data, "test_interval", int64(e.TestInterval/time.Second), e.TestInterval > 0)
+	setExportValue(data, "enabled", false, e.Disabled)
+	setExportValue(data, "not_before", formatOptionalTime(e.NotBefore), !e.NotBefore.IsZero())
+	setExportValue(data, "not_after", formatOptionalTime(e.NotAfter), !e.NotAfter.IsZero())
+	setExportValue(data, "availability_windows", e.AvailabilityWindows, len(e.AvailabilityWindows) > 0)
+	setExportValue(data, "availability_timezone", e.AvailabilityTimezone, e.AvailabilityTimezone != "")
+	setExportValue(data, "description", e.Description, e.Description != "")
+	setExportValue(data, "owner", e.Owner, e.Owner != "")
+	setExportValue(data, "tags", e.Tags, len(e.Tags) > 0)
+	setExportValue(data, "extends", e.Extends, e.Extends != "")
+	return data
+}
+
+// pinnedPath is the pinned id and full path of the group or project a merged role keeps, it isn't a field of the
+// schema so a request can't set it
+type pinnedPath struct {
+	id       int
+	fullPath string
+}
+
+const rawKeyPinnedPath = "pinned_path"
+
+// Merge returns the fields of the role with the fields set in the request replacing them, so a write of the result
+// keeps the fields the request doesn't set. Fields inherited from a template stay inherited. The pinned id and full
+// path are kept while the request doesn't change where the path is resolved.
+func (e EntryRole) Merge(data *framework.FieldData) *framework.FieldData {
+	var raw = e.exportData()
+	maps.Copy(raw, data.Raw)
+	raw["role_name"] = e.RoleName
+	delete(raw, rawKeyPinnedPath)
+	if e.PathId != 0 && !slices.ContainsFunc([]string{"path", "token_type", "config_name", "extends"}, func(field string) bool {
+		_, ok := data.Raw[field]
+		return ok
+	}) {
+		raw[rawKeyPinnedPath] = pinnedPath{id: e.PathId, fullPath: e.FullPath}
+	}
+	return &framework.FieldData{Raw: raw, Schema: data.Schema}
+}
+
 func getRole(ctx context.Context, name string, s logical.Storage) (role *EntryRole, err error) {
 	var entry *logical.StorageEntry
 	if entry, err = s.Get(ctx, fmt.Sprintf("%s/%s", PathRoleStorage, name)); err == nil {
@@ -46,3 +222,11 @@ func getRole(ctx context.Context, name string, s logical.Storage) (role *EntryRo
 	return role, err
 
 }
+
+func saveRole(ctx context.Context, role EntryRole, s logical.Storage) error {
+	var entry, err = logical.StorageEntryJSON(fmt.Sprintf("%s/%s", PathRoleStorage, role.RoleName), role)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,92 @@
+package gitlab
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRoleHistoryStorage = "roles-history"
+)
+
+func roleHistoryStoragePath(roleName string, version int) string {
+	return fmt.Sprintf("%s/%s/%d", PathRoleHistoryStorage, roleName, version)
+}
+
+func getRoleVersion(ctx context.Context, s logical.Storage, roleName string, version int) (role *EntryRole, err error) {
+	var entry *logical.StorageEntry
+	if entry, err = s.Get(ctx, roleHistoryStoragePath(roleName, version)); err == nil {
+		if entry == nil {
+			return nil, nil
+		}
+		role = new(EntryRole)
+		_ = entry.DecodeJSON(role)
+	}
+	return role, err
+}
+
+// listRoleVersions returns the versions of the role in the history, oldest first
+func listRoleVersions(ctx context.Context, s logical.Storage, roleName string) (versions []int, err error) {
+	var keys []string
+	if keys, err = s.List(ctx, fmt.Sprintf("%s/%s/", PathRoleHistoryStorage, roleName)); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		if version, er := strconv.Atoi(key); er == nil {
+			versions = append(versions, version)
+		}
+	}
+	slices.Sort(versions)
+	return versions, nil
+}
+
+// archiveRole stores the current version of the role in the history, removes the oldest versions over the
+// retention and returns the version the next write of the role should use. The current role can be nil, when the
+// role is new or was deleted, in which case only the version is computed.
+func archiveRole(ctx context.Context, s logical.Storage, roleName string, current *EntryRole, retention int) (next int, err error) {
+	var versions []int
+	if versions, err = listRoleVersions(ctx, s, roleName); err != nil {
+		return 0, err
+	}
+	if len(versions) > 0 {
+		next = versions[len(versions)-1]
+	}
+
+	if current != nil {
+		next = max(next, current.Version)
+		var entry *logical.StorageEntry
+		if entry, err = logical.StorageEntryJSON(roleHistoryStoragePath(roleName, current.Version), current); err != nil {
+			return 0, err
+		}
+		if err = s.Put(ctx, entry); err != nil {
+			return 0, err
+		}
+		if !slices.Contains(versions, current.Version) {
+			versions = append(versions, current.Version)
+			slices.Sort(versions)
+		}
+	}
+
+	retention = cmp.Or(retention, DefaultRoleHistoryRetention)
+	for len(versions) > retention {
+		if err = s.Delete(ctx, roleHistoryStoragePath(roleName, versions[0])); err != nil {
+			return 0, err
+		}
+		versions = versions[1:]
+	}
+
+	return next + 1, nil
+}
+
+// roleHistoryRetention returns how many versions of the roles of the config are kept
+func roleHistoryRetention(ctx context.Context, s logical.Storage, configName string) int {
+	if config, err := getConfig(ctx, s, cmp.Or(configName, DefaultConfigName)); err == nil && config != nil {
+		return cmp.Or(config.RoleHistoryRetention, DefaultRoleHistoryRetention)
+	}
+	return DefaultRoleHistoryRetention
+}
@@ -0,0 +1,137 @@
+package gitlab
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRoleTemplateStorage = "role-templates"
+)
+
+// roleTemplateFields are the fields a role can inherit from the template it extends
+var roleTemplateFields = []string{"name", "ttl", "scopes", "access_level", "config_name"}
+
+// EntryRoleTemplate is a partial role shared by the roles that extend it, a field that isn't set is not inherited
+type EntryRoleTemplate struct {
+	TemplateName string        `json:"template_name" structs:"template_name" mapstructure:"template_name"`
+	TTL          time.Duration `json:"ttl" structs:"ttl" mapstructure:"ttl"`
+	Name         string        `json:"name" structs:"name" mapstructure:"name"`
+	Scopes       []string      `json:"scopes" structs:"scopes" mapstructure:"scopes"`
+	AccessLevel  AccessLevel   `json:"access_level" structs:"access_level" mapstructure:"access_level,omitempty"`
+	ConfigName   string        `json:"config_name" structs:"config_name" mapstructure:"config_name"`
+}
+
+func (e EntryRoleTemplate) LogicalResponseData() map[string]any {
+	var scopes = e.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return map[string]any{
+		"template_name": e.TemplateName,
+		"ttl":           int64(e.TTL / time.Second),
+		"name":          e.Name,
+		"scopes":        scopes,
+		"access_level":  e.AccessLevel.String(),
+		"config_name":   e.ConfigName,
+	}
+}
+
+// exportData returns the template as the fields of a template write, the fields that aren't set are left out
+func (e EntryRoleTemplate) exportData() map[string]any {
+	var data = map[string]any{}
+	setExportValue(data, "name", e.Name, e.Name != "")
+	setExportValue(data, "ttl", int64(e.TTL/time.Second), e.TTL > 0)
+	setExportValue(data, "scopes", e.Scopes, len(e.Scopes) > 0)
+	setExportValue(data, "access_level", e.AccessLevel.String(), e.AccessLevel != AccessLevelUnknown)
+	setExportValue(data, "config_name", e.ConfigName, e.ConfigName != "")
+	return data
+}
+
+// apply fills the fields the role doesn't set with the values of the template, and returns the inherited fields
+func (e EntryRoleTemplate) apply(role *EntryRole) (inherited []string) {
+	if role.Name == "" && e.Name != "" {
+		role.Name = e.Name
+		inherited = append(inherited, "name")
+	}
+	if role.TTL == 0 && e.TTL != 0 {
+		role.TTL = e.TTL
+		inherited = append(inherited, "ttl")
+	}
+	if len(role.Scopes) == 0 && len(e.Scopes) > 0 {
+		role.Scopes = e.Scopes
+		inherited = append(inherited, "scopes")
+	}
+	if role.AccessLevel == AccessLevelUnknown && e.AccessLevel != AccessLevelUnknown {
+		role.AccessLevel = e.AccessLevel
+		inherited = append(inherited, "access_level")
+	}
+	if role.ConfigName == "" {
+		role.ConfigName = cmp.Or(e.ConfigName, DefaultConfigName)
+		if e.ConfigName != "" {
+			inherited = append(inherited, "config_name")
+		}
+	}
+	return inherited
+}
+
+// clearField clears a field of the role that can be inherited from a template
+func (e *EntryRole) clearField(field string) {
+	switch field {
+	case "name":
+		e.Name = ""
+	case "ttl":
+		e.TTL = 0
+	case "scopes":
+		e.Scopes = nil
+	case "access_level":
+		e.AccessLevel = AccessLevelUnknown
+	case "config_name":
+		e.ConfigName = ""
+	}
+}
+
+func getRoleTemplate(ctx context.Context, name string, s logical.Storage) (tmpl *EntryRoleTemplate, err error) {
+	var entry *logical.StorageEntry
+	if entry, err = s.Get(ctx, fmt.Sprintf("%s/%s", PathRoleTemplateStorage, name)); err == nil {
+		if entry == nil {
+			return nil, nil
+		}
+		tmpl = new(EntryRoleTemplate)
+		_ = entry.DecodeJSON(tmpl)
+	}
+	return tmpl, err
+}
+
+// resolveRole returns the effective role, the role merged with the template it extends, and the inherited fields
+func resolveRole(ctx context.Context, s logical.Storage, role *EntryRole) (effective *EntryRole, inherited []string, err error) {
+	if role == nil || role.Extends == "" {
+		return role, []string{}, nil
+	}
+	var tmpl *EntryRoleTemplate
+	if tmpl, err = getRoleTemplate(ctx, role.Extends, s); err != nil {
+		return nil, nil, err
+	}
+	if tmpl == nil {
+		return nil, nil, fmt.Errorf("role %s extends %s: %w", role.RoleName, role.Extends, ErrRoleTemplateNotFound)
+	}
+	var merged = *role
+	inherited = tmpl.apply(&merged)
+	if inherited == nil {
+		inherited = []string{}
+	}
+	return &merged, inherited, nil
+}
+
+// getEffectiveRole reads the role merged with the template it extends, changes to the template apply to the role
+// immediately
+func getEffectiveRole(ctx context.Context, name string, s logical.Storage) (role *EntryRole, inherited []string, err error) {
+	if role, err = getRole(ctx, name, s); err != nil {
+		return nil, nil, err
+	}
+	return resolveRole(ctx, s, role)
+}
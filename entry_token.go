@@ -1,8 +1,16 @@
 package gitlab
 
 import (
+	"context"
+	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathIssuedTokenStorage = "issued-tokens"
 )
 
 type EntryToken struct {
@@ -20,6 +28,7 @@ type EntryToken struct {
 	RoleName           string      `json:"role_name"`
 	ConfigName         string      `json:"config_name"`
 	GitlabRevokesToken bool        `json:"gitlab_revokes_token"`
+	Revoked            bool        `json:"revoked"`
 }
 
 func (e EntryToken) SecretResponse() (map[string]any, map[string]any) {
@@ -48,3 +57,52 @@ func (e EntryToken) SecretResponse() (map[string]any, map[string]any) {
 			"gitlab_revokes_token": strconv.FormatBool(e.GitlabRevokesToken),
 		}
 }
+
+func issuedTokenStoragePath(configName string, tokenId int) string {
+	return fmt.Sprintf("%s/%s/%d", PathIssuedTokenStorage, configName, tokenId)
+}
+
+func getIssuedToken(ctx context.Context, s logical.Storage, configName string, tokenId int) (token *EntryToken, err error) {
+	var entry *logical.StorageEntry
+	if entry, err = s.Get(ctx, issuedTokenStoragePath(configName, tokenId)); err == nil {
+		if entry == nil {
+			return nil, nil
+		}
+		token = new(EntryToken)
+		_ = entry.DecodeJSON(token)
+	}
+	return token, err
+}
+
+func listIssuedTokens(ctx context.Context, s logical.Storage, configName string) (tokens []*EntryToken, err error) {
+	var ids []string
+	if ids, err = s.List(ctx, fmt.Sprintf("%s/%s/", PathIssuedTokenStorage, configName)); err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		var tokenId int
+		if tokenId, err = strconv.Atoi(id); err != nil {
+			continue
+		}
+		var token *EntryToken
+		if token, err = getIssuedToken(ctx, s, configName, tokenId); err != nil {
+			return nil, err
+		}
+		if token != nil {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens, nil
+}
+
+func saveIssuedToken(ctx context.Context, token EntryToken, s logical.Storage) (err error) {
+	var storageEntry *logical.StorageEntry
+	if storageEntry, err = logical.StorageEntryJSON(issuedTokenStoragePath(token.ConfigName, token.TokenID), token); err == nil {
+		err = s.Put(ctx, storageEntry)
+	}
+	return err
+}
+
+func deleteIssuedToken(ctx context.Context, s logical.Storage, configName string, tokenId int) error {
+	return s.Delete(ctx, issuedTokenStoragePath(configName, tokenId))
+}
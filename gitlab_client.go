@@ -1,11 +1,14 @@
 package gitlab
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,12 +21,16 @@ import (
 var (
 	ErrAccessTokenNotFound = errors.New("access token not found")
 	ErrRoleNotFound        = errors.New("role not found")
+
+	botUsernameRegex = regexp.MustCompile(`^(group|project)_(\d+)_bot`)
 )
 
 type Client interface {
 	GitlabClient(ctx context.Context) *g.Client
 	Valid(ctx context.Context) bool
 	CurrentTokenInfo(ctx context.Context) (*EntryToken, error)
+	TokenInfo(ctx context.Context, token string) (*EntryToken, error)
+	CurrentTokenKind(ctx context.Context) (kind TokenType, parentId string, err error)
 	RotateCurrentToken(ctx context.Context) (newToken *EntryToken, oldToken *EntryToken, err error)
 	CreatePersonalAccessToken(ctx context.Context, username string, userId int, name string, expiresAt time.Time, scopes []string) (*EntryToken, error)
 	CreateGroupAccessToken(ctx context.Context, groupId string, name string, expiresAt time.Time, scopes []string, accessLevel AccessLevel) (*EntryToken, error)
@@ -31,12 +38,25 @@ type Client interface {
 	RevokePersonalAccessToken(ctx context.Context, tokenId int) error
 	RevokeProjectAccessToken(ctx context.Context, tokenId int, projectId string) error
 	RevokeGroupAccessToken(ctx context.Context, tokenId int, groupId string) error
+	GetPersonalAccessToken(ctx context.Context, tokenId int) (*EntryToken, error)
+	GetProjectAccessToken(ctx context.Context, tokenId int, projectId string) (*EntryToken, error)
+	GetGroupAccessToken(ctx context.Context, tokenId int, groupId string) (*EntryToken, error)
 	GetUserIdByUsername(ctx context.Context, username string) (int, error)
 	GetGroupIdByPath(ctx context.Context, path string) (int, error)
+	ResolveProject(ctx context.Context, idOrPath string) (projectId int, fullPath string, err error)
+	ResolveGroup(ctx context.Context, idOrPath string) (groupId int, fullPath string, err error)
+	CurrentUser(ctx context.Context) (userId int, isAdmin bool, err error)
+	GetGroupMemberAccessLevel(ctx context.Context, groupId string, userId int) (AccessLevel, error)
+	GetProjectMemberAccessLevel(ctx context.Context, projectId string, userId int) (AccessLevel, error)
 	CreateGroupServiceAccountAccessToken(ctx context.Context, group string, groupId string, userId int, name string, expiresAt time.Time, scopes []string) (*EntryToken, error)
 	CreateUserServiceAccountAccessToken(ctx context.Context, username string, userId int, name string, expiresAt time.Time, scopes []string) (*EntryToken, error)
 	RevokeUserServiceAccountAccessToken(ctx context.Context, token string) error
 	RevokeGroupServiceAccountAccessToken(ctx context.Context, token string) error
+	CreateServiceAccount(ctx context.Context, groupId string, name string, username string) (userId int, serviceAccountUsername string, err error)
+	DeleteServiceAccount(ctx context.Context, groupId string, userId int) error
+	AddGroupMember(ctx context.Context, groupId string, userId int, accessLevel AccessLevel) error
+	AddProjectMember(ctx context.Context, projectId string, userId int, accessLevel AccessLevel) error
+	RevokeCurrentToken(ctx context.Context) error
 }
 
 type gitlabClient struct {
@@ -55,16 +75,72 @@ func (gc *gitlabClient) GetGroupIdByPath(ctx context.Context, path string) (grou
 		Search: g.Ptr(path),
 	}
 
-	g, _, err := gc.client.Groups.ListGroups(l)
+	groups, _, err := gc.client.Groups.ListGroups(l)
 	if err != nil {
 		return 0, fmt.Errorf("%v", err)
 	}
-	if len(g) == 0 {
+	// the search is fuzzy, so only a group with the exact path is a match
+	var idx = slices.IndexFunc(groups, func(group *g.Group) bool { return group.FullPath == path })
+	if idx == -1 {
 		return 0, fmt.Errorf("path '%s' not found: %w", path, ErrInvalidValue)
 	}
-	groupId = g[0].ID
+	groupId = groups[idx].ID
 	return groupId, nil
+}
+
+func (gc *gitlabClient) ResolveProject(ctx context.Context, idOrPath string) (projectId int, fullPath string, err error) {
+	defer func() {
+		gc.logger.Debug("Resolve project", "idOrPath", idOrPath, "projectId", projectId, "fullPath", fullPath, "error", err)
+	}()
+	var project *g.Project
+	if project, _, err = gc.client.Projects.GetProject(idOrPath, nil, g.WithContext(ctx)); err != nil {
+		return 0, "", err
+	}
+	return project.ID, project.PathWithNamespace, nil
+}
+
+func (gc *gitlabClient) ResolveGroup(ctx context.Context, idOrPath string) (groupId int, fullPath string, err error) {
+	defer func() {
+		gc.logger.Debug("Resolve group", "idOrPath", idOrPath, "groupId", groupId, "fullPath", fullPath, "error", err)
+	}()
+	var group *g.Group
+	if group, _, err = gc.client.Groups.GetGroup(idOrPath, &g.GetGroupOptions{WithProjects: g.Ptr(false)}, g.WithContext(ctx)); err != nil {
+		return 0, "", err
+	}
+	return group.ID, group.FullPath, nil
+}
+
+func (gc *gitlabClient) CurrentUser(ctx context.Context) (userId int, isAdmin bool, err error) {
+	defer func() {
+		gc.logger.Debug("Current user", "userId", userId, "isAdmin", isAdmin, "error", err)
+	}()
+	var user *g.User
+	if user, _, err = gc.client.Users.CurrentUser(g.WithContext(ctx)); err != nil {
+		return 0, false, err
+	}
+	return user.ID, user.IsAdmin, nil
+}
+
+func (gc *gitlabClient) GetGroupMemberAccessLevel(ctx context.Context, groupId string, userId int) (accessLevel AccessLevel, err error) {
+	defer func() {
+		gc.logger.Debug("Get group member access level", "groupId", groupId, "userId", userId, "accessLevel", accessLevel, "error", err)
+	}()
+	var member *g.GroupMember
+	if member, _, err = gc.client.GroupMembers.GetInheritedGroupMember(groupId, userId, g.WithContext(ctx)); err != nil {
+		return AccessLevelUnknown, err
+	}
+	return accessLevelFromValue(member.AccessLevel), nil
+}
 
+func (gc *gitlabClient) GetProjectMemberAccessLevel(ctx context.Context, projectId string, userId int) (accessLevel AccessLevel, err error) {
+	defer func() {
+		gc.logger.Debug("Get project member access level", "projectId", projectId, "userId", userId, "accessLevel", accessLevel, "error", err)
+	}()
+	var member *g.ProjectMember
+	if member, _, err = gc.client.ProjectMembers.GetInheritedProjectMember(projectId, userId, g.WithContext(ctx)); err != nil {
+		return AccessLevelUnknown, err
+	}
+	return accessLevelFromValue(member.AccessLevel), nil
 }
 
 func (gc *gitlabClient) GitlabClient(ctx context.Context) *g.Client {
@@ -169,6 +245,30 @@ func (gc *gitlabClient) CurrentTokenInfo(ctx context.Context) (et *EntryToken, e
 	return et, nil
 }
 
+// TokenInfo returns the information of the token by calling GitLab with the token itself
+func (gc *gitlabClient) TokenInfo(ctx context.Context, token string) (et *EntryToken, err error) {
+	var pat *g.PersonalAccessToken
+	defer func() { gc.logger.Debug("Token info", "token", et, "error", err) }()
+	var config = *gc.config
+	config.Token = token
+	var client *g.Client
+	if client, err = newGitlabClient(&config, gc.httpClient); err != nil {
+		return nil, err
+	}
+	if pat, _, err = client.PersonalAccessTokens.GetSinglePersonalAccessToken(g.WithContext(ctx)); err != nil {
+		return nil, err
+	}
+	et = &EntryToken{
+		TokenID:   pat.ID,
+		UserID:    pat.UserID,
+		Name:      pat.Name,
+		CreatedAt: pat.CreatedAt,
+		ExpiresAt: (*time.Time)(pat.ExpiresAt),
+		Scopes:    pat.Scopes,
+	}
+	return et, nil
+}
+
 func (gc *gitlabClient) RotateCurrentToken(ctx context.Context) (token *EntryToken, currentEntryToken *EntryToken, err error) {
 	var expiresAt time.Time
 	defer func() {
@@ -186,14 +286,16 @@ func (gc *gitlabClient) RotateCurrentToken(ctx context.Context) (token *EntryTok
 		return nil, nil, err
 	}
 
-	var pat *g.PersonalAccessToken
 	var durationTTL = currentEntryToken.ExpiresAt.Sub(*currentEntryToken.CreatedAt)
 	_, expiresAt, _ = calculateGitlabTTL(durationTTL, TimeFromContext(ctx))
-	pat, _, err = gc.client.PersonalAccessTokens.RotatePersonalAccessToken(
-		currentEntryToken.TokenID,
+
+	// the self rotate endpoint works for personal, group and project access tokens alike, rotating
+	// doesn't change the kind of the token so the one detected when the config was written is kept
+	var kind, parentId = cmp.Or(gc.config.TokenKind, TokenTypePersonal), gc.config.TokenParentId
+	var pat *g.PersonalAccessToken
+	pat, _, err = gc.client.PersonalAccessTokens.RotatePersonalAccessTokenSelf(
 		&g.RotatePersonalAccessTokenOptions{ExpiresAt: (*g.ISOTime)(&expiresAt)},
 	)
-
 	if err != nil {
 		return nil, nil, err
 	}
@@ -201,11 +303,11 @@ func (gc *gitlabClient) RotateCurrentToken(ctx context.Context) (token *EntryTok
 	token = &EntryToken{
 		TokenID:     pat.ID,
 		UserID:      pat.UserID,
-		ParentID:    "",
+		ParentID:    parentId,
 		Path:        usr.Username,
 		Name:        pat.Name,
 		Token:       pat.Token,
-		TokenType:   TokenTypePersonal,
+		TokenType:   kind,
 		CreatedAt:   pat.CreatedAt,
 		ExpiresAt:   (*time.Time)(pat.ExpiresAt),
 		Scopes:      pat.Scopes,
@@ -215,6 +317,8 @@ func (gc *gitlabClient) RotateCurrentToken(ctx context.Context) (token *EntryTok
 	gc.config.Token = token.Token
 	gc.config.TokenId = token.TokenID
 	gc.config.Scopes = token.Scopes
+	gc.config.TokenKind = kind
+	gc.config.TokenParentId = parentId
 	if token.CreatedAt != nil {
 		gc.config.TokenCreatedAt = *token.CreatedAt
 	}
@@ -226,6 +330,27 @@ func (gc *gitlabClient) RotateCurrentToken(ctx context.Context) (token *EntryTok
 	return token, currentEntryToken, err
 }
 
+func (gc *gitlabClient) CurrentTokenKind(ctx context.Context) (kind TokenType, parentId string, err error) {
+	var usr *g.User
+	defer func() {
+		gc.logger.Debug("Current token kind", "kind", kind, "parentId", parentId, "error", err)
+	}()
+	if usr, _, err = gc.client.Users.CurrentUser(g.WithContext(ctx)); err != nil {
+		return TokenTypeUnknown, "", err
+	}
+	kind, parentId = tokenKindFromUsername(usr.Username)
+	return kind, parentId, nil
+}
+
+// tokenKindFromUsername detects if the token belongs to the bot user of a group or project access token,
+// GitLab names them group_<id>_bot_<hash> and project_<id>_bot_<hash>.
+func tokenKindFromUsername(username string) (kind TokenType, parentId string) {
+	if m := botUsernameRegex.FindStringSubmatch(username); m != nil {
+		return TokenType(m[1]), m[2]
+	}
+	return TokenTypePersonal, ""
+}
+
 func (gc *gitlabClient) GetUserIdByUsername(ctx context.Context, username string) (userId int, err error) {
 	defer func() {
 		gc.logger.Debug("Get user id by username", "username", username, "userId", userId, "error", err)
@@ -334,6 +459,80 @@ func (gc *gitlabClient) CreateProjectAccessToken(ctx context.Context, projectId
 	}, nil
 }
 
+// CreateServiceAccount creates an instance level service account when groupId is empty, otherwise
+// a service account in the top level group.
+func (gc *gitlabClient) CreateServiceAccount(ctx context.Context, groupId string, name string, username string) (userId int, serviceAccountUsername string, err error) {
+	defer func() {
+		gc.logger.Debug("Create service account", "groupId", groupId, "name", name, "username", username, "userId", userId, "serviceAccountUsername", serviceAccountUsername, "error", err)
+	}()
+	var opts = &g.CreateServiceAccountOptions{}
+	if name != "" {
+		opts.Name = g.Ptr(name)
+	}
+	if username != "" {
+		opts.Username = g.Ptr(username)
+	}
+
+	if groupId == "" {
+		// Users.CreateServiceAccountUser doesn't send the name and username the instance API accepts
+		req, er := gc.client.NewRequest(http.MethodPost, "service_accounts", opts, []g.RequestOptionFunc{g.WithContext(ctx)})
+		if er != nil {
+			return 0, "", er
+		}
+		var usr = new(g.User)
+		if _, err = gc.client.Do(req, usr); err != nil {
+			return 0, "", err
+		}
+		return usr.ID, usr.Username, nil
+	}
+
+	var sa *g.GroupServiceAccount
+	if sa, _, err = gc.client.Groups.CreateServiceAccount(groupId, opts, g.WithContext(ctx)); err != nil {
+		return 0, "", err
+	}
+	return sa.ID, sa.UserName, nil
+}
+
+func (gc *gitlabClient) DeleteServiceAccount(ctx context.Context, groupId string, userId int) (err error) {
+	defer func() {
+		gc.logger.Debug("Delete service account", "groupId", groupId, "userId", userId, "error", err)
+	}()
+	if groupId == "" {
+		_, err = gc.client.Users.DeleteUser(userId, g.WithContext(ctx))
+		return err
+	}
+	_, err = gc.client.Groups.DeleteServiceAccount(groupId, userId, g.WithContext(ctx))
+	return err
+}
+
+func (gc *gitlabClient) AddGroupMember(ctx context.Context, groupId string, userId int, accessLevel AccessLevel) (err error) {
+	defer func() {
+		gc.logger.Debug("Add group member", "groupId", groupId, "userId", userId, "accessLevel", accessLevel, "error", err)
+	}()
+	_, _, err = gc.client.GroupMembers.AddGroupMember(groupId, &g.AddGroupMemberOptions{
+		UserID:      g.Ptr(userId),
+		AccessLevel: g.Ptr(g.AccessLevelValue(accessLevel.Value())),
+	})
+	return err
+}
+
+func (gc *gitlabClient) AddProjectMember(ctx context.Context, projectId string, userId int, accessLevel AccessLevel) (err error) {
+	defer func() {
+		gc.logger.Debug("Add project member", "projectId", projectId, "userId", userId, "accessLevel", accessLevel, "error", err)
+	}()
+	_, _, err = gc.client.ProjectMembers.AddProjectMember(projectId, &g.AddProjectMemberOptions{
+		UserID:      userId,
+		AccessLevel: g.Ptr(g.AccessLevelValue(accessLevel.Value())),
+	})
+	return err
+}
+
+func (gc *gitlabClient) RevokeCurrentToken(ctx context.Context) (err error) {
+	defer func() { gc.logger.Debug("Revoke current token", "error", err) }()
+	_, err = gc.client.PersonalAccessTokens.RevokePersonalAccessTokenSelf()
+	return err
+}
+
 func (gc *gitlabClient) RevokePersonalAccessToken(ctx context.Context, tokenId int) (err error) {
 	defer func() {
 		gc.logger.Debug("Revoke personal access token", "tokenId", tokenId, "error", err)
@@ -379,6 +578,88 @@ func (gc *gitlabClient) RevokeGroupAccessToken(ctx context.Context, tokenId int,
 	return nil
 }
 
+func (gc *gitlabClient) GetPersonalAccessToken(ctx context.Context, tokenId int) (et *EntryToken, err error) {
+	var pat *g.PersonalAccessToken
+	defer func() {
+		gc.logger.Debug("Get personal access token", "tokenId", tokenId, "pat", pat, "error", err)
+	}()
+	var resp *g.Response
+	pat, resp, err = gc.client.PersonalAccessTokens.GetSinglePersonalAccessTokenByID(tokenId)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("personal: %w", ErrAccessTokenNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &EntryToken{
+		TokenID:     pat.ID,
+		UserID:      pat.UserID,
+		Name:        pat.Name,
+		TokenType:   TokenTypePersonal,
+		CreatedAt:   pat.CreatedAt,
+		ExpiresAt:   (*time.Time)(pat.ExpiresAt),
+		Scopes:      pat.Scopes,
+		AccessLevel: AccessLevelUnknown,
+		Revoked:     pat.Revoked,
+	}, nil
+}
+
+func (gc *gitlabClient) GetProjectAccessToken(ctx context.Context, tokenId int, projectId string) (et *EntryToken, err error) {
+	var at *g.ProjectAccessToken
+	defer func() {
+		gc.logger.Debug("Get project access token", "tokenId", tokenId, "projectId", projectId, "pat", at, "error", err)
+	}()
+	var resp *g.Response
+	at, resp, err = gc.client.ProjectAccessTokens.GetProjectAccessToken(projectId, tokenId)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("project: %w", ErrAccessTokenNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &EntryToken{
+		TokenID:     at.ID,
+		UserID:      at.UserID,
+		ParentID:    projectId,
+		Path:        projectId,
+		Name:        at.Name,
+		TokenType:   TokenTypeProject,
+		CreatedAt:   at.CreatedAt,
+		ExpiresAt:   (*time.Time)(at.ExpiresAt),
+		Scopes:      at.Scopes,
+		AccessLevel: accessLevelFromValue(at.AccessLevel),
+		Revoked:     at.Revoked,
+	}, nil
+}
+
+func (gc *gitlabClient) GetGroupAccessToken(ctx context.Context, tokenId int, groupId string) (et *EntryToken, err error) {
+	var at *g.GroupAccessToken
+	defer func() {
+		gc.logger.Debug("Get group access token", "tokenId", tokenId, "groupId", groupId, "gat", at, "error", err)
+	}()
+	var resp *g.Response
+	at, resp, err = gc.client.GroupAccessTokens.GetGroupAccessToken(groupId, tokenId)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("group: %w", ErrAccessTokenNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &EntryToken{
+		TokenID:     at.ID,
+		UserID:      at.UserID,
+		ParentID:    groupId,
+		Path:        groupId,
+		Name:        at.Name,
+		TokenType:   TokenTypeGroup,
+		CreatedAt:   at.CreatedAt,
+		ExpiresAt:   (*time.Time)(at.ExpiresAt),
+		Scopes:      at.Scopes,
+		AccessLevel: accessLevelFromValue(at.AccessLevel),
+		Revoked:     at.Revoked,
+	}, nil
+}
+
 func (gc *gitlabClient) Valid(ctx context.Context) bool {
 	return gc.client != nil && gc.config != nil
 }
@@ -0,0 +1,331 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	g "github.com/xanzy/go-gitlab"
+)
+
+var (
+	ErrGitlabUnavailable = errors.New("gitlab unavailable")
+)
+
+// CircuitBreakerState is the state of the circuit breaker of a config
+type CircuitBreakerState string
+
+const (
+	// CircuitBreakerClosed lets all the requests through
+	CircuitBreakerClosed = CircuitBreakerState("closed")
+	// CircuitBreakerOpen fails all the requests until a probe from the periodic function succeeds
+	CircuitBreakerOpen = CircuitBreakerState("open")
+	// CircuitBreakerHalfOpen lets a single request through to probe GitLab, the others fail until it finished
+	CircuitBreakerHalfOpen = CircuitBreakerState("half-open")
+)
+
+func (i CircuitBreakerState) String() string {
+	return string(i)
+}
+
+// circuitBreaker counts the consecutive transport and 5xx failures of a config, once the threshold is reached it opens
+// and the requests fail fast with ErrGitlabUnavailable instead of waiting for GitLab to time out.
+type circuitBreaker struct {
+	mu        sync.Mutex
+	state     CircuitBreakerState
+	failures  int
+	threshold int
+	openedAt  time.Time
+	lastError string
+	// probing is set while the request that probes GitLab in the half-open state is in flight
+	probing bool
+}
+
+func newCircuitBreaker(threshold int) *circuitBreaker {
+	return &circuitBreaker{state: CircuitBreakerClosed, threshold: threshold}
+}
+
+func (c *circuitBreaker) allow() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.state == CircuitBreakerClosed:
+		return nil
+	case c.state == CircuitBreakerHalfOpen && !c.probing:
+		c.probing = true
+		return nil
+	case c.state == CircuitBreakerHalfOpen:
+		return fmt.Errorf("circuit breaker is half-open and probing gitlab, last error %q: %w", c.lastError, ErrGitlabUnavailable)
+	}
+	return fmt.Errorf("circuit breaker is open since %s, last error %q: %w", c.openedAt.Format(time.RFC3339), c.lastError, ErrGitlabUnavailable)
+}
+
+// record updates the breaker with the result of a request, it returns the new state and if the breaker opened or closed
+func (c *circuitBreaker) record(now time.Time, err error) (state CircuitBreakerState, changed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var prev = c.state
+	c.probing = false
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		// a canceled or timed out request doesn't tell if gitlab is healthy, the next request probes again
+		return c.state, false
+	}
+	if !isGitlabUnavailableError(err) {
+		c.state, c.failures, c.lastError = CircuitBreakerClosed, 0, ""
+	} else {
+		c.failures++
+		c.lastError = err.Error()
+		if c.state == CircuitBreakerHalfOpen || c.failures >= c.threshold {
+			c.state, c.openedAt = CircuitBreakerOpen, now
+		}
+	}
+	return c.state, (prev == CircuitBreakerClosed) != (c.state == CircuitBreakerClosed)
+}
+
+// probe moves an open breaker to half-open so the next request goes through, it returns false if the breaker isn't open
+func (c *circuitBreaker) probe() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state != CircuitBreakerOpen {
+		return false
+	}
+	c.state = CircuitBreakerHalfOpen
+	return true
+}
+
+func (c *circuitBreaker) setThreshold(threshold int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.threshold = threshold
+}
+
+func (c *circuitBreaker) LogicalResponseData() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var openedAt string
+	if !c.openedAt.IsZero() && c.state != CircuitBreakerClosed {
+		openedAt = c.openedAt.Format(time.RFC3339)
+	}
+	return map[string]any{
+		"state":                c.state.String(),
+		"consecutive_failures": c.failures,
+		"threshold":            c.threshold,
+		"opened_at":            openedAt,
+		"last_error":           c.lastError,
+	}
+}
+
+func (c *circuitBreaker) metadata() map[string]string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return map[string]string{
+		"state":                c.state.String(),
+		"consecutive_failures": strconv.Itoa(c.failures),
+		"error":                c.lastError,
+	}
+}
+
+// isGitlabUnavailableError returns true for the errors that mean GitLab can't serve requests, transport errors and 5xx
+func isGitlabUnavailableError(err error) bool {
+	if err == nil || errors.Is(err, ErrGitlabUnavailable) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var errResp *g.ErrorResponse
+	if errors.As(err, &errResp) {
+		return errResp.Response != nil && errResp.Response.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// circuitBreakerClient guards the calls to GitLab of the client with the circuit breaker of the config
+type circuitBreakerClient struct {
+	client   Client
+	breaker  *circuitBreaker
+	onChange func(ctx context.Context, state CircuitBreakerState)
+}
+
+var _ Client = new(circuitBreakerClient)
+
+func guard[T any](ctx context.Context, c *circuitBreakerClient, fn func() (T, error)) (val T, err error) {
+	if err = c.breaker.allow(); err != nil {
+		return val, err
+	}
+	val, err = fn()
+	if state, changed := c.breaker.record(TimeFromContext(ctx), err); changed && c.onChange != nil {
+		c.onChange(ctx, state)
+	}
+	return val, err
+}
+
+func guardErr(ctx context.Context, c *circuitBreakerClient, fn func() error) error {
+	_, err := guard(ctx, c, func() (struct{}, error) { return struct{}{}, fn() })
+	return err
+}
+
+func (c *circuitBreakerClient) GitlabClient(ctx context.Context) *g.Client {
+	return c.client.GitlabClient(ctx)
+}
+
+func (c *circuitBreakerClient) Valid(ctx context.Context) bool {
+	return c.client.Valid(ctx)
+}
+
+func (c *circuitBreakerClient) CurrentTokenInfo(ctx context.Context) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) { return c.client.CurrentTokenInfo(ctx) })
+}
+
+func (c *circuitBreakerClient) TokenInfo(ctx context.Context, token string) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) { return c.client.TokenInfo(ctx, token) })
+}
+
+func (c *circuitBreakerClient) CurrentTokenKind(ctx context.Context) (kind TokenType, parentId string, err error) {
+	err = guardErr(ctx, c, func() (err error) {
+		kind, parentId, err = c.client.CurrentTokenKind(ctx)
+		return err
+	})
+	return kind, parentId, err
+}
+
+func (c *circuitBreakerClient) RotateCurrentToken(ctx context.Context) (newToken *EntryToken, oldToken *EntryToken, err error) {
+	err = guardErr(ctx, c, func() (err error) {
+		newToken, oldToken, err = c.client.RotateCurrentToken(ctx)
+		return err
+	})
+	return newToken, oldToken, err
+}
+
+func (c *circuitBreakerClient) CreatePersonalAccessToken(ctx context.Context, username string, userId int, name string, expiresAt time.Time, scopes []string) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) {
+		return c.client.CreatePersonalAccessToken(ctx, username, userId, name, expiresAt, scopes)
+	})
+}
+
+func (c *circuitBreakerClient) CreateGroupAccessToken(ctx context.Context, groupId string, name string, expiresAt time.Time, scopes []string, accessLevel AccessLevel) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) {
+		return c.client.CreateGroupAccessToken(ctx, groupId, name, expiresAt, scopes, accessLevel)
+	})
+}
+
+func (c *circuitBreakerClient) CreateProjectAccessToken(ctx context.Context, projectId string, name string, expiresAt time.Time, scopes []string, accessLevel AccessLevel) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) {
+		return c.client.CreateProjectAccessToken(ctx, projectId, name, expiresAt, scopes, accessLevel)
+	})
+}
+
+func (c *circuitBreakerClient) RevokePersonalAccessToken(ctx context.Context, tokenId int) error {
+	return guardErr(ctx, c, func() error { return c.client.RevokePersonalAccessToken(ctx, tokenId) })
+}
+
+func (c *circuitBreakerClient) RevokeProjectAccessToken(ctx context.Context, tokenId int, projectId string) error {
+	return guardErr(ctx, c, func() error { return c.client.RevokeProjectAccessToken(ctx, tokenId, projectId) })
+}
+
+func (c *circuitBreakerClient) RevokeGroupAccessToken(ctx context.Context, tokenId int, groupId string) error {
+	return guardErr(ctx, c, func() error { return c.client.RevokeGroupAccessToken(ctx, tokenId, groupId) })
+}
+
+func (c *circuitBreakerClient) GetPersonalAccessToken(ctx context.Context, tokenId int) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) { return c.client.GetPersonalAccessToken(ctx, tokenId) })
+}
+
+func (c *circuitBreakerClient) GetProjectAccessToken(ctx context.Context, tokenId int, projectId string) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) { return c.client.GetProjectAccessToken(ctx, tokenId, projectId) })
+}
+
+func (c *circuitBreakerClient) GetGroupAccessToken(ctx context.Context, tokenId int, groupId string) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) { return c.client.GetGroupAccessToken(ctx, tokenId, groupId) })
+}
+
+func (c *circuitBreakerClient) GetUserIdByUsername(ctx context.Context, username string) (int, error) {
+	return guard(ctx, c, func() (int, error) { return c.client.GetUserIdByUsername(ctx, username) })
+}
+
+func (c *circuitBreakerClient) GetGroupIdByPath(ctx context.Context, path string) (int, error) {
+	return guard(ctx, c, func() (int, error) { return c.client.GetGroupIdByPath(ctx, path) })
+}
+
+func (c *circuitBreakerClient) ResolveProject(ctx context.Context, idOrPath string) (projectId int, fullPath string, err error) {
+	err = guardErr(ctx, c, func() (err error) {
+		projectId, fullPath, err = c.client.ResolveProject(ctx, idOrPath)
+		return err
+	})
+	return projectId, fullPath, err
+}
+
+func (c *circuitBreakerClient) ResolveGroup(ctx context.Context, idOrPath string) (groupId int, fullPath string, err error) {
+	err = guardErr(ctx, c, func() (err error) {
+		groupId, fullPath, err = c.client.ResolveGroup(ctx, idOrPath)
+		return err
+	})
+	return groupId, fullPath, err
+}
+
+func (c *circuitBreakerClient) CurrentUser(ctx context.Context) (userId int, isAdmin bool, err error) {
+	err = guardErr(ctx, c, func() (err error) {
+		userId, isAdmin, err = c.client.CurrentUser(ctx)
+		return err
+	})
+	return userId, isAdmin, err
+}
+
+func (c *circuitBreakerClient) GetGroupMemberAccessLevel(ctx context.Context, groupId string, userId int) (AccessLevel, error) {
+	return guard(ctx, c, func() (AccessLevel, error) {
+		return c.client.GetGroupMemberAccessLevel(ctx, groupId, userId)
+	})
+}
+
+func (c *circuitBreakerClient) GetProjectMemberAccessLevel(ctx context.Context, projectId string, userId int) (AccessLevel, error) {
+	return guard(ctx, c, func() (AccessLevel, error) {
+		return c.client.GetProjectMemberAccessLevel(ctx, projectId, userId)
+	})
+}
+
+func (c *circuitBreakerClient) CreateGroupServiceAccountAccessToken(ctx context.Context, group string, groupId string, userId int, name string, expiresAt time.Time, scopes []string) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) {
+		return c.client.CreateGroupServiceAccountAccessToken(ctx, group, groupId, userId, name, expiresAt, scopes)
+	})
+}
+
+func (c *circuitBreakerClient) CreateUserServiceAccountAccessToken(ctx context.Context, username string, userId int, name string, expiresAt time.Time, scopes []string) (*EntryToken, error) {
+	return guard(ctx, c, func() (*EntryToken, error) {
+		return c.client.CreateUserServiceAccountAccessToken(ctx, username, userId, name, expiresAt, scopes)
+	})
+}
+
+func (c *circuitBreakerClient) RevokeUserServiceAccountAccessToken(ctx context.Context, token string) error {
+	return guardErr(ctx, c, func() error { return c.client.RevokeUserServiceAccountAccessToken(ctx, token) })
+}
+
+func (c *circuitBreakerClient) RevokeGroupServiceAccountAccessToken(ctx context.Context, token string) error {
+	return guardErr(ctx, c, func() error { return c.client.RevokeGroupServiceAccountAccessToken(ctx, token) })
+}
+
+func (c *circuitBreakerClient) CreateServiceAccount(ctx context.Context, groupId string, name string, username string) (userId int, serviceAccountUsername string, err error) {
+	err = guardErr(ctx, c, func() (err error) {
+		userId, serviceAccountUsername, err = c.client.CreateServiceAccount(ctx, groupId, name, username)
+		return err
+	})
+	return userId, serviceAccountUsername, err
+}
+
+func (c *circuitBreakerClient) DeleteServiceAccount(ctx context.Context, groupId string, userId int) error {
+	return guardErr(ctx, c, func() error { return c.client.DeleteServiceAccount(ctx, groupId, userId) })
+}
+
+func (c *circuitBreakerClient) AddGroupMember(ctx context.Context, groupId string, userId int, accessLevel AccessLevel) error {
+	return guardErr(ctx, c, func() error { return c.client.AddGroupMember(ctx, groupId, userId, accessLevel) })
+}
+
+func (c *circuitBreakerClient) AddProjectMember(ctx context.Context, projectId string, userId int, accessLevel AccessLevel) error {
+	return guardErr(ctx, c, func() error { return c.client.AddProjectMember(ctx, projectId, userId, accessLevel) })
+}
+
+func (c *circuitBreakerClient) RevokeCurrentToken(ctx context.Context) error {
+	return guardErr(ctx, c, func() error { return c.client.RevokeCurrentToken(ctx) })
+}
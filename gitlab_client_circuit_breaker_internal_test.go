@@ -0,0 +1,69 @@
+//go:build !integration
+
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	var now = time.Now()
+	var unavailable = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	var breaker = newCircuitBreaker(1)
+
+	require.NoError(t, breaker.allow())
+	state, changed := breaker.record(now, unavailable)
+	require.EqualValues(t, CircuitBreakerOpen, state)
+	require.True(t, changed)
+	require.ErrorIs(t, breaker.allow(), ErrGitlabUnavailable)
+
+	// only a single request probes gitlab while the breaker is half-open
+	require.True(t, breaker.probe())
+	require.NoError(t, breaker.allow())
+	require.ErrorIs(t, breaker.allow(), ErrGitlabUnavailable)
+
+	// a failed probe opens the breaker again
+	state, _ = breaker.record(now, unavailable)
+	require.EqualValues(t, CircuitBreakerOpen, state)
+	require.ErrorIs(t, breaker.allow(), ErrGitlabUnavailable)
+
+	require.True(t, breaker.probe())
+	require.NoError(t, breaker.allow())
+	state, changed = breaker.record(now, nil)
+	require.EqualValues(t, CircuitBreakerClosed, state)
+	require.True(t, changed)
+	require.NoError(t, breaker.allow())
+	require.NoError(t, breaker.allow())
+}
+
+func TestCircuitBreakerCanceledProbe(t *testing.T) {
+	var now = time.Now()
+	var breaker = newCircuitBreaker(1)
+	breaker.record(now, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+
+	for _, err := range []error{context.Canceled, context.DeadlineExceeded} {
+		// a canceled probe keeps the breaker half-open, and lets the next request probe
+		require.True(t, breaker.probe())
+		require.NoError(t, breaker.allow())
+		state, changed := breaker.record(now, err)
+		require.EqualValues(t, CircuitBreakerHalfOpen, state)
+		require.False(t, changed)
+		require.NoError(t, breaker.allow())
+		require.ErrorIs(t, breaker.allow(), ErrGitlabUnavailable)
+
+		state, _ = breaker.record(now, &net.OpError{Op: "dial", Err: errors.New("connection refused")})
+		require.EqualValues(t, CircuitBreakerOpen, state)
+	}
+
+	// a canceled request doesn't close an open breaker
+	state, changed := breaker.record(now, context.Canceled)
+	require.EqualValues(t, CircuitBreakerOpen, state)
+	require.False(t, changed)
+	require.ErrorIs(t, breaker.allow(), ErrGitlabUnavailable)
+}
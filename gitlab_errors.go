@@ -0,0 +1,98 @@
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	g "github.com/xanzy/go-gitlab"
+)
+
+var (
+	ErrGitlabForbidden   = errors.New("gitlab forbidden")
+	ErrGitlabNotFound    = errors.New("gitlab not found")
+	ErrGitlabValidation  = errors.New("gitlab validation failed")
+	ErrGitlabRateLimited = errors.New("gitlab rate limited")
+)
+
+// GitlabError is a GitLab API error mapped to one of the sentinel errors, it carries the HTTP status that Vault
+// responds with, so the caller gets a 4xx with an actionable message instead of an opaque 500.
+type GitlabError struct {
+	Err     error
+	Status  int
+	Message string
+	cause   error
+}
+
+var _ logical.HTTPCodedError = new(GitlabError)
+
+func (e *GitlabError) Error() string {
+	return e.Message
+}
+
+func (e *GitlabError) Code() int {
+	return e.Status
+}
+
+func (e *GitlabError) Unwrap() []error {
+	return []error{e.Err, e.cause}
+}
+
+// mapGitlabError maps the error of a GitLab API call to a GitlabError, the hint explains what the config token needs
+// to be able to do the action. Errors that don't come from a GitLab response are returned as they are.
+func mapGitlabError(err error, action string, hint string) error {
+	if err == nil {
+		return nil
+	}
+
+	var gitlabErr *GitlabError
+	if errors.As(err, &gitlabErr) {
+		return err
+	}
+
+	if errors.Is(err, ErrGitlabUnavailable) {
+		return &GitlabError{Err: ErrGitlabUnavailable, Status: http.StatusServiceUnavailable, Message: fmt.Sprintf("%s: %s", action, err), cause: err}
+	}
+
+	if errors.Is(err, g.ErrNotFound) || errors.Is(err, ErrAccessTokenNotFound) {
+		return &GitlabError{Err: ErrGitlabNotFound, Status: http.StatusNotFound, Message: fmt.Sprintf("%s: not found, or the config token can't see it", action), cause: err}
+	}
+
+	var errResp *g.ErrorResponse
+	if !errors.As(err, &errResp) || errResp.Response == nil {
+		return err
+	}
+
+	switch errResp.Response.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &GitlabError{Err: ErrGitlabForbidden, Status: http.StatusForbidden, Message: fmt.Sprintf("%s: %s, gitlab responded with %s", action, hint, errResp.Message), cause: err}
+	case http.StatusNotFound:
+		return &GitlabError{Err: ErrGitlabNotFound, Status: http.StatusNotFound, Message: fmt.Sprintf("%s: not found, or the config token can't see it, gitlab responded with %s", action, errResp.Message), cause: err}
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return &GitlabError{Err: ErrGitlabValidation, Status: http.StatusBadRequest, Message: fmt.Sprintf("%s: gitlab rejected the request: %s", action, errResp.Message), cause: err}
+	case http.StatusTooManyRequests:
+		var message = fmt.Sprintf("%s: gitlab is rate limiting the config token", action)
+		if retryAfter := errResp.Response.Header.Get("Retry-After"); retryAfter != "" {
+			message = fmt.Sprintf("%s, retry after %s seconds", message, retryAfter)
+		}
+		return &GitlabError{Err: ErrGitlabRateLimited, Status: http.StatusTooManyRequests, Message: message, cause: err}
+	}
+
+	return err
+}
+
+// tokenPermissionHint describes the permission the config token needs to create or revoke tokens of the token type
+func tokenPermissionHint(tokenType TokenType, path string) string {
+	switch tokenType {
+	case TokenTypeGroup:
+		return fmt.Sprintf("config token lacks Owner on group %s", path)
+	case TokenTypeProject:
+		return fmt.Sprintf("config token lacks Maintainer on project %s", path)
+	case TokenTypeGroupServiceAccount:
+		return fmt.Sprintf("config token lacks Owner on the group of the service account %s", path)
+	case TokenTypePersonal, TokenTypeUserServiceAccount:
+		return fmt.Sprintf("config token needs to be an admin token to manage tokens of user %s", path)
+	}
+	return "config token lacks the permissions"
+}
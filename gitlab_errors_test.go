@@ -0,0 +1,90 @@
+package gitlab_test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestGitlabErrorMapping(t *testing.T) {
+	var tests = []struct {
+		name     string
+		err      error
+		expected error
+		status   int
+		message  string
+	}{
+		{
+			name:     "forbidden",
+			err:      newGitlabErrorResponse(http.StatusForbidden, "403 Forbidden"),
+			expected: gitlab.ErrGitlabForbidden,
+			status:   http.StatusForbidden,
+			message:  "create project for example/example: config token lacks Maintainer on project example/example",
+		},
+		{
+			name:     "not found",
+			err:      newGitlabErrorResponse(http.StatusNotFound, "404 Project Not Found"),
+			expected: gitlab.ErrGitlabNotFound,
+			status:   http.StatusNotFound,
+			message:  "not found, or the config token can't see it",
+		},
+		{
+			name:     "validation",
+			err:      newGitlabErrorResponse(http.StatusUnprocessableEntity, "{expires_at: [must be before 2025-01-01]}"),
+			expected: gitlab.ErrGitlabValidation,
+			status:   http.StatusBadRequest,
+			message:  "gitlab rejected the request: {expires_at: [must be before 2025-01-01]}",
+		},
+		{
+			name:     "rate limited",
+			err:      newGitlabErrorResponse(http.StatusTooManyRequests, "429 Too Many Requests"),
+			expected: gitlab.ErrGitlabRateLimited,
+			status:   http.StatusTooManyRequests,
+			message:  "retry after 30 seconds",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var client = newInMemoryClient(true)
+			ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+			b, l, err := getBackendWithConfig(ctx, map[string]any{
+				"token":    "glpat-secret-random-token",
+				"base_url": "http://localhost:8080/",
+				"type":     gitlab.TypeSelfManaged.String(),
+			})
+			require.NoError(t, err)
+
+			resp, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+				Data: map[string]any{
+					"path":         "example/example",
+					"name":         "project",
+					"token_type":   gitlab.TokenTypeProject.String(),
+					"access_level": gitlab.AccessLevelGuestPermissions.String(),
+					"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+					"ttl":          "1h",
+				},
+			})
+			require.NoError(t, err)
+			require.NoError(t, resp.Error())
+
+			client.gitlabError = tt.err
+			_, err = b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+			})
+			require.ErrorIs(t, err, tt.expected)
+			require.ErrorContains(t, err, tt.message)
+			var codedErr logical.HTTPCodedError
+			require.ErrorAs(t, err, &codedErr)
+			require.EqualValues(t, tt.status, codedErr.Code())
+		})
+	}
+}
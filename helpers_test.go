@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
@@ -86,10 +89,14 @@ func (m *mockEventsSender) expectEvents(t *testing.T, expectedEvents []expectedE
 }
 
 func getBackendWithEvents(ctx context.Context) (*gitlab.Backend, logical.Storage, *mockEventsSender, error) {
+	return getBackendWithSystemView(ctx, &logical.StaticSystemView{})
+}
+
+func getBackendWithSystemView(ctx context.Context, system logical.SystemView) (*gitlab.Backend, logical.Storage, *mockEventsSender, error) {
 	events := &mockEventsSender{}
 	config := &logical.BackendConfig{
 		Logger:       logging.NewVaultLoggerWithWriter(io.Discard, log.NoLevel),
-		System:       &logical.StaticSystemView{},
+		System:       system,
 		StorageView:  &logical.InmemStorage{},
 		BackendUUID:  "test",
 		EventsSender: events,
@@ -147,12 +154,31 @@ func newInMemoryClient(valid bool) *inMemoryClient {
 	}
 }
 
+var errUnavailable = &url.Error{Op: "Get", URL: "http://localhost:8080/api/v4", Err: errors.New("connection refused")}
+
+func newGitlabErrorResponse(statusCode int, message string) *g.ErrorResponse {
+	return &g.ErrorResponse{
+		Message: message,
+		Response: &http.Response{
+			StatusCode: statusCode,
+			Header:     http.Header{"Retry-After": []string{"30"}},
+			Request:    &http.Request{Method: http.MethodPost, URL: &url.URL{Scheme: "http", Host: "localhost:8080", Path: "/api/v4/projects"}},
+		},
+	}
+}
+
 type inMemoryClient struct {
 	internalCounter int
 	users           []string
 	groups          []string
 	muLock          sync.Mutex
 	valid           bool
+	unavailable     bool
+	gitlabError     error
+	fullPaths       map[int]string
+	admin           bool
+	tokenInfoError  error
+	memberAccess    map[string]gitlab.AccessLevel
 
 	personalAccessTokenRevokeError                    bool
 	groupAccessTokenRevokeError                       bool
@@ -164,15 +190,26 @@ type inMemoryClient struct {
 	revokeGroupServiceAccountPersonalAccessTokenError bool
 	createUserServiceAccountAccessTokenError          bool
 	createGroupServiceAccountAccessTokenError         bool
+	rotateMainTokenError                              bool
+	createServiceAccountError                         bool
+	addMemberError                                    bool
 
-	calledMainToken       int
-	calledRotateMainToken int
-	calledValid           int
+	calledMainToken            int
+	calledRotateMainToken      int
+	calledValid                int
+	calledRevokeCurrentToken   int
+	calledDeleteServiceAccount int
 
-	mainTokenInfo   gitlab.EntryToken
-	rotateMainToken gitlab.EntryToken
+	mainTokenInfo     gitlab.EntryToken
+	mainTokenKind     gitlab.TokenType
+	mainTokenParentId string
+	rotateMainToken   gitlab.EntryToken
 
 	accessTokens map[string]gitlab.EntryToken
+	members      []string
+
+	// resolveHook is called before a group or project is resolved
+	resolveHook func()
 }
 
 func (i *inMemoryClient) GetGroupIdByPath(ctx context.Context, path string) (int, error) {
@@ -184,6 +221,42 @@ func (i *inMemoryClient) GetGroupIdByPath(ctx context.Context, path string) (int
 	return idx, nil
 }
 
+func (i *inMemoryClient) resolve(idOrPath string) (int, string, error) {
+	if i.resolveHook != nil {
+		i.resolveHook()
+	}
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if i.unavailable {
+		return 0, "", errUnavailable
+	}
+	if i.fullPaths == nil {
+		i.fullPaths = make(map[int]string)
+	}
+	if id, err := strconv.Atoi(idOrPath); err == nil {
+		if fullPath, ok := i.fullPaths[id]; ok {
+			return id, fullPath, nil
+		}
+		return 0, "", g.ErrNotFound
+	}
+	for id, fullPath := range i.fullPaths {
+		if fullPath == idOrPath {
+			return id, fullPath, nil
+		}
+	}
+	var id = 1000 + len(i.fullPaths)
+	i.fullPaths[id] = idOrPath
+	return id, idOrPath, nil
+}
+
+func (i *inMemoryClient) ResolveProject(ctx context.Context, idOrPath string) (int, string, error) {
+	return i.resolve(idOrPath)
+}
+
+func (i *inMemoryClient) ResolveGroup(ctx context.Context, idOrPath string) (int, string, error) {
+	return i.resolve(idOrPath)
+}
+
 func (i *inMemoryClient) GitlabClient(ctx context.Context) *g.Client {
 	return nil
 }
@@ -194,7 +267,22 @@ func (i *inMemoryClient) CreateGroupServiceAccountAccessToken(ctx context.Contex
 	if i.createGroupServiceAccountAccessTokenError {
 		return nil, fmt.Errorf("CreateGroupServiceAccountAccessToken")
 	}
-	return nil, nil
+	i.internalCounter++
+	var tokenId = i.internalCounter
+	var entryToken = gitlab.EntryToken{
+		TokenID:   tokenId,
+		UserID:    userId,
+		ParentID:  groupId,
+		Path:      path,
+		Name:      name,
+		Token:     "",
+		TokenType: gitlab.TokenTypeGroupServiceAccount,
+		CreatedAt: g.Ptr(time.Now()),
+		ExpiresAt: &expiresAt,
+		Scopes:    scopes,
+	}
+	i.accessTokens[fmt.Sprintf("%s_%v", gitlab.TokenTypeGroupServiceAccount.String(), tokenId)] = entryToken
+	return &entryToken, nil
 }
 
 func (i *inMemoryClient) CreateUserServiceAccountAccessToken(ctx context.Context, username string, userId int, name string, expiresAt time.Time, scopes []string) (*gitlab.EntryToken, error) {
@@ -231,14 +319,81 @@ func (i *inMemoryClient) CurrentTokenInfo(ctx context.Context) (*gitlab.EntryTok
 	i.muLock.Lock()
 	defer i.muLock.Unlock()
 	i.calledMainToken++
+	if i.unavailable {
+		return nil, errUnavailable
+	}
 	return &i.mainTokenInfo, nil
 }
 
+func (i *inMemoryClient) TokenInfo(ctx context.Context, token string) (*gitlab.EntryToken, error) {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if i.tokenInfoError != nil {
+		return nil, i.tokenInfoError
+	}
+	return &gitlab.EntryToken{Token: token}, nil
+}
+
+func (i *inMemoryClient) CurrentTokenKind(ctx context.Context) (gitlab.TokenType, string, error) {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	return cmp.Or(i.mainTokenKind, gitlab.TokenTypePersonal), i.mainTokenParentId, nil
+}
+
+func (i *inMemoryClient) CreateServiceAccount(ctx context.Context, groupId string, name string, username string) (int, string, error) {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if i.createServiceAccountError {
+		return 0, "", fmt.Errorf("CreateServiceAccount")
+	}
+	i.internalCounter++
+	username = cmp.Or(username, fmt.Sprintf("service_account_%d", i.internalCounter))
+	i.users = append(i.users, username)
+	return i.internalCounter, username, nil
+}
+
+func (i *inMemoryClient) DeleteServiceAccount(ctx context.Context, groupId string, userId int) error {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	i.calledDeleteServiceAccount++
+	return nil
+}
+
+func (i *inMemoryClient) AddGroupMember(ctx context.Context, groupId string, userId int, accessLevel gitlab.AccessLevel) error {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	i.members = append(i.members, fmt.Sprintf("group_%s_%d_%s", groupId, userId, accessLevel))
+	return nil
+}
+
+func (i *inMemoryClient) AddProjectMember(ctx context.Context, projectId string, userId int, accessLevel gitlab.AccessLevel) error {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if i.addMemberError {
+		return fmt.Errorf("AddProjectMember")
+	}
+	i.members = append(i.members, fmt.Sprintf("project_%s_%d_%s", projectId, userId, accessLevel))
+	return nil
+}
+
+func (i *inMemoryClient) RevokeCurrentToken(ctx context.Context) error {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	i.calledRevokeCurrentToken++
+	return nil
+}
+
 func (i *inMemoryClient) RotateCurrentToken(ctx context.Context) (*gitlab.EntryToken, *gitlab.EntryToken, error) {
 	i.muLock.Lock()
 	defer i.muLock.Unlock()
 	i.calledRotateMainToken++
-	return &i.rotateMainToken, &i.mainTokenInfo, nil
+	if i.rotateMainTokenError {
+		return nil, nil, fmt.Errorf("RotateCurrentToken")
+	}
+	// like in GitLab the rotated token becomes the current token
+	var oldToken, newToken = i.mainTokenInfo, i.rotateMainToken
+	i.mainTokenInfo = newToken
+	return &newToken, &oldToken, nil
 }
 
 func (i *inMemoryClient) Valid(ctx context.Context) bool {
@@ -288,7 +443,7 @@ func (i *inMemoryClient) CreateGroupAccessToken(ctx context.Context, groupId str
 		Name:        name,
 		Token:       "",
 		TokenType:   gitlab.TokenTypeGroup,
-		CreatedAt:   g.Ptr(time.Now()),
+		CreatedAt:   g.Ptr(gitlab.TimeFromContext(ctx)),
 		ExpiresAt:   &expiresAt,
 		Scopes:      scopes,
 		AccessLevel: accessLevel,
@@ -300,6 +455,12 @@ func (i *inMemoryClient) CreateGroupAccessToken(ctx context.Context, groupId str
 func (i *inMemoryClient) CreateProjectAccessToken(ctx context.Context, projectId string, name string, expiresAt time.Time, scopes []string, accessLevel gitlab.AccessLevel) (*gitlab.EntryToken, error) {
 	i.muLock.Lock()
 	defer i.muLock.Unlock()
+	if i.unavailable {
+		return nil, errUnavailable
+	}
+	if i.gitlabError != nil {
+		return nil, i.gitlabError
+	}
 	if i.projectAccessTokenCreateError {
 		return nil, fmt.Errorf("CreateProjectAccessToken")
 	}
@@ -313,7 +474,7 @@ func (i *inMemoryClient) CreateProjectAccessToken(ctx context.Context, projectId
 		Name:        name,
 		Token:       "",
 		TokenType:   gitlab.TokenTypeProject,
-		CreatedAt:   g.Ptr(time.Now()),
+		CreatedAt:   g.Ptr(gitlab.TimeFromContext(ctx)),
 		ExpiresAt:   &expiresAt,
 		Scopes:      scopes,
 		AccessLevel: accessLevel,
@@ -352,6 +513,27 @@ func (i *inMemoryClient) RevokeGroupAccessToken(ctx context.Context, tokenId int
 	return nil
 }
 
+func (i *inMemoryClient) getAccessToken(tokenType gitlab.TokenType, tokenId int) (*gitlab.EntryToken, error) {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if token, ok := i.accessTokens[fmt.Sprintf("%s_%v", tokenType.String(), tokenId)]; ok {
+		return &token, nil
+	}
+	return nil, fmt.Errorf("%s: %w", tokenType.String(), gitlab.ErrAccessTokenNotFound)
+}
+
+func (i *inMemoryClient) GetPersonalAccessToken(ctx context.Context, tokenId int) (*gitlab.EntryToken, error) {
+	return i.getAccessToken(gitlab.TokenTypePersonal, tokenId)
+}
+
+func (i *inMemoryClient) GetProjectAccessToken(ctx context.Context, tokenId int, projectId string) (*gitlab.EntryToken, error) {
+	return i.getAccessToken(gitlab.TokenTypeProject, tokenId)
+}
+
+func (i *inMemoryClient) GetGroupAccessToken(ctx context.Context, tokenId int, groupId string) (*gitlab.EntryToken, error) {
+	return i.getAccessToken(gitlab.TokenTypeGroup, tokenId)
+}
+
 func (i *inMemoryClient) GetUserIdByUsername(ctx context.Context, username string) (int, error) {
 	idx := slices.Index(i.users, username)
 	if idx == -1 {
@@ -361,6 +543,39 @@ func (i *inMemoryClient) GetUserIdByUsername(ctx context.Context, username strin
 	return idx, nil
 }
 
+func (i *inMemoryClient) CurrentUser(ctx context.Context) (int, bool, error) {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if i.unavailable {
+		return 0, false, errUnavailable
+	}
+	return i.mainTokenInfo.UserID, i.admin, nil
+}
+
+// memberAccessLevel looks up the access level of the config token on a group or project, by id or full path
+func (i *inMemoryClient) memberAccessLevel(idOrPath string) (gitlab.AccessLevel, error) {
+	i.muLock.Lock()
+	defer i.muLock.Unlock()
+	if i.unavailable {
+		return gitlab.AccessLevelUnknown, errUnavailable
+	}
+	if id, err := strconv.Atoi(idOrPath); err == nil && i.fullPaths[id] != "" {
+		idOrPath = i.fullPaths[id]
+	}
+	if accessLevel, ok := i.memberAccess[idOrPath]; ok {
+		return accessLevel, nil
+	}
+	return gitlab.AccessLevelUnknown, g.ErrNotFound
+}
+
+func (i *inMemoryClient) GetGroupMemberAccessLevel(ctx context.Context, groupId string, userId int) (gitlab.AccessLevel, error) {
+	return i.memberAccessLevel(groupId)
+}
+
+func (i *inMemoryClient) GetProjectMemberAccessLevel(ctx context.Context, projectId string, userId int) (gitlab.AccessLevel, error) {
+	return i.memberAccessLevel(projectId)
+}
+
 var _ gitlab.Client = new(inMemoryClient)
 
 func sanitizePath(path string) string {
@@ -1,14 +1,29 @@
 package gitlab
 
 import (
+	"cmp"
 	"crypto/rand"
+	"crypto/sha256"
 	"fmt"
+	"regexp"
 	"strings"
 	"text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 	_ "unsafe"
 )
 
+// TokenNameData is the information of the request that is available to the name template, next to the fields of the role
+type TokenNameData struct {
+	EntityID       string
+	EntityName     string
+	EntityMetadata map[string]string
+	RequestID      string
+	MountPath      string
+	ConfigName     string
+}
+
 func yesNoBool(in bool) string {
 	if in {
 		return "yes"
@@ -25,17 +40,100 @@ func timeNowFormat(layout string) string {
 	return time.Now().UTC().Format(layout)
 }
 
+// truncate shortens the value to at most length characters
+func truncate(length int, value string) string {
+	if utf8.RuneCountInString(value) <= length {
+		return value
+	}
+	return string([]rune(value)[:max(length, 0)])
+}
+
+// replace replaces all occurrences of old with new, the value is last so it can be used in a pipeline
+func replace(old, new, value string) string {
+	return strings.ReplaceAll(value, old, new)
+}
+
+var (
+	slugRegex     = regexp.MustCompile(`[^a-z0-9]+`)
+	sanitizeRegex = regexp.MustCompile(`[^\pL\pN _.:@-]+`)
+)
+
+// slug lowercases the value and replaces everything that isn't a letter or a digit with a dash
+func slug(value string) string {
+	return strings.Trim(slugRegex.ReplaceAllString(strings.ToLower(value), "-"), "-")
+}
+
+// sanitize replaces the characters that don't belong in a token name with an underscore
+func sanitize(value string) string {
+	return sanitizeRegex.ReplaceAllString(value, "_")
+}
+
+// sha256Short returns the first 8 characters of the hex encoded sha256 of the value
+func sha256Short(value string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(value)))[:8]
+}
+
+func timeNow() time.Time {
+	return time.Now().UTC()
+}
+
+// timeAdd adds the duration to the time, next to the units of time.ParseDuration it accepts days like 7d
+func timeAdd(duration string, t time.Time) (time.Time, error) {
+	if days, ok := strings.CutSuffix(duration, "d"); ok {
+		var d, err = time.ParseDuration(days + "h")
+		return t.Add(d * 24), err
+	}
+	var d, err = time.ParseDuration(duration)
+	return t.Add(d), err
+}
+
+func timeFormat(layout string, t time.Time) string {
+	return t.Format(layout)
+}
+
 var tplFuncMap = template.FuncMap{
 	"randHexString": randHexString,
 	"stringsJoin":   strings.Join,
 	"yesNoBool":     yesNoBool,
 	"timeNowFormat": timeNowFormat,
+	"truncate":      truncate,
+	"lower":         strings.ToLower,
+	"upper":         strings.ToUpper,
+	"replace":       replace,
+	"slug":          slug,
+	"sanitize":      sanitize,
+	"sha256Short":   sha256Short,
+	"timeNow":       timeNow,
+	"timeAdd":       timeAdd,
+	"timeFormat":    timeFormat,
+}
+
+// validateTokenName checks the rendered name against the limits GitLab has for the name of a token
+func validateTokenName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("token name is empty: %w", ErrInvalidValue)
+	}
+	if length := utf8.RuneCountInString(name); length > DefaultTokenNameMaxLength {
+		return fmt.Errorf("token name is %d characters long [length <= %d]: %w", length, DefaultTokenNameMaxLength, ErrInvalidValue)
+	}
+	if strings.IndexFunc(name, unicode.IsControl) != -1 {
+		return fmt.Errorf("token name %q contains control characters: %w", name, ErrInvalidValue)
+	}
+	return nil
 }
 
 func TokenName(role *EntryRole) (name string, err error) {
+	return TokenNameWithData(role, TokenNameData{})
+}
+
+// TokenNameWithData renders the name template of the role with the fields of the role and the request information
+func TokenNameWithData(role *EntryRole, nameData TokenNameData) (name string, err error) {
 	if role == nil {
 		return "", fmt.Errorf("role: %w", ErrNilValue)
 	}
+	if nameData.EntityMetadata == nil {
+		nameData.EntityMetadata = make(map[string]string)
+	}
 	var tpl *template.Template
 	tpl, err = template.New("name").Funcs(tplFuncMap).Parse(role.Name)
 	if err != nil {
@@ -44,8 +142,16 @@ func TokenName(role *EntryRole) (name string, err error) {
 	buf := new(strings.Builder)
 	var data = role.LogicalResponseData()
 	data["unix_timestamp_utc"] = time.Now().UTC().Unix()
+	data["entity_id"] = nameData.EntityID
+	data["entity_name"] = nameData.EntityName
+	data["entity_metadata"] = nameData.EntityMetadata
+	data["request_id"] = nameData.RequestID
+	data["mount_path"] = nameData.MountPath
+	data["config_name"] = cmp.Or(nameData.ConfigName, role.ConfigName, DefaultConfigName)
 	delete(data, "name")
-	err = tpl.Execute(buf, data)
+	if err = tpl.Execute(buf, data); err != nil {
+		return "", err
+	}
 	name = buf.String()
-	return name, err
+	return name, validateTokenName(name)
 }
@@ -0,0 +1,109 @@
+package gitlab_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	g "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestTokenNameGenerator_Functions(t *testing.T) {
+	var tests = []struct {
+		name   string
+		outVal string
+		outErr bool
+	}{
+		{`{{ "a-very-long-role-name" | truncate 6 }}`, "a-very", false},
+		{`{{ .role_name | upper }}-{{ "ABC" | lower }}`, "TEST-abc", false},
+		{`{{ .path | replace "/" "-" }}`, "group-project", false},
+		{`{{ "Jane Doe / Team@ACME!" | slug }}`, "jane-doe-team-acme", false},
+		{`{{ "jane doe/team!" | sanitize }}`, "jane doe_team_", false},
+		{`{{ "value" | sha256Short }}`, "cd42404d", false},
+		{`{{ timeNow | timeAdd "7d" | timeFormat "2006" }}`, time.Now().UTC().Add(7 * 24 * time.Hour).Format("2006"), false},
+		{`{{ timeNow | timeAdd "invalid" }}`, "", true},
+		{`{{ .config_name }}`, g.DefaultConfigName, false},
+		{`{{ .entity_name }}`, "", true},
+		{strings.Repeat("a", g.DefaultTokenNameMaxLength+1), "", true},
+		{"{{ .role_name }}\n", "", true},
+	}
+
+	for _, tst := range tests {
+		t.Run(tst.name, func(t *testing.T) {
+			val, err := g.TokenName(&g.EntryRole{
+				RoleName:  "test",
+				TTL:       time.Hour,
+				Path:      "group/project",
+				Name:      tst.name,
+				TokenType: g.TokenTypePersonal,
+			})
+			if tst.outErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tst.outVal, val)
+		})
+	}
+}
+
+func TestTokenNameGenerator_RequestData(t *testing.T) {
+	val, err := g.TokenNameWithData(&g.EntryRole{
+		RoleName:   "test",
+		TTL:        time.Hour,
+		Path:       "group/project",
+		Name:       "{{ .entity_name | slug }}-{{ .entity_metadata.team }}-{{ .entity_id }}-{{ .request_id }}-{{ .mount_path }}-{{ .config_name }}",
+		TokenType:  g.TokenTypeProject,
+		ConfigName: "other",
+	}, g.TokenNameData{
+		EntityID:       "entity",
+		EntityName:     "Jane Doe",
+		EntityMetadata: map[string]string{"team": "platform"},
+		RequestID:      "request",
+		MountPath:      "gitlab/",
+		ConfigName:     "other",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "jane-doe-platform-entity-request-gitlab/-other", val)
+
+	t.Run("token name has the requesting entity", func(t *testing.T) {
+		var client = newInMemoryClient(true)
+		ctx := g.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, _, err := getBackendWithSystemView(ctx, &logical.StaticSystemView{
+			EntityVal: &logical.Entity{ID: "entity", Name: "Jane Doe", Metadata: map[string]string{"team": "platform"}},
+		})
+		require.NoError(t, err)
+		require.NoError(t, writeBackendConfigWithName(ctx, b, l, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     g.TypeSelfManaged.String(),
+		}, ""))
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", g.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         "{{ .role_name }}-{{ .entity_name | slug }}-{{ .entity_metadata.team }}",
+				"token_type":   g.TokenTypeProject.String(),
+				"access_level": g.AccessLevelGuestPermissions.String(),
+				"ttl":          "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", g.PathTokenRoleStorage), Storage: l,
+			EntityID: "entity",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Equal(t, "test-jane-doe-platform", resp.Data["name"])
+	})
+}
@@ -64,6 +64,113 @@ var (
 				Name: "Auto Rotate Before",
 			},
 		},
+		"webhook_secret": {
+			Type:        framework.TypeString,
+			Description: `The secret token GitLab sends in the X-Gitlab-Token header when delivering system hooks and group webhooks to the webhook endpoint. Webhook deliveries are rejected while this is not set.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Webhook Secret",
+				Sensitive: true,
+			},
+		},
+		"reconcile_interval": {
+			Type:        framework.TypeDurationSecond,
+			Description: `How often the plugin should check the tokens it issued against GitLab and report the ones that were revoked, expired early or had their scopes changed. Set to 0 to disable the periodic reconciliation.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Reconcile Interval",
+			},
+		},
+		"reconcile_resolve_stale": {
+			Type:        framework.TypeBool,
+			Description: `Revoke the stale tokens found by the periodic reconciliation that are still active in GitLab, and mark every stale token as revoked. The leases stay active until they expire or are revoked in Vault.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Reconcile Resolve Stale",
+			},
+		},
+		"rotation_schedule": {
+			Type:        framework.TypeString,
+			Description: `A cron expression (standard 5 field syntax, evaluated in UTC) of when the config token should be rotated, for example "0 2 * * 2" for every Tuesday at 02:00. When set the token is only rotated at the scheduled times. Mutually exclusive with rotation_period.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rotation Schedule",
+			},
+		},
+		"rotation_window": {
+			Type:        framework.TypeDurationSecond,
+			Description: `How long after the scheduled time the rotation is still allowed to start. If the rotation cannot happen within the window it is postponed to the next scheduled time. Requires rotation_schedule, 0 means the rotation can start any time after the scheduled time.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rotation Window",
+			},
+		},
+		"rotation_period": {
+			Type:        framework.TypeDurationSecond,
+			Description: `Rotate the config token after it has been in use for this long, regardless of its expiry. Mutually exclusive with rotation_schedule.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Rotation Period",
+			},
+		},
+		"personal_token": {
+			Type:        framework.TypeString,
+			Description: `Optional token used instead of the config token to create personal and user service account tokens, it requires admin rights. Set to an empty value to remove it.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Personal Token",
+				Sensitive: true,
+			},
+		},
+		"group_token": {
+			Type:        framework.TypeString,
+			Description: `Optional token used instead of the config token to create group, project and group service account tokens, a group owner token is enough. Set to an empty value to remove it.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Group Token",
+				Sensitive: true,
+			},
+		},
+		"revoke_token": {
+			Type:        framework.TypeString,
+			Description: `Optional token used instead of the config token to revoke the issued tokens. Set to an empty value to remove it.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Revoke Token",
+				Sensitive: true,
+			},
+		},
+		"allowed_token_types": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: `Token types roles of this config may request. Empty allows all token types.`,
+		},
+		"max_access_level": {
+			Type:        framework.TypeString,
+			Description: `The highest access level roles of this config may request.`,
+		},
+		"allowed_scopes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: `Scopes roles of this config may request. Empty allows all scopes.`,
+		},
+		"denied_scopes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: `Scopes roles of this config may never request.`,
+		},
+		"max_ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: `The highest TTL roles of this config may request.`,
+		},
+		"allowed_path_patterns": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: `Glob patterns the path of roles of this config must match. Empty allows all paths.`,
+		},
+		"vault_revoked_min_ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: `The shortest TTL roles can use when Vault revokes the tokens, defaults to 1h and can be lowered to 1m.`,
+		},
+		"circuit_breaker_threshold": {
+			Type:        framework.TypeInt,
+			Description: `How many consecutive transport or 5xx errors open the circuit breaker of the config, defaults to 5.`,
+		},
+		"validate_roles": {
+			Type:        framework.TypeBool,
+			Description: `Validate roles of this config against GitLab when they are written, unless the role write sets validate.`,
+		},
+		"role_history_retention": {
+			Type:        framework.TypeInt,
+			Description: `How many previous versions of the roles of this config are kept, defaults to 10.`,
+		},
 		"config_name": {
 			Type:        framework.TypeString,
 			Description: "Config name",
@@ -107,9 +214,10 @@ func (b *Backend) pathConfigRead(ctx context.Context, req *logical.Request, data
 		if config == nil {
 			return logical.ErrorResponse(ErrBackendNotConfigured.Error()), nil
 		}
-		lrd := config.LogicalResponseData()
+		lrd := config.LogicalResponseData(ctx)
 		b.Logger().Debug("Reading configuration info", "info", lrd)
-		lResp = &logical.Response{Data: config.LogicalResponseData()}
+		lResp = &logical.Response{Data: config.LogicalResponseData(ctx)}
+		lResp.Data["circuit_breaker"] = b.circuitBreaker(name, config.CircuitBreakerThreshold).LogicalResponseData()
 	}
 	return lResp, err
 }
@@ -138,10 +246,14 @@ func (b *Backend) pathConfigPatch(ctx context.Context, req *logical.Request, dat
 		}
 	}
 
+	if err = b.updateCredentialsClientInfo(ctx, config); err != nil {
+		return nil, err
+	}
+
 	b.lockClientMutex.Lock()
 	defer b.lockClientMutex.Unlock()
 	if err = saveConfig(ctx, *config, req.Storage); err == nil {
-		lrd := config.LogicalResponseData()
+		lrd := config.LogicalResponseData(ctx)
 		event(ctx, b.Backend, "config-patch", changes)
 		b.SetClient(nil, name)
 		b.Logger().Debug("Patched config", "lrd", lrd, "warnings", warnings)
@@ -156,10 +268,13 @@ func (b *Backend) updateConfigClientInfo(ctx context.Context, config *EntryConfi
 	var client Client
 	httpClient, _ = HttpClientFromContext(ctx)
 	if client, _ = GitlabClientFromContext(ctx); client == nil {
-		if client, err = NewGitlabClient(config, httpClient, b.Logger()); err == nil {
+		if client, err = NewGitlabClient(config, httpClient, b.Logger()); err == nil && clientCacheFromContext(ctx) {
 			b.SetClient(client, config.Name)
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
 
 	et, err = client.CurrentTokenInfo(ctx)
 	if err != nil {
@@ -171,9 +286,34 @@ func (b *Backend) updateConfigClientInfo(ctx context.Context, config *EntryConfi
 	config.TokenId = et.TokenID
 	config.Scopes = et.Scopes
 
+	var e error
+	if config.TokenKind, config.TokenParentId, e = client.CurrentTokenKind(ctx); e != nil {
+		b.Logger().Warn("Cannot detect the kind of the token, assuming it's a personal access token", "error", e)
+		config.TokenKind, config.TokenParentId = TokenTypePersonal, ""
+	}
+
 	return et, nil
 }
 
+// updateCredentialsClientInfo fetches the token information of the credentials that were just set
+func (b *Backend) updateCredentialsClientInfo(ctx context.Context, config *EntryConfig) (err error) {
+	for _, capability := range validCapabilities {
+		var cred = config.Credentials[capability]
+		if cred == nil || cred.TokenId != 0 {
+			continue
+		}
+		var credConfig = config.credentialConfig(capability)
+		if clientCacheFromContext(ctx) {
+			b.clients.Delete(credConfig.Name)
+		}
+		if _, err = b.updateConfigClientInfo(ctx, credConfig); err != nil {
+			return fmt.Errorf("%s_token: %w", capability, err)
+		}
+		cred.updateFromConfig(credConfig)
+	}
+	return nil
+}
+
 func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	var name = data.Get("config_name").(string)
 	var config = new(EntryConfig)
@@ -187,26 +327,19 @@ func (b *Backend) pathConfigWrite(ctx context.Context, req *logical.Request, dat
 		return nil, err
 	}
 
+	if err = b.updateCredentialsClientInfo(ctx, config); err != nil {
+		return nil, err
+	}
+
 	b.lockClientMutex.Lock()
 	defer b.lockClientMutex.Unlock()
 	var lResp *logical.Response
 
 	if err = saveConfig(ctx, *config, req.Storage); err == nil {
-		event(ctx, b.Backend, "config-write", map[string]string{
-			"path":               fmt.Sprintf("%s/%s", PathConfigStorage, name),
-			"auto_rotate_token":  strconv.FormatBool(config.AutoRotateToken),
-			"auto_rotate_before": config.AutoRotateBefore.String(),
-			"base_url":           config.BaseURL,
-			"token_id":           strconv.Itoa(config.TokenId),
-			"created_at":         config.TokenCreatedAt.Format(time.RFC3339),
-			"expires_at":         config.TokenExpiresAt.Format(time.RFC3339),
-			"scopes":             strings.Join(config.Scopes, ", "),
-			"type":               config.Type.String(),
-			"config_name":        config.Name,
-		})
+		eventConfigWrite(ctx, b, config)
 
 		b.SetClient(nil, name)
-		lrd := config.LogicalResponseData()
+		lrd := config.LogicalResponseData(ctx)
 		b.Logger().Debug("Wrote new config", "lrd", lrd, "warnings", warnings)
 		lResp = &logical.Response{Data: lrd, Warnings: warnings}
 	}
@@ -284,3 +417,19 @@ is used to configure those credentials and the default values for the Backend in
 
 You must specify expected Gitlab token with access to allow Vault to create tokens.
 `
+
+// eventConfigWrite sends the config-write event for a config that was written
+func eventConfigWrite(ctx context.Context, b *Backend, config *EntryConfig) {
+	event(ctx, b.Backend, "config-write", map[string]string{
+		"path":               fmt.Sprintf("%s/%s", PathConfigStorage, config.Name),
+		"auto_rotate_token":  strconv.FormatBool(config.AutoRotateToken),
+		"auto_rotate_before": config.AutoRotateBefore.String(),
+		"base_url":           config.BaseURL,
+		"token_id":           strconv.Itoa(config.TokenId),
+		"created_at":         config.TokenCreatedAt.Format(time.RFC3339),
+		"expires_at":         config.TokenExpiresAt.Format(time.RFC3339),
+		"scopes":             strings.Join(config.Scopes, ", "),
+		"type":               config.Type.String(),
+		"config_name":        config.Name,
+	})
+}
@@ -0,0 +1,285 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	DefaultBootstrapTokenName = "vault-plugin-secrets-gitlab"
+)
+
+const pathConfigBootstrapHelpSynopsis = `Create a dedicated service account for this configuration using a one-time admin token.`
+
+const pathConfigBootstrapHelpDescription = `
+This endpoint uses a short-lived admin token to create a service account dedicated to the plugin, an instance level
+service account on self-managed GitLab and a group service account on gitlab.com and dedicated instances. The service
+account is added to the requested groups and projects, and a personal access token is created for it which is stored
+as the token of the configuration. The bootstrap token itself is not stored, and it can be revoked once the
+configuration was created. The token of an existing configuration is only replaced when force is set, and if any step
+fails the service account that was created is deleted again.`
+
+var (
+	FieldSchemaConfigBootstrap = map[string]*framework.FieldSchema{
+		"config_name": {
+			Type:        framework.TypeString,
+			Description: "Config name",
+			Required:    true,
+		},
+		"token": {
+			Type:        framework.TypeString,
+			Description: "The short-lived admin token used to create the service account. It is not stored.",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name:      "Bootstrap Token",
+				Sensitive: true,
+			},
+		},
+		"base_url": {
+			Type:        framework.TypeString,
+			Description: `The address to access Gitlab, only used if the configuration does not exist yet.`,
+		},
+		"type": {
+			Type:          framework.TypeString,
+			Description:   "The type of gitlab instance, only used if the configuration does not exist yet.",
+			AllowedValues: []interface{}{"saas", "self-managed", "dedicated"},
+		},
+		"group": {
+			Type:        framework.TypeString,
+			Description: "The top level group the service account is created in, required on gitlab.com and dedicated instances.",
+		},
+		"name": {
+			Type:        framework.TypeString,
+			Description: "The name of the service account.",
+		},
+		"username": {
+			Type:        framework.TypeString,
+			Description: "The username of the service account.",
+		},
+		"token_name": {
+			Type:        framework.TypeString,
+			Description: "The name of the access token created for the service account.",
+			Default:     DefaultBootstrapTokenName,
+		},
+		"scopes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "The scopes of the access token created for the service account.",
+			Default:     []string{TokenScopeApi.String()},
+		},
+		"ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "The TTL of the access token created for the service account.",
+			Default:     DefaultAccessTokenMaxPossibleTTL,
+		},
+		"groups": {
+			Type:        framework.TypeKVPairs,
+			Description: "The groups the service account should be a member of, as group path and access level pairs.",
+		},
+		"projects": {
+			Type:        framework.TypeKVPairs,
+			Description: "The projects the service account should be a member of, as project path and access level pairs.",
+		},
+		"revoke_bootstrap_token": {
+			Type:        framework.TypeBool,
+			Default:     false,
+			Description: "Revoke the bootstrap token once the configuration was created.",
+		},
+		"force": {
+			Type:        framework.TypeBool,
+			Default:     false,
+			Description: "Replace the token of an existing configuration.",
+		},
+	}
+)
+
+func pathConfigBootstrap(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathConfigBootstrapHelpSynopsis),
+		HelpDescription: strings.TrimSpace(pathConfigBootstrapHelpDescription),
+		Pattern:         fmt.Sprintf("%s/%s/bootstrap$", PathConfigStorage, framework.GenericNameRegex("config_name")),
+		Fields:          FieldSchemaConfigBootstrap,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:     b.pathConfigBootstrap,
+				DisplayAttrs: &framework.DisplayAttributes{OperationVerb: "bootstrap"},
+				Summary:      "Create a dedicated service account and use its token for the configuration.",
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathConfigBootstrap(ctx context.Context, req *logical.Request, data *framework.FieldData) (lResp *logical.Response, err error) {
+	var name = data.Get("config_name").(string)
+	var config *EntryConfig
+
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, req.Storage, name)
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if config != nil && !data.Get("force").(bool) {
+		err = multierror.Append(err, fmt.Errorf("config %s already exists, set force=true to replace its token: %w", name, ErrInvalidValue))
+	}
+
+	if config == nil {
+		config = &EntryConfig{Name: name, BaseURL: data.Get("base_url").(string)}
+		if config.BaseURL == "" {
+			err = multierror.Append(err, fmt.Errorf("base_url: %w", ErrFieldRequired))
+		}
+		if typ, ok := data.GetOk("type"); ok {
+			var er error
+			if config.Type, er = TypeParse(typ.(string)); er != nil {
+				err = multierror.Append(err, er)
+			}
+		} else {
+			err = multierror.Append(err, fmt.Errorf("gitlab type: %w", ErrFieldRequired))
+		}
+	}
+
+	var group = data.Get("group").(string)
+	if config.Type != TypeSelfManaged && group == "" {
+		err = multierror.Append(err, fmt.Errorf("group is required for %s: %w", config.Type, ErrFieldRequired))
+	}
+
+	var groups, projects map[string]AccessLevel
+	var er error
+	if groups, er = parseMemberships(data.Get("groups").(map[string]string)); er != nil {
+		err = multierror.Append(err, fmt.Errorf("groups: %w", er))
+	}
+	if projects, er = parseMemberships(data.Get("projects").(map[string]string)); er != nil {
+		err = multierror.Append(err, fmt.Errorf("projects: %w", er))
+	}
+
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	var httpClient *http.Client
+	var client Client
+	httpClient, _ = HttpClientFromContext(ctx)
+	if client, _ = GitlabClientFromContext(ctx); client == nil {
+		if client, err = NewGitlabClient(&EntryConfig{Name: name, BaseURL: config.BaseURL, Type: config.Type, Token: data.Get("token").(string)}, httpClient, b.Logger()); err != nil {
+			return nil, err
+		}
+	}
+
+	var userId int
+	var username string
+	if userId, username, err = client.CreateServiceAccount(ctx, group, data.Get("name").(string), data.Get("username").(string)); err != nil {
+		return nil, fmt.Errorf("create service account: %w", err)
+	}
+	b.Logger().Info("Created the service account", "config_name", name, "user_id", userId, "username", username)
+	defer func() {
+		// nothing refers to the service account when a later step failed, so it's removed with its token
+		if err == nil {
+			return
+		}
+		if er := client.DeleteServiceAccount(ctx, group, userId); er != nil {
+			b.Logger().Warn("Failed to delete the service account", "config_name", name, "user_id", userId, "username", username, "error", er)
+		}
+	}()
+
+	for _, path := range sortedKeys(groups) {
+		if err = client.AddGroupMember(ctx, path, userId, groups[path]); err != nil {
+			return nil, fmt.Errorf("add service account %s to group %s: %w", username, path, err)
+		}
+	}
+	for _, path := range sortedKeys(projects) {
+		if err = client.AddProjectMember(ctx, path, userId, projects[path]); err != nil {
+			return nil, fmt.Errorf("add service account %s to project %s: %w", username, path, err)
+		}
+	}
+
+	var token *EntryToken
+	var _, expiresAt, _ = calculateGitlabTTL(time.Duration(data.Get("ttl").(int))*time.Second, TimeFromContext(ctx))
+	var tokenName, scopes = data.Get("token_name").(string), data.Get("scopes").([]string)
+	if group == "" {
+		token, err = client.CreateUserServiceAccountAccessToken(ctx, username, userId, tokenName, expiresAt, scopes)
+	} else {
+		token, err = client.CreateGroupServiceAccountAccessToken(ctx, group, group, userId, tokenName, expiresAt, scopes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("create access token for service account %s: %w", username, err)
+	}
+
+	config.Token = token.Token
+	if _, err = b.updateConfigClientInfo(ctx, config); err != nil {
+		return nil, err
+	}
+
+	b.lockClientMutex.Lock()
+	err = saveConfig(ctx, *config, req.Storage)
+	b.lockClientMutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	b.SetClient(nil, name)
+	eventConfigWrite(ctx, b, config)
+
+	var warnings []string
+	var revoked bool
+	if data.Get("revoke_bootstrap_token").(bool) {
+		if er = client.RevokeCurrentToken(ctx); er != nil {
+			b.Logger().Warn("Failed to revoke the bootstrap token", "config_name", name, "error", er)
+			warnings = append(warnings, fmt.Sprintf("failed to revoke the bootstrap token: %s", er))
+		} else {
+			revoked = true
+		}
+	}
+
+	event(ctx, b.Backend, "config-bootstrap", map[string]string{
+		"path":                     fmt.Sprintf("%s/%s", PathConfigStorage, name),
+		"config_name":              name,
+		"service_account_id":       strconv.Itoa(userId),
+		"service_account_username": username,
+		"token_id":                 strconv.Itoa(config.TokenId),
+		"bootstrap_token_revoked":  strconv.FormatBool(revoked),
+	})
+
+	lResp = &logical.Response{Data: config.LogicalResponseData(ctx), Warnings: warnings}
+	lResp.Data["service_account_id"] = userId
+	lResp.Data["service_account_username"] = username
+	lResp.Data["bootstrap_token_revoked"] = revoked
+	return lResp, nil
+}
+
+// parseMemberships converts the path and access level pairs to access levels
+func parseMemberships(memberships map[string]string) (parsed map[string]AccessLevel, err error) {
+	parsed = make(map[string]AccessLevel, len(memberships))
+	for path, value := range memberships {
+		var accessLevel, er = AccessLevelParse(value)
+		if er != nil || accessLevel == AccessLevelNoPermissions || accessLevel == AccessLevelUnknown {
+			err = multierror.Append(err, fmt.Errorf("%s='%s': %w", path, value, ErrFieldInvalidValue))
+			continue
+		}
+		parsed[path] = accessLevel
+	}
+	return parsed, err
+}
+
+func sortedKeys[V any](m map[string]V) (keys []string) {
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
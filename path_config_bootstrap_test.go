@@ -0,0 +1,184 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathConfigBootstrap(t *testing.T) {
+	var setup = func(t *testing.T) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, events, err := getBackendWithEvents(ctx)
+		require.NoError(t, err)
+		return ctx, b, l, events, client
+	}
+
+	var bootstrap = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, data map[string]any) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Data:      data,
+			Path:      fmt.Sprintf("%s/%s/bootstrap", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+	}
+
+	t.Run("self managed instance service account", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t)
+		resp, err := bootstrap(ctx, b, l, map[string]any{
+			"token":                  "glpat-bootstrap-token",
+			"base_url":               "http://localhost:8080/",
+			"type":                   gitlab.TypeSelfManaged.String(),
+			"groups":                 map[string]string{"example": gitlab.AccessLevelOwnerPermissions.String()},
+			"projects":               map[string]string{"example/example": gitlab.AccessLevelMaintainerPermissions.String()},
+			"revoke_bootstrap_token": true,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Empty(t, resp.Warnings)
+		require.EqualValues(t, "service_account_1", resp.Data["service_account_username"])
+		require.True(t, resp.Data["bootstrap_token_revoked"].(bool))
+		require.NotContains(t, resp.Data, "token")
+		require.EqualValues(t, []string{"group_example_1_owner", "project_example/example_1_maintainer"}, client.members)
+		require.EqualValues(t, 1, client.calledRevokeCurrentToken)
+		require.Len(t, client.accessTokens, 1)
+
+		// the config now uses the token of the service account
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "http://localhost:8080/", resp.Data["base_url"])
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/config-write"},
+			{eventType: "gitlab/config-bootstrap"},
+		})
+		require.Zero(t, client.calledDeleteServiceAccount)
+	})
+
+	t.Run("instance service account with a username", func(t *testing.T) {
+		ctx, b, l, _, _ := setup(t)
+		resp, err := bootstrap(ctx, b, l, map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+			"name":     "Vault",
+			"username": "vault-plugin",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "vault-plugin", resp.Data["service_account_username"])
+	})
+
+	t.Run("existing config token is only replaced with force", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t)
+		var data = map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		}
+		resp, err := bootstrap(ctx, b, l, data)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		events.resetEvents(t)
+
+		resp, err = bootstrap(ctx, b, l, data)
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "force")
+		require.Len(t, client.users, 1)
+		events.expectEvents(t, []expectedEvent{})
+
+		data["force"] = true
+		resp, err = bootstrap(ctx, b, l, data)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Len(t, client.users, 2)
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/config-write"},
+			{eventType: "gitlab/config-bootstrap"},
+		})
+	})
+
+	t.Run("service account is deleted when a later step fails", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t)
+		client.addMemberError = true
+		_, err := bootstrap(ctx, b, l, map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+			"projects": map[string]string{"example/example": gitlab.AccessLevelMaintainerPermissions.String()},
+		})
+		require.Error(t, err)
+		require.EqualValues(t, 1, client.calledDeleteServiceAccount)
+		events.expectEvents(t, []expectedEvent{})
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.Error(t, resp.Error())
+	})
+
+	t.Run("group is required on saas", func(t *testing.T) {
+		ctx, b, l, _, client := setup(t)
+		resp, err := bootstrap(ctx, b, l, map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "https://gitlab.com/",
+			"type":     gitlab.TypeSaaS.String(),
+		})
+		require.ErrorIs(t, err, gitlab.ErrFieldRequired)
+		require.Error(t, resp.Error())
+		require.Empty(t, client.users)
+	})
+
+	t.Run("saas group service account", func(t *testing.T) {
+		ctx, b, l, _, client := setup(t)
+		resp, err := bootstrap(ctx, b, l, map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "https://gitlab.com/",
+			"type":     gitlab.TypeSaaS.String(),
+			"group":    "example",
+			"username": "vault",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "vault", resp.Data["service_account_username"])
+		require.False(t, resp.Data["bootstrap_token_revoked"].(bool))
+		require.Zero(t, client.calledRevokeCurrentToken)
+	})
+
+	t.Run("invalid membership access level", func(t *testing.T) {
+		ctx, b, l, _, client := setup(t)
+		_, err := bootstrap(ctx, b, l, map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+			"groups":   map[string]string{"example": "admin"},
+		})
+		require.ErrorIs(t, err, gitlab.ErrFieldInvalidValue)
+		require.Empty(t, client.users)
+	})
+
+	t.Run("service account creation fails", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t)
+		client.createServiceAccountError = true
+		_, err := bootstrap(ctx, b, l, map[string]any{
+			"token":    "glpat-bootstrap-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.Error(t, err)
+		require.Zero(t, client.calledDeleteServiceAccount)
+		events.expectEvents(t, []expectedEvent{})
+	})
+}
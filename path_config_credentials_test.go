@@ -0,0 +1,186 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+	g "github.com/xanzy/go-gitlab"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathConfigCredentials(t *testing.T) {
+	var setup = func(t *testing.T, config map[string]any) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient, *inMemoryClient, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		client.mainTokenInfo.TokenID = 1
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		var data = map[string]any{
+			"token":        "glpat-secret-random-token",
+			"base_url":     "http://localhost:8080/",
+			"type":         gitlab.TypeSelfManaged.String(),
+			"group_token":  "glpat-group-token",
+			"revoke_token": "glpat-revoke-token",
+		}
+		for k, v := range config {
+			data[k] = v
+		}
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, data)
+		require.NoError(t, err)
+
+		var groupClient, revokeClient = newInMemoryClient(true), newInMemoryClient(true)
+		b.SetClient(groupClient, fmt.Sprintf("%s/%s", gitlab.DefaultConfigName, gitlab.CapabilityGroup))
+		b.SetClient(revokeClient, fmt.Sprintf("%s/%s", gitlab.DefaultConfigName, gitlab.CapabilityRevoke))
+		return ctx, b, l, events, client, groupClient, revokeClient
+	}
+
+	var readConfig = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) map[string]any {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp.Data
+	}
+
+	var issueToken = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) *logical.Secret {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         "project",
+				"token_type":   gitlab.TokenTypeProject.String(),
+				"access_level": gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":          "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		return resp.Secret
+	}
+
+	var revokeToken = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, secret *logical.Secret) error {
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, secret.LeaseID), Storage: l,
+		})
+		return err
+	}
+
+	t.Run("credentials are shown without the tokens", func(t *testing.T) {
+		ctx, b, l, _, _, _, _ := setup(t, nil)
+		credentials := readConfig(t, ctx, b, l)["credentials"].(map[string]any)
+		require.Len(t, credentials, 2)
+		require.Contains(t, credentials, gitlab.CapabilityGroup.String())
+		require.Contains(t, credentials, gitlab.CapabilityRevoke.String())
+		require.NotContains(t, credentials[gitlab.CapabilityGroup.String()], "token")
+	})
+
+	t.Run("the narrowest credential is used", func(t *testing.T) {
+		ctx, b, l, _, client, groupClient, revokeClient := setup(t, nil)
+		secret := issueToken(t, ctx, b, l)
+		require.Len(t, groupClient.accessTokens, 1)
+		require.Empty(t, client.accessTokens)
+
+		revokeClient.projectAccessTokenRevokeError = true
+		require.Error(t, revokeToken(ctx, b, l, secret))
+
+		// without a revoke credential the issuing credential revokes the token
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Data:      map[string]any{"revoke_token": ""},
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.NotContains(t, resp.Data["credentials"], gitlab.CapabilityRevoke.String())
+
+		require.NoError(t, revokeToken(ctx, b, l, secret))
+		require.Empty(t, groupClient.accessTokens)
+	})
+
+	t.Run("credentials are rotated independently", func(t *testing.T) {
+		ctx, b, l, events, client, groupClient, revokeClient := setup(t, map[string]any{"auto_rotate_token": true})
+		events.resetEvents(t)
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+		require.EqualValues(t, 1, groupClient.calledRotateMainToken)
+		require.EqualValues(t, 1, revokeClient.calledRotateMainToken)
+
+		// the clients of the rotated credentials are dropped, so the test clients are set again
+		b.SetClient(groupClient, fmt.Sprintf("%s/%s", gitlab.DefaultConfigName, gitlab.CapabilityGroup))
+		b.SetClient(revokeClient, fmt.Sprintf("%s/%s", gitlab.DefaultConfigName, gitlab.CapabilityRevoke))
+		groupClient.rotateMainTokenError = true
+		require.Error(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		credentials := readConfig(t, ctx, b, l)["credentials"].(map[string]any)
+		require.EqualValues(t, 1, credentials[gitlab.CapabilityGroup.String()].(map[string]any)["consecutive_failures"])
+		require.EqualValues(t, 0, credentials[gitlab.CapabilityRevoke.String()].(map[string]any)["consecutive_failures"])
+	})
+
+	t.Run("rotated credential is verified before it's promoted", func(t *testing.T) {
+		ctx, b, l, events, client, groupClient, _ := setup(t, map[string]any{"auto_rotate_token": true})
+		var groupCredential = func() map[string]any {
+			return readConfig(t, ctx, b, l)["credentials"].(map[string]any)[gitlab.CapabilityGroup.String()].(map[string]any)
+		}
+		var before = groupCredential()
+		events.resetEvents(t)
+
+		client.unavailable = true
+		require.Error(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.EqualValues(t, 1, groupClient.calledRotateMainToken)
+		require.True(t, groupCredential()["rotation_pending"].(bool))
+		require.EqualValues(t, before["token_sha1_hash"], groupCredential()["token_sha1_hash"])
+		require.EqualValues(t, 1, groupCredential()["consecutive_failures"])
+
+		// the pending credential is promoted on the next attempt without rotating it again
+		client.unavailable = false
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, time.Now().Add(time.Hour)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 1, groupClient.calledRotateMainToken)
+		require.False(t, groupCredential()["rotation_pending"].(bool))
+		require.NotEqualValues(t, before["token_sha1_hash"], groupCredential()["token_sha1_hash"])
+		require.EqualValues(t, 0, groupCredential()["consecutive_failures"])
+	})
+
+	t.Run("interrupted credential rotation is finished on initialize", func(t *testing.T) {
+		ctx, b, l, events, _, groupClient, _ := setup(t, nil)
+
+		// simulate a rotation that was interrupted after the new credential was persisted
+		var key = fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName)
+		entry, err := l.Get(ctx, key)
+		require.NoError(t, err)
+		var config gitlab.EntryConfig
+		require.NoError(t, entry.DecodeJSON(&config))
+		config.Credentials[gitlab.CapabilityGroup].PendingToken = &gitlab.EntryToken{
+			TokenID:   1,
+			Token:     "pending-group-token",
+			CreatedAt: g.Ptr(time.Now()),
+			ExpiresAt: g.Ptr(time.Now().Add(24 * time.Hour)),
+		}
+		entry, err = logical.StorageEntryJSON(key, config)
+		require.NoError(t, err)
+		require.NoError(t, l.Put(ctx, entry))
+		events.resetEvents(t)
+
+		require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: l}))
+		require.Zero(t, groupClient.calledRotateMainToken)
+		var credential = readConfig(t, ctx, b, l)["credentials"].(map[string]any)[gitlab.CapabilityGroup.String()].(map[string]any)
+		require.False(t, credential["rotation_pending"].(bool))
+		require.EqualValues(t, 1, credential["token_id"])
+		events.expectEvents(t, []expectedEvent{{eventType: "gitlab/config-token-rotate"}})
+	})
+}
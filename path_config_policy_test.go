@@ -0,0 +1,118 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathConfigPolicy(t *testing.T) {
+	var setup = func(t *testing.T, config map[string]any) (context.Context, *gitlab.Backend, logical.Storage) {
+		t.Helper()
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+		var data = map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		}
+		for k, v := range config {
+			data[k] = v
+		}
+		b, l, _, err := getBackendWithEventsAndConfig(ctx, data)
+		require.NoError(t, err)
+		return ctx, b, l
+	}
+
+	var writeRole = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, data map[string]any) (*logical.Response, error) {
+		var role = map[string]any{
+			"path":         "example/example",
+			"name":         "project",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		}
+		for k, v := range data {
+			role[k] = v
+		}
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: role,
+		})
+	}
+
+	var policy = map[string]any{
+		"allowed_token_types":   []string{gitlab.TokenTypeProject.String(), gitlab.TokenTypeGroup.String()},
+		"max_access_level":      gitlab.AccessLevelDeveloperPermissions.String(),
+		"allowed_scopes":        []string{gitlab.TokenScopeReadApi.String(), gitlab.TokenScopeApi.String()},
+		"denied_scopes":         []string{gitlab.TokenScopeApi.String()},
+		"max_ttl":               "24h",
+		"allowed_path_patterns": []string{"example/*"},
+	}
+
+	t.Run("role within the policy", func(t *testing.T) {
+		ctx, b, l := setup(t, policy)
+		resp, err := writeRole(ctx, b, l, nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	})
+
+	t.Run("roles outside of the policy are rejected", func(t *testing.T) {
+		for name, data := range map[string]map[string]any{
+			"token type":   {"token_type": gitlab.TokenTypePersonal.String(), "path": "admin-user", "access_level": ""},
+			"access level": {"access_level": gitlab.AccessLevelMaintainerPermissions.String()},
+			"denied scope": {"scopes": []string{gitlab.TokenScopeApi.String()}},
+			"scope":        {"scopes": []string{gitlab.TokenScopeReadRepository.String()}},
+			"ttl":          {"ttl": "48h"},
+			"path":         {"path": "other/example"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				ctx, b, l := setup(t, policy)
+				resp, err := writeRole(ctx, b, l, data)
+				require.ErrorIs(t, err, gitlab.ErrRoleNotAllowed)
+				require.Error(t, resp.Error())
+			})
+		}
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+		_, _, _, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":               "glpat-secret-random-token",
+			"base_url":            "http://localhost:8080/",
+			"type":                gitlab.TypeSelfManaged.String(),
+			"allowed_token_types": []string{"unknown"},
+			"max_access_level":    "admin",
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("issuing re-checks the policy", func(t *testing.T) {
+		ctx, b, l := setup(t, nil)
+		resp, err := writeRole(ctx, b, l, nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Data:      map[string]any{"max_access_level": gitlab.AccessLevelGuestPermissions.String()},
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), resp.Data["max_access_level"])
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.ErrorIs(t, err, gitlab.ErrRoleNotAllowed)
+		require.Error(t, resp.Error())
+	})
+}
@@ -0,0 +1,219 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	DriftRevoked       = "revoked"
+	DriftExpired       = "expired"
+	DriftMissing       = "missing"
+	DriftScopesChanged = "scopes_changed"
+)
+
+const pathConfigReconcileHelpSynopsis = `Reconcile the tokens issued with this configuration against GitLab.`
+
+const pathConfigReconcileHelpDescription = `
+This endpoint checks every token issued with this configuration against GitLab and reports the ones whose state
+drifted from what Vault knows about, tokens that were revoked or deleted in GitLab, expired before their lease or
+had their scopes changed. When resolve_stale is set the stale tokens that are still active in GitLab are revoked there,
+and every stale token is marked as revoked, so the lease revocation no longer calls GitLab for it. The leases stay
+active until they expire or are revoked in Vault, a plugin can't end a lease by itself.`
+
+var (
+	FieldSchemaConfigReconcile = map[string]*framework.FieldSchema{
+		"config_name": {
+			Type:        framework.TypeString,
+			Description: "Config name",
+			Required:    true,
+		},
+		"resolve_stale": {
+			Type:        framework.TypeBool,
+			Default:     false,
+			Description: "Revoke the stale tokens that are still active in GitLab and mark every stale token as revoked.",
+		},
+	}
+)
+
+func pathConfigReconcile(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathConfigReconcileHelpSynopsis),
+		HelpDescription: strings.TrimSpace(pathConfigReconcileHelpDescription),
+		Pattern:         fmt.Sprintf("%s/%s/reconcile$", PathConfigStorage, framework.GenericNameRegex("config_name")),
+		Fields:          FieldSchemaConfigReconcile,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:     b.pathConfigReconcile,
+				DisplayAttrs: &framework.DisplayAttributes{OperationVerb: "reconcile"},
+				Summary:      "Reconcile the issued tokens against GitLab.",
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathConfigReconcile(ctx context.Context, req *logical.Request, data *framework.FieldData) (lResp *logical.Response, err error) {
+	var name = data.Get("config_name").(string)
+	var config *EntryConfig
+
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, req.Storage, name)
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return logical.ErrorResponse(ErrBackendNotConfigured.Error()), nil
+	}
+
+	var findings []map[string]any
+	var checked int
+	findings, checked, err = b.reconcileConfig(ctx, req.Storage, config, data.Get("resolve_stale").(bool))
+	if err != nil && checked == 0 {
+		return nil, err
+	}
+
+	lResp = &logical.Response{
+		Data: map[string]any{
+			"config_name": name,
+			"checked":     checked,
+			"findings":    findings,
+		},
+	}
+	if err != nil {
+		lResp.AddWarning(err.Error())
+	}
+	return lResp, nil
+}
+
+// periodicReconcile runs the reconciliation for the config if reconcile_interval elapsed since the last run.
+func (b *Backend) periodicReconcile(ctx context.Context, req *logical.Request, config *EntryConfig) (err error) {
+	var now = TimeFromContext(ctx)
+	if last, ok := b.reconciledAt.Load(config.Name); ok && now.Sub(last.(time.Time)) < config.ReconcileInterval {
+		return nil
+	}
+	b.reconciledAt.Store(config.Name, now)
+	_, _, err = b.reconcileConfig(ctx, req.Storage, config, config.ReconcileResolveStale)
+	return err
+}
+
+func (b *Backend) reconcileConfig(ctx context.Context, s logical.Storage, config *EntryConfig, resolveStale bool) (findings []map[string]any, checked int, err error) {
+	var tokens []*EntryToken
+	if tokens, err = listIssuedTokens(ctx, s, config.Name); err != nil {
+		return nil, 0, err
+	}
+
+	var clients = make(map[Capability]Client)
+	var now = TimeFromContext(ctx).UTC()
+	findings = make([]map[string]any, 0)
+	for _, token := range tokens {
+		if token.Revoked {
+			continue
+		}
+
+		var capability = capabilityForTokenType(token.TokenType)
+		var client = clients[capability]
+		var e error
+		if client == nil {
+			if client, e = b.getClientFor(ctx, s, config.Name, capability); e != nil {
+				return findings, checked, errors.Join(err, e)
+			}
+			clients[capability] = client
+		}
+
+		var remote *EntryToken
+		switch token.TokenType {
+		case TokenTypeProject:
+			remote, e = client.GetProjectAccessToken(ctx, token.TokenID, token.ParentID)
+		case TokenTypeGroup:
+			remote, e = client.GetGroupAccessToken(ctx, token.TokenID, token.ParentID)
+		default:
+			remote, e = client.GetPersonalAccessToken(ctx, token.TokenID)
+		}
+
+		var drift string
+		switch {
+		case errors.Is(e, ErrAccessTokenNotFound):
+			drift = DriftMissing
+		case e != nil:
+			err = errors.Join(err, fmt.Errorf("token %d: %w", token.TokenID, e))
+			continue
+		case remote.Revoked:
+			drift = DriftRevoked
+		case remote.ExpiresAt != nil && !remote.ExpiresAt.After(now) && (token.ExpiresAt == nil || token.ExpiresAt.After(now)):
+			drift = DriftExpired
+		case !sameScopes(remote.Scopes, token.Scopes):
+			drift = DriftScopesChanged
+		}
+		checked++
+
+		if drift == "" {
+			continue
+		}
+
+		// only a token with changed scopes is still active in GitLab, the others can't be used anymore
+		var revokedInGitlab, markedRevoked bool
+		if resolveStale {
+			if drift == DriftScopesChanged {
+				if e = revokeToken(ctx, client, *token); e != nil && !errors.Is(e, ErrAccessTokenNotFound) {
+					err = errors.Join(err, fmt.Errorf("revoke token %d: %w", token.TokenID, e))
+				}
+				revokedInGitlab = e == nil
+			}
+			if e == nil || errors.Is(e, ErrAccessTokenNotFound) {
+				token.Revoked = true
+				if e = saveIssuedToken(ctx, *token, s); e != nil {
+					err = errors.Join(err, e)
+				}
+				markedRevoked = e == nil
+			}
+		}
+
+		b.Logger().Warn("Token drift detected", "config_name", config.Name, "token_id", token.TokenID, "role_name", token.RoleName, "drift", drift)
+		findings = append(findings, map[string]any{
+			"token_id":          token.TokenID,
+			"role_name":         token.RoleName,
+			"token_type":        token.TokenType.String(),
+			"path":              token.Path,
+			"drift":             drift,
+			"revoked_in_gitlab": revokedInGitlab,
+			"marked_revoked":    markedRevoked,
+		})
+		event(ctx, b.Backend, "token-drift", map[string]string{
+			"config_name":       config.Name,
+			"token_id":          strconv.Itoa(token.TokenID),
+			"role_name":         token.RoleName,
+			"token_type":        token.TokenType.String(),
+			"path":              token.Path,
+			"drift":             drift,
+			"revoked_in_gitlab": strconv.FormatBool(revokedInGitlab),
+			"marked_revoked":    strconv.FormatBool(markedRevoked),
+		})
+	}
+
+	return findings, checked, err
+}
+
+func sameScopes(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
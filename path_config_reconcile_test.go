@@ -0,0 +1,158 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathConfigReconcile(t *testing.T) {
+	var defaultConfig = map[string]any{
+		"token":    "glpat-secret-random-token",
+		"base_url": "http://localhost:8080/",
+		"type":     gitlab.TypeSelfManaged.String(),
+	}
+
+	var setup = func(t *testing.T, config map[string]any) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient, string) {
+		t.Helper()
+		ctx := getCtxGitlabClient(t)
+		client := newInMemoryClient(true)
+		ctx = gitlab.GitlabClientNewContext(ctx, client)
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, config)
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         "project",
+				"token_type":   gitlab.TokenTypeProject.String(),
+				"access_level": gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":          "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		events.resetEvents(t)
+		return ctx, b, l, events, client, fmt.Sprintf("%s_%v", gitlab.TokenTypeProject, resp.Secret.InternalData["token_id"])
+	}
+
+	var reconcile = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, resolveStale bool) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Data:      map[string]any{"resolve_stale": resolveStale},
+			Path:      fmt.Sprintf("%s/%s/reconcile", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+	}
+
+	t.Run("no drift", func(t *testing.T) {
+		ctx, b, l, events, _, _ := setup(t, defaultConfig)
+		resp, err := reconcile(ctx, b, l, false)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 1, resp.Data["checked"])
+		require.Empty(t, resp.Data["findings"])
+		events.expectEvents(t, []expectedEvent{})
+	})
+
+	t.Run("revoked token", func(t *testing.T) {
+		ctx, b, l, events, client, key := setup(t, defaultConfig)
+		token := client.accessTokens[key]
+		token.Revoked = true
+		client.accessTokens[key] = token
+
+		resp, err := reconcile(ctx, b, l, false)
+		require.NoError(t, err)
+		findings := resp.Data["findings"].([]map[string]any)
+		require.Len(t, findings, 1)
+		require.EqualValues(t, gitlab.DriftRevoked, findings[0]["drift"])
+		require.False(t, findings[0]["revoked_in_gitlab"].(bool))
+		require.False(t, findings[0]["marked_revoked"].(bool))
+		events.expectEvents(t, []expectedEvent{{eventType: "gitlab/token-drift"}})
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		ctx, b, l, _, client, key := setup(t, defaultConfig)
+		delete(client.accessTokens, key)
+
+		resp, err := reconcile(ctx, b, l, true)
+		require.NoError(t, err)
+		findings := resp.Data["findings"].([]map[string]any)
+		require.Len(t, findings, 1)
+		require.EqualValues(t, gitlab.DriftMissing, findings[0]["drift"])
+		require.False(t, findings[0]["revoked_in_gitlab"].(bool))
+		require.True(t, findings[0]["marked_revoked"].(bool))
+
+		// the token is marked as revoked so it's not checked again
+		resp, err = reconcile(ctx, b, l, true)
+		require.NoError(t, err)
+		require.EqualValues(t, 0, resp.Data["checked"])
+	})
+
+	t.Run("scopes changed and revoke stale", func(t *testing.T) {
+		ctx, b, l, _, client, key := setup(t, defaultConfig)
+		token := client.accessTokens[key]
+		token.Scopes = []string{gitlab.TokenScopeApi.String()}
+		client.accessTokens[key] = token
+
+		resp, err := reconcile(ctx, b, l, true)
+		require.NoError(t, err)
+		findings := resp.Data["findings"].([]map[string]any)
+		require.Len(t, findings, 1)
+		require.EqualValues(t, gitlab.DriftScopesChanged, findings[0]["drift"])
+		require.True(t, findings[0]["revoked_in_gitlab"].(bool))
+		require.True(t, findings[0]["marked_revoked"].(bool))
+		require.NotContains(t, client.accessTokens, key)
+	})
+
+	t.Run("periodic reconciliation", func(t *testing.T) {
+		var config = map[string]any{"reconcile_interval": "1h"}
+		for k, v := range defaultConfig {
+			config[k] = v
+		}
+		ctx, b, l, events, client, key := setup(t, config)
+		delete(client.accessTokens, key)
+
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		// the interval has not elapsed yet, so nothing else is reported
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		events.expectEvents(t, []expectedEvent{{eventType: "gitlab/token-drift"}})
+	})
+
+	t.Run("negative interval is rejected", func(t *testing.T) {
+		var config = map[string]any{"reconcile_interval": -3600}
+		for k, v := range defaultConfig {
+			config[k] = v
+		}
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+		b, l, _, _ := getBackendWithEvents(ctx)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+			Data: config,
+		})
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "negative")
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), gitlab.ErrBackendNotConfigured.Error())
+	})
+}
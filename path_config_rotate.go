@@ -3,7 +3,9 @@ package gitlab
 import (
 	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -44,8 +46,13 @@ func (b *Backend) checkAndRotateConfigToken(ctx context.Context, request *logica
 	var err error
 	b.Logger().Debug("Running check and rotate config token")
 
-	if time.Until(config.TokenExpiresAt) > config.AutoRotateBefore {
-		b.Logger().Debug("Nothing to do it's not yet time to rotate the token")
+	if next := config.NextRotation(TimeFromContext(ctx)); config.PendingToken == nil && (next.IsZero() || TimeFromContext(ctx).Before(next)) {
+		b.Logger().Debug("Nothing to do it's not yet time to rotate the token", "next_planned_rotation", next)
+		return nil
+	}
+
+	if TimeFromContext(ctx).Before(config.NextRotationAttempt) {
+		b.Logger().Debug("Backing off after a failed rotation", "consecutive_failures", config.ConsecutiveFailures, "next_rotation_attempt", config.NextRotationAttempt)
 		return nil
 	}
 
@@ -77,20 +84,51 @@ func (b *Backend) pathConfigTokenRotate(ctx context.Context, request *logical.Re
 		return logical.ErrorResponse(ErrBackendNotConfigured.Error()), nil
 	}
 
-	if client, err = b.getClient(ctx, request.Storage, name); err != nil {
+	if client, err = b.getClientFor(ctx, request.Storage, name); err != nil {
 		return nil, err
 	}
 
-	var entryToken *EntryToken
-	entryToken, _, err = client.RotateCurrentToken(ctx)
-	if err != nil {
-		b.Logger().Error("Failed to rotate main token", "err", err)
+	if config.PendingToken == nil {
+		var entryToken *EntryToken
+		entryToken, _, err = client.RotateCurrentToken(ctx)
+		if err != nil {
+			b.Logger().Error("Failed to rotate main token", "err", err)
+			b.recordRotationFailure(ctx, request.Storage, config, err)
+			return nil, err
+		}
+
+		// the old token is no longer valid, so we persist the new token before doing anything else with it
+		config.PendingToken = entryToken
+		b.lockClientMutex.Lock()
+		err = saveConfig(ctx, *config, request.Storage)
+		b.lockClientMutex.Unlock()
+		if err != nil {
+			b.Logger().Error("Failed to store the pending token", "err", err)
+			return nil, err
+		}
+	} else {
+		// we don't know how far the interrupted rotation got, the pending token is verified before it's used
+		b.Logger().Warn("Recovering an interrupted rotation", "config_name", name, "token_id", config.PendingToken.TokenID)
+	}
+
+	// the pending token is only promoted once it works, until then it stays pending and is verified again on the
+	// next attempt
+	if err = b.verifyPendingToken(ctx, config); err != nil {
+		b.Logger().Error("Failed to verify the pending token", "err", err)
+		b.recordRotationFailure(ctx, request.Storage, config, err)
 		return nil, err
 	}
+	var entryToken = config.PendingToken
 
+	config.PendingToken = nil
+	config.LastRotationAttempt = TimeFromContext(ctx).UTC()
+	config.LastRotationError = ""
+	config.ConsecutiveFailures = 0
+	config.NextRotationAttempt = time.Time{}
 	config.Token = entryToken.Token
 	config.TokenId = entryToken.TokenID
 	config.Scopes = entryToken.Scopes
+	config.TokenKind, config.TokenParentId = entryToken.TokenType, entryToken.ParentID
 	if entryToken.CreatedAt != nil {
 		config.TokenCreatedAt = *entryToken.CreatedAt
 	}
@@ -105,7 +143,7 @@ func (b *Backend) pathConfigTokenRotate(ctx context.Context, request *logical.Re
 		return nil, err
 	}
 
-	lResp = &logical.Response{Data: config.LogicalResponseData()}
+	lResp = &logical.Response{Data: config.LogicalResponseData(ctx)}
 	lResp.Data["token"] = config.Token
 	event(ctx, b.Backend, "config-token-rotate", map[string]string{
 		"path":       fmt.Sprintf("%s/%s", PathConfigStorage, name),
@@ -119,3 +157,212 @@ func (b *Backend) pathConfigTokenRotate(ctx context.Context, request *logical.Re
 	b.SetClient(nil, name)
 	return lResp, err
 }
+
+// checkAndRotateCredentials rotates the credentials of the config that are due, independently of the config token.
+func (b *Backend) checkAndRotateCredentials(ctx context.Context, request *logical.Request, name string) (err error) {
+	var config *EntryConfig
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, request.Storage, name)
+	b.lockClientMutex.RUnlock()
+	if err != nil || config == nil {
+		return err
+	}
+
+	var now = TimeFromContext(ctx)
+	for _, capability := range validCapabilities {
+		var credConfig = config.credentialConfig(capability)
+		if credConfig == nil {
+			continue
+		}
+		if now.Before(credConfig.NextRotationAttempt) {
+			continue
+		}
+		// an interrupted rotation is finished even when the credential isn't due yet
+		if next := credConfig.NextRotation(now); credConfig.PendingToken == nil && (next.IsZero() || now.Before(next)) {
+			continue
+		}
+		err = errors.Join(err, b.rotateCredential(ctx, request.Storage, name, capability))
+	}
+	return err
+}
+
+func (b *Backend) rotateCredential(ctx context.Context, s logical.Storage, name string, capability Capability) (err error) {
+	var config *EntryConfig
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, s, name)
+	b.lockClientMutex.RUnlock()
+	if err != nil || config == nil || config.Credentials[capability] == nil {
+		return err
+	}
+
+	// the credential goes through the same two phases as the config token, the rotated token is persisted as
+	// pending first and only promoted once it has been verified
+	var pendingToken = config.Credentials[capability].PendingToken
+	if pendingToken == nil {
+		var client Client
+		if client, err = b.getClientFor(ctx, s, name, capability); err != nil {
+			return err
+		}
+		if pendingToken, _, err = client.RotateCurrentToken(ctx); err != nil {
+			b.Logger().Error("Failed to rotate the credential", "config_name", name, "capability", capability, "err", err)
+			b.recordCredentialRotationFailure(ctx, s, name, capability, err)
+			return err
+		}
+
+		if err = b.updateCredential(ctx, s, name, capability, func(cred *EntryCredential) {
+			cred.PendingToken = pendingToken
+		}); err != nil {
+			b.Logger().Error("Failed to store the pending credential", "config_name", name, "capability", capability, "err", err)
+			return err
+		}
+	} else {
+		b.Logger().Warn("Recovering an interrupted credential rotation", "config_name", name, "capability", capability, "token_id", pendingToken.TokenID)
+	}
+
+	var credConfig = config.credentialConfig(capability)
+	credConfig.PendingToken = pendingToken
+	if err = b.verifyPendingToken(ctx, credConfig); err != nil {
+		b.Logger().Error("Failed to verify the pending credential", "config_name", name, "capability", capability, "err", err)
+		b.recordCredentialRotationFailure(ctx, s, name, capability, err)
+		return err
+	}
+
+	var now = TimeFromContext(ctx).UTC()
+	if err = b.updateCredential(ctx, s, name, capability, func(cred *EntryCredential) {
+		cred.PendingToken = nil
+		cred.Token = pendingToken.Token
+		cred.TokenId = pendingToken.TokenID
+		cred.Scopes = pendingToken.Scopes
+		cred.TokenKind, cred.TokenParentId = pendingToken.TokenType, pendingToken.ParentID
+		if pendingToken.CreatedAt != nil {
+			cred.TokenCreatedAt = *pendingToken.CreatedAt
+		}
+		if pendingToken.ExpiresAt != nil {
+			cred.TokenExpiresAt = *pendingToken.ExpiresAt
+		}
+		cred.LastRotationAttempt = now
+		cred.LastRotationError = ""
+		cred.ConsecutiveFailures = 0
+		cred.NextRotationAttempt = time.Time{}
+	}); err != nil {
+		b.Logger().Error("Failed to store the rotated credential", "config_name", name, "capability", capability, "err", err)
+		return err
+	}
+	b.clients.Delete(credentialClientName(name, capability))
+
+	var expiresAt, createdAt time.Time
+	if pendingToken.ExpiresAt != nil {
+		expiresAt = *pendingToken.ExpiresAt
+	}
+	if pendingToken.CreatedAt != nil {
+		createdAt = *pendingToken.CreatedAt
+	}
+	event(ctx, b.Backend, "config-token-rotate", map[string]string{
+		"path":       fmt.Sprintf("%s/%s", PathConfigStorage, name),
+		"capability": capability.String(),
+		"expires_at": expiresAt.Format(time.RFC3339),
+		"created_at": createdAt.Format(time.RFC3339),
+		"scopes":     strings.Join(pendingToken.Scopes, ", "),
+		"token_id":   strconv.Itoa(pendingToken.TokenID),
+		"name":       pendingToken.Name,
+	})
+	return nil
+}
+
+// updateCredential applies the update to the credential of the stored config and saves it
+func (b *Backend) updateCredential(ctx context.Context, s logical.Storage, name string, capability Capability, update func(cred *EntryCredential)) (err error) {
+	b.lockClientMutex.Lock()
+	defer b.lockClientMutex.Unlock()
+	var config *EntryConfig
+	if config, err = getConfig(ctx, s, name); err != nil {
+		return err
+	}
+	if config == nil || config.Credentials[capability] == nil {
+		return fmt.Errorf("%s credential of config %s: %w", capability, name, ErrNilValue)
+	}
+	update(config.Credentials[capability])
+	return saveConfig(ctx, *config, s)
+}
+
+// recordCredentialRotationFailure stores the failed rotation attempt on the credential and schedules the next
+// attempt with an exponential backoff, like recordRotationFailure does for the config token.
+func (b *Backend) recordCredentialRotationFailure(ctx context.Context, s logical.Storage, name string, capability Capability, rotateErr error) {
+	var now = TimeFromContext(ctx).UTC()
+	var cred EntryCredential
+	if err := b.updateCredential(ctx, s, name, capability, func(c *EntryCredential) {
+		c.LastRotationAttempt = now
+		c.LastRotationError = rotateErr.Error()
+		c.ConsecutiveFailures++
+		c.NextRotationAttempt = now.Add(rotationBackoff(c.ConsecutiveFailures))
+		cred = *c
+	}); err != nil {
+		b.Logger().Error("Failed to store the rotation status", "err", err)
+	}
+
+	event(ctx, b.Backend, "config-token-rotate-failed", map[string]string{
+		"path":                  fmt.Sprintf("%s/%s", PathConfigStorage, name),
+		"capability":            capability.String(),
+		"error":                 rotateErr.Error(),
+		"consecutive_failures":  strconv.Itoa(cred.ConsecutiveFailures),
+		"next_rotation_attempt": cred.NextRotationAttempt.Format(time.RFC3339),
+	})
+}
+
+// verifyPendingToken checks that the pending token of the config works and that it's the token we expect.
+func (b *Backend) verifyPendingToken(ctx context.Context, config *EntryConfig) (err error) {
+	var httpClient *http.Client
+	var client Client
+	httpClient, _ = HttpClientFromContext(ctx)
+	if client, _ = GitlabClientFromContext(ctx); client == nil {
+		var pendingConfig = *config
+		pendingConfig.Token = config.PendingToken.Token
+		if client, err = NewGitlabClient(&pendingConfig, httpClient, b.Logger()); err != nil {
+			return err
+		}
+	}
+
+	var et *EntryToken
+	if et, err = client.CurrentTokenInfo(ctx); err != nil {
+		return fmt.Errorf("pending token cannot be verified: %w", err)
+	}
+	if et.TokenID != config.PendingToken.TokenID {
+		return fmt.Errorf("pending token id %d does not match %d: %w", config.PendingToken.TokenID, et.TokenID, ErrInvalidValue)
+	}
+	return nil
+}
+
+// recordRotationFailure stores the failed rotation attempt on the config and schedules the next attempt
+// with an exponential backoff, so the periodic function doesn't retry on every tick.
+func (b *Backend) recordRotationFailure(ctx context.Context, s logical.Storage, config *EntryConfig, rotateErr error) {
+	var now = TimeFromContext(ctx).UTC()
+	config.LastRotationAttempt = now
+	config.LastRotationError = rotateErr.Error()
+	config.ConsecutiveFailures++
+	config.NextRotationAttempt = now.Add(rotationBackoff(config.ConsecutiveFailures))
+
+	b.lockClientMutex.Lock()
+	defer b.lockClientMutex.Unlock()
+	if err := saveConfig(ctx, *config, s); err != nil {
+		b.Logger().Error("Failed to store the rotation status", "err", err)
+	}
+
+	event(ctx, b.Backend, "config-token-rotate-failed", map[string]string{
+		"path":                  fmt.Sprintf("%s/%s", PathConfigStorage, config.Name),
+		"error":                 config.LastRotationError,
+		"consecutive_failures":  strconv.Itoa(config.ConsecutiveFailures),
+		"next_rotation_attempt": config.NextRotationAttempt.Format(time.RFC3339),
+	})
+}
+
+func rotationBackoff(failures int) time.Duration {
+	return exponentialBackoff(failures, DefaultRotationBackoffMin, DefaultRotationBackoffMax)
+}
+
+// exponentialBackoff doubles the wait time for every failure after the first one, up to the max
+func exponentialBackoff(failures int, minBackoff, maxBackoff time.Duration) time.Duration {
+	var backoff = minBackoff
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
@@ -24,4 +24,34 @@ func TestPathConfigRotate(t *testing.T) {
 		require.Error(t, resp.Error())
 		require.EqualValues(t, resp.Error(), gitlab.ErrBackendNotConfigured)
 	})
+
+	t.Run("rotated token is verified before it's promoted", func(t *testing.T) {
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+		events.resetEvents(t)
+
+		client.rotateMainToken.TokenID, client.rotateMainToken.Token = 7, "rotated-token"
+		var calledMainToken = client.calledMainToken
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/%s/rotate", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "rotated-token", resp.Data["token"])
+		require.EqualValues(t, 7, resp.Data["token_id"])
+		require.False(t, resp.Data["rotation_pending"].(bool))
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+		require.EqualValues(t, calledMainToken+1, client.calledMainToken)
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/config-token-rotate"},
+		})
+	})
 }
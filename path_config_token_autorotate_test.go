@@ -1,6 +1,7 @@
 package gitlab_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -8,6 +9,7 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	g "github.com/xanzy/go-gitlab"
 
 	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
 )
@@ -262,3 +264,297 @@ func TestPathConfig_AutoRotateToken(t *testing.T) {
 	})
 
 }
+
+func TestPathConfig_AutoRotateTokenBackoff(t *testing.T) {
+	var client = newInMemoryClient(true)
+	ctx, url := getCtxGitlabClientWithUrl(t)
+	ctx = gitlab.GitlabClientNewContext(ctx, newInMemoryClient(true))
+	b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+		"token":              "token",
+		"base_url":           url,
+		"auto_rotate_token":  true,
+		"auto_rotate_before": "24h",
+		"type":               gitlab.TypeSelfManaged.String(),
+	})
+	require.NoError(t, err)
+
+	client.rotateMainTokenError = true
+	b.SetClient(client, gitlab.DefaultConfigName)
+
+	var readConfig = func(ctx context.Context) map[string]any {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp.Data
+	}
+
+	var now = time.Now()
+	err = b.PeriodicFunc(gitlab.WithStaticTime(ctx, now), &logical.Request{Storage: l})
+	require.Error(t, err)
+	require.EqualValues(t, 1, client.calledRotateMainToken)
+
+	data := readConfig(ctx)
+	require.EqualValues(t, 1, data["consecutive_failures"])
+	require.EqualValues(t, "RotateCurrentToken", data["last_rotation_error"])
+	require.EqualValues(t, now.UTC().Add(gitlab.DefaultRotationBackoffMin).Format(time.RFC3339), data["next_rotation_attempt"])
+
+	// still backing off, so no rotation should be attempted
+	err = b.PeriodicFunc(gitlab.WithStaticTime(ctx, now.Add(30*time.Second)), &logical.Request{Storage: l})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, client.calledRotateMainToken)
+
+	// the backoff doubles after every failure
+	now = now.Add(gitlab.DefaultRotationBackoffMin)
+	err = b.PeriodicFunc(gitlab.WithStaticTime(ctx, now), &logical.Request{Storage: l})
+	require.Error(t, err)
+	require.EqualValues(t, 2, client.calledRotateMainToken)
+	data = readConfig(ctx)
+	require.EqualValues(t, 2, data["consecutive_failures"])
+	require.EqualValues(t, now.UTC().Add(2*gitlab.DefaultRotationBackoffMin).Format(time.RFC3339), data["next_rotation_attempt"])
+
+	// a successful rotation clears the failures
+	client.rotateMainTokenError = false
+	now = now.Add(2 * gitlab.DefaultRotationBackoffMin)
+	err = b.PeriodicFunc(gitlab.WithStaticTime(ctx, now), &logical.Request{Storage: l})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, client.calledRotateMainToken)
+	data = readConfig(ctx)
+	require.EqualValues(t, 0, data["consecutive_failures"])
+	require.Empty(t, data["last_rotation_error"])
+	require.Empty(t, data["next_rotation_attempt"])
+
+	events.expectEvents(t, []expectedEvent{
+		{eventType: "gitlab/config-write"},
+		{eventType: "gitlab/config-token-rotate-failed"},
+		{eventType: "gitlab/config-token-rotate-failed"},
+		{eventType: "gitlab/config-token-rotate"},
+	})
+}
+
+func TestPathConfig_RotateTokenPending(t *testing.T) {
+	var setup = func(t *testing.T, pendingTokenId int) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx, url := getCtxGitlabClientWithUrl(t)
+		ctx = gitlab.GitlabClientNewContext(ctx, client)
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":    "token",
+			"base_url": url,
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+
+		// simulate a rotation that was interrupted after the new token was persisted
+		var key = fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName)
+		entry, err := l.Get(ctx, key)
+		require.NoError(t, err)
+		var config gitlab.EntryConfig
+		require.NoError(t, entry.DecodeJSON(&config))
+		config.PendingToken = &gitlab.EntryToken{
+			TokenID:   pendingTokenId,
+			Token:     "pending-token",
+			CreatedAt: g.Ptr(time.Now()),
+			ExpiresAt: g.Ptr(time.Now().Add(24 * time.Hour)),
+		}
+		entry, err = logical.StorageEntryJSON(key, config)
+		require.NoError(t, err)
+		require.NoError(t, l.Put(ctx, entry))
+		return ctx, b, l, events, client
+	}
+
+	var readConfig = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) map[string]any {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp.Data
+	}
+
+	t.Run("interrupted rotation is finished on initialize", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t, 0)
+		require.True(t, readConfig(t, ctx, b, l)["rotation_pending"].(bool))
+
+		require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+		require.False(t, readConfig(t, ctx, b, l)["rotation_pending"].(bool))
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/config-write"},
+			{eventType: "gitlab/config-token-rotate"},
+		})
+	})
+
+	t.Run("interrupted rotation is finished by the periodic function", func(t *testing.T) {
+		ctx, b, l, _, client := setup(t, 0)
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+		require.False(t, readConfig(t, ctx, b, l)["rotation_pending"].(bool))
+	})
+
+	t.Run("pending token that cannot be verified is kept", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t, 42)
+		require.Error(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+
+		data := readConfig(t, ctx, b, l)
+		require.True(t, data["rotation_pending"].(bool))
+		require.EqualValues(t, 42, data["pending_token_id"])
+		require.EqualValues(t, 1, data["consecutive_failures"])
+		require.NotEmpty(t, data["last_rotation_error"])
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/config-write"},
+			{eventType: "gitlab/config-token-rotate-failed"},
+		})
+	})
+
+	t.Run("rotated token that cannot be verified is not promoted", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t, 0)
+		require.NoError(t, b.Initialize(ctx, &logical.InitializationRequest{Storage: l}))
+		events.resetEvents(t)
+
+		client.rotateMainToken.TokenID, client.rotateMainToken.Token = 42, "rotated-token"
+		client.unavailable = true
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/%s/rotate", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.Error(t, err)
+		require.Nil(t, resp)
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+
+		data := readConfig(t, ctx, b, l)
+		require.True(t, data["rotation_pending"].(bool))
+		require.EqualValues(t, 42, data["pending_token_id"])
+		require.EqualValues(t, 1, data["consecutive_failures"])
+
+		// once the token can be verified the pending token is promoted without rotating again
+		client.unavailable = false
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/%s/rotate", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, "rotated-token", resp.Data["token"])
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+		data = readConfig(t, ctx, b, l)
+		require.False(t, data["rotation_pending"].(bool))
+		require.EqualValues(t, 0, data["pending_token_id"])
+		require.EqualValues(t, 42, data["token_id"])
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/config-token-rotate-failed"},
+			{eventType: "gitlab/config-token-rotate"},
+		})
+	})
+}
+
+func TestPathConfig_RotationSchedule(t *testing.T) {
+	var setup = func(t *testing.T, config map[string]any) (context.Context, *gitlab.Backend, logical.Storage, *inMemoryClient, time.Time, error) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		client.mainTokenInfo.ExpiresAt = g.Ptr(client.mainTokenInfo.CreatedAt.Add(365 * 24 * time.Hour))
+		if expiresAt, ok := config["token_expires_at"].(time.Time); ok {
+			client.mainTokenInfo.ExpiresAt = &expiresAt
+			delete(config, "token_expires_at")
+		}
+		ctx, url := getCtxGitlabClientWithUrl(t)
+		ctx = gitlab.GitlabClientNewContext(ctx, client)
+		var data = map[string]any{
+			"token":    "token",
+			"base_url": url,
+			"type":     gitlab.TypeSelfManaged.String(),
+		}
+		for k, v := range config {
+			data[k] = v
+		}
+		b, l, _, err := getBackendWithEventsAndConfig(ctx, data)
+		return ctx, b, l, client, client.mainTokenInfo.CreatedAt.UTC(), err
+	}
+
+	var readConfig = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) map[string]any {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp.Data
+	}
+
+	t.Run("invalid values", func(t *testing.T) {
+		for name, config := range map[string]map[string]any{
+			"invalid cron expression":   {"rotation_schedule": "every tuesday"},
+			"schedule and period":       {"rotation_schedule": "0 2 * * 2", "rotation_period": "48h"},
+			"window without a schedule": {"rotation_window": "2h"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, _, _, _, _, err := setup(t, config)
+				require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+			})
+		}
+	})
+
+	t.Run("rotates only within the window", func(t *testing.T) {
+		ctx, b, l, client, createdAt, err := setup(t, map[string]any{
+			"rotation_schedule": "0 2 * * *",
+			"rotation_window":   "2h",
+		})
+		require.NoError(t, err)
+
+		var scheduled = createdAt.Truncate(24 * time.Hour).Add(2 * time.Hour)
+		if !scheduled.After(createdAt) {
+			scheduled = scheduled.Add(24 * time.Hour)
+		}
+		data := readConfig(t, ctx, b, l)
+		require.EqualValues(t, "0 2 * * *", data["rotation_schedule"])
+		require.EqualValues(t, scheduled.Format(time.RFC3339), data["next_planned_rotation"])
+
+		// before the scheduled time
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, scheduled.Add(-time.Minute)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+
+		// the window was missed, so the rotation is postponed to the next one
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, scheduled.Add(3*time.Hour)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, scheduled.Add(24*time.Hour+30*time.Minute)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+	})
+
+	t.Run("rotates after the rotation period", func(t *testing.T) {
+		ctx, b, l, client, createdAt, err := setup(t, map[string]any{"rotation_period": "48h"})
+		require.NoError(t, err)
+		require.EqualValues(t, createdAt.Add(48*time.Hour).Format(time.RFC3339), readConfig(t, ctx, b, l)["next_planned_rotation"])
+
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, createdAt.Add(47*time.Hour)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, createdAt.Add(49*time.Hour)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+	})
+
+	t.Run("rotates before the token expires when the schedule is later", func(t *testing.T) {
+		var expiresAt = time.Now().UTC().Add(72 * time.Hour).Truncate(time.Second)
+		ctx, b, l, client, _, err := setup(t, map[string]any{
+			"rotation_schedule":  "0 2 1 1 *",
+			"auto_rotate_before": "48h",
+			"token_expires_at":   expiresAt,
+		})
+		require.NoError(t, err)
+
+		var rotateAt = expiresAt.Add(-48 * time.Hour)
+		require.EqualValues(t, rotateAt.Format(time.RFC3339), readConfig(t, ctx, b, l)["next_planned_rotation"])
+
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, rotateAt.Add(-time.Minute)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 0, client.calledRotateMainToken)
+
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, rotateAt.Add(time.Minute)), &logical.Request{Storage: l}))
+		require.EqualValues(t, 1, client.calledRotateMainToken)
+	})
+}
@@ -0,0 +1,444 @@
+package gitlab
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathExport = "export"
+	PathImport = "import"
+
+	pathExportHelpSyn  = `Export the configs, role templates and roles as a single document`
+	pathExportHelpDesc = `
+This path returns the configs, role templates and roles as a single JSON or YAML document, every entry holds the
+fields of a write to the config, role template or role. Credentials are left out, unless a PEM encoded RSA public_key
+is provided, in which case they are encrypted with RSA-OAEP and SHA-256 under encrypted_credentials of the config.`
+	pathImportHelpSyn  = `Import the configs, role templates and roles from a single document`
+	pathImportHelpDesc = `
+This path loads a document in the format of the export. Every item is checked with the same checks as a write before
+anything is applied, and the response has the changes to every item. With mode=merge the items of the document are
+written, with mode=replace the role templates and roles that aren't in the document are also deleted, and with
+mode=dry-run nothing is written. Configs are never deleted, and a new config needs the token in the document.`
+)
+
+func pathExport(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathExportHelpSyn),
+		HelpDescription: strings.TrimSpace(pathExportHelpDesc),
+		Pattern:         fmt.Sprintf("%s$", PathExport),
+		Fields: map[string]*framework.FieldSchema{
+			"format": {
+				Type:          framework.TypeString,
+				Description:   "The format of the document, json or yaml",
+				Default:       ExportFormatJSON,
+				AllowedValues: []any{ExportFormatJSON, ExportFormatYAML},
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: "PEM encoded RSA public key to encrypt the credentials with, without it the credentials are left out",
+			},
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "export",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathExport,
+				Summary:  "Export the configs, role templates and roles",
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func pathImport(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathImportHelpSyn),
+		HelpDescription: strings.TrimSpace(pathImportHelpDesc),
+		Pattern:         fmt.Sprintf("%s$", PathImport),
+		Fields: map[string]*framework.FieldSchema{
+			"document": {
+				Type:        framework.TypeString,
+				Description: "The JSON or YAML document to import",
+				Required:    true,
+			},
+			"mode": {
+				Type:          framework.TypeString,
+				Description:   "How the document is applied, merge, replace or dry-run",
+				Default:       ImportModeMerge,
+				AllowedValues: []any{ImportModeMerge, ImportModeReplace, ImportModeDryRun},
+			},
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "import",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathImport,
+				Summary:  "Import the configs, role templates and roles",
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "import",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathExport(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var format = data.Get("format").(string)
+	if !slices.Contains(validExportFormats, format) {
+		var err = fmt.Errorf("format='%s', should be one of %v: %w", format, validExportFormats, ErrFieldInvalidValue)
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	var key *rsa.PublicKey
+	var err error
+	if publicKey := data.Get("public_key").(string); publicKey != "" {
+		if key, err = parseRSAPublicKey(publicKey); err != nil {
+			return logical.ErrorResponse(err.Error()), err
+		}
+	}
+
+	b.lockClientMutex.RLock()
+	var doc, er = exportDocument(ctx, req.Storage, key)
+	b.lockClientMutex.RUnlock()
+	if er != nil {
+		return nil, er
+	}
+
+	var out []byte
+	if out, err = doc.Marshal(format); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Debug("Exported", "configs", len(doc.Configs), "roleTemplates", len(doc.RoleTemplates), "roles", len(doc.Roles))
+	return &logical.Response{
+		Data: map[string]any{
+			"format":   format,
+			"document": string(out),
+		},
+	}, nil
+}
+
+// exportDocument collects the configs, role templates and roles, the credentials are encrypted with the key or left
+// out when there is no key
+func exportDocument(ctx context.Context, s logical.Storage, key *rsa.PublicKey) (doc ExportDocument, err error) {
+	doc = ExportDocument{
+		Configs:       map[string]map[string]any{},
+		RoleTemplates: map[string]map[string]any{},
+		Roles:         map[string]map[string]any{},
+	}
+
+	var names []string
+	if names, err = s.List(ctx, fmt.Sprintf("%s/", PathConfigStorage)); err != nil {
+		return doc, err
+	}
+	for _, name := range names {
+		var config *EntryConfig
+		if config, err = getConfig(ctx, s, name); err != nil || config == nil {
+			if err != nil {
+				return doc, err
+			}
+			continue
+		}
+		var entry = config.exportData()
+		if key != nil {
+			if entry["encrypted_credentials"], err = encryptCredentials(key, config.exportCredentials()); err != nil {
+				return doc, err
+			}
+		}
+		doc.Configs[name] = entry
+	}
+
+	if names, err = s.List(ctx, fmt.Sprintf("%s/", PathRoleTemplateStorage)); err != nil {
+		return doc, err
+	}
+	for _, name := range names {
+		var tmpl *EntryRoleTemplate
+		if tmpl, err = getRoleTemplate(ctx, name, s); err != nil {
+			return doc, err
+		}
+		if tmpl != nil {
+			doc.RoleTemplates[name] = tmpl.exportData()
+		}
+	}
+
+	if names, err = s.List(ctx, fmt.Sprintf("%s/", PathRoleStorage)); err != nil {
+		return doc, err
+	}
+	for _, name := range names {
+		var role *EntryRole
+		if role, err = getRole(ctx, name, s); err != nil {
+			return doc, err
+		}
+		if role != nil {
+			doc.Roles[name] = role.exportData()
+		}
+	}
+
+	return doc, nil
+}
+
+func (b *Backend) pathImport(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var mode = data.Get("mode").(string)
+	if !slices.Contains(validImportModes, mode) {
+		var err = fmt.Errorf("mode='%s', should be one of %v: %w", mode, validImportModes, ErrFieldInvalidValue)
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	var doc, err = ExportDocumentParse(data.Get("document").(string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	b.lockClientMutex.RLock()
+	var items, warnings, er = b.planImport(ctx, req, doc, mode == ImportModeReplace)
+	b.lockClientMutex.RUnlock()
+	if er != nil {
+		return logical.ErrorResponse(er.Error()), er
+	}
+
+	var applied []string
+	if mode != ImportModeDryRun {
+		if applied, err = b.applyImport(ctx, req, items); err != nil {
+			err = fmt.Errorf("import failed after applying %v: %w", applied, err)
+			return logical.ErrorResponse(err.Error()), err
+		}
+		event(ctx, b.Backend, "import", map[string]string{
+			"path":    PathImport,
+			"mode":    mode,
+			"applied": strconv.Itoa(len(applied)),
+		})
+	}
+
+	var itemsData = make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		itemsData = append(itemsData, item.LogicalResponseData())
+	}
+
+	b.Logger().Debug("Imported", "mode", mode, "items", len(items), "applied", len(applied))
+	return &logical.Response{
+		Data: map[string]any{
+			"mode":    mode,
+			"items":   itemsData,
+			"applied": len(applied),
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+// planImport checks every item of the document against a copy of the storage, in the order they are applied, and
+// returns the changes to every item. Nothing is written to the storage of the request, the caller holds
+// lockClientMutex.
+func (b *Backend) planImport(ctx context.Context, req *logical.Request, doc ExportDocument, replace bool) (items []importItem, warnings []string, err error) {
+	var staging logical.Storage
+	if staging, err = stagingStorage(ctx, req.Storage); err != nil {
+		return nil, nil, err
+	}
+	var stagingReq = *req
+	stagingReq.Storage = staging
+	// the configs in the staging storage may differ from the ones the cached clients were created for
+	ctx = withoutClientCache(ctx)
+
+	var errs *multierror.Error
+	for _, name := range sortedKeys(doc.Configs) {
+		var item, w, er = b.planImportConfig(ctx, staging, name, doc.Configs[name])
+		warnings = append(warnings, w...)
+		if er != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s/%s: %w", PathConfigStorage, name, er))
+			continue
+		}
+		items = append(items, item)
+	}
+
+	for _, name := range sortedKeys(doc.RoleTemplates) {
+		var fields = maps.Clone(doc.RoleTemplates[name])
+		fields["template_name"] = name
+		var tmpl, er = roleTemplateFromFieldData(ctx, staging, &framework.FieldData{Raw: fields, Schema: FieldSchemaRoleTemplates})
+		if er != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s/%s: %w", PathRoleTemplateStorage, name, er))
+			continue
+		}
+		var existing *EntryRoleTemplate
+		if existing, er = getRoleTemplate(ctx, name, staging); er != nil {
+			return nil, nil, er
+		}
+		var before map[string]any
+		if existing != nil {
+			before = existing.exportData()
+		}
+		var changes = diffExportData(before, tmpl.exportData())
+		items = append(items, importItem{Kind: PathRoleTemplateStorage, Name: name, Action: importAction(existing != nil, changes), Changes: changes, data: fields})
+
+		var entry *logical.StorageEntry
+		if entry, er = logical.StorageEntryJSON(fmt.Sprintf("%s/%s", PathRoleTemplateStorage, name), tmpl); er == nil {
+			er = staging.Put(ctx, entry)
+		}
+		if er != nil {
+			return nil, nil, er
+		}
+	}
+
+	if replace {
+		var deleted []importItem
+		if deleted, err = planImportDeletes(ctx, staging, doc); err != nil {
+			return nil, nil, err
+		}
+		items = append(items, deleted...)
+	}
+
+	for _, name := range sortedKeys(doc.Roles) {
+		var fields = maps.Clone(doc.Roles[name])
+		fields["role_name"] = name
+		var rw, resp, er = b.validateRoleWrite(ctx, &stagingReq, &framework.FieldData{Raw: fields, Schema: FieldSchemaRoles})
+		if er == nil && resp.IsError() {
+			er = resp.Error()
+		}
+		if er != nil {
+			errs = multierror.Append(errs, fmt.Errorf("%s/%s: %w", PathRoleStorage, name, er))
+			continue
+		}
+		for _, w := range rw.warnings {
+			warnings = append(warnings, fmt.Sprintf("%s/%s: %s", PathRoleStorage, name, w))
+		}
+		var existing *EntryRole
+		if existing, er = getRole(ctx, name, staging); er != nil {
+			return nil, nil, er
+		}
+		var before map[string]any
+		if existing != nil {
+			before = existing.exportData()
+		}
+		var changes = diffExportData(before, rw.stored.exportData())
+		items = append(items, importItem{Kind: PathRoleStorage, Name: name, Action: importAction(existing != nil, changes), Changes: changes, data: fields})
+	}
+
+	return items, warnings, errs.ErrorOrNil()
+}
+
+// planImportConfig checks the config of the document and stores it in the staging storage, an existing config is
+// patched with the fields of the document and keeps its credentials unless the document sets them. A config that
+// changes is checked against GitLab, so a bad token or base_url fails before anything is applied.
+func (b *Backend) planImportConfig(ctx context.Context, staging logical.Storage, name string, fields map[string]any) (item importItem, warnings []string, err error) {
+	fields = maps.Clone(fields)
+	if _, ok := fields["encrypted_credentials"]; ok {
+		delete(fields, "encrypted_credentials")
+		warnings = append(warnings, fmt.Sprintf("%s/%s: encrypted_credentials are ignored, decrypt them and set them as fields of the config", PathConfigStorage, name))
+	}
+	fields["config_name"] = name
+	var data = &framework.FieldData{Raw: fields, Schema: FieldSchemaConfig}
+
+	var existing *EntryConfig
+	if existing, err = getConfig(ctx, staging, name); err != nil {
+		return item, warnings, err
+	}
+
+	var config = new(EntryConfig)
+	var before map[string]any
+	var beforeCredentials = map[string]string{}
+	if existing != nil {
+		*config = *existing
+		config.Credentials = maps.Clone(existing.Credentials)
+		before, beforeCredentials = existing.exportData(), existing.exportCredentials()
+		_, _, err = config.Merge(data)
+	} else {
+		_, err = config.UpdateFromFieldData(data)
+		config.Name = name
+	}
+	if err != nil {
+		return item, warnings, err
+	}
+
+	var changes = diffExportData(before, config.exportData())
+	maps.Copy(changes, diffCredentials(beforeCredentials, config.exportCredentials()))
+	item = importItem{Kind: PathConfigStorage, Name: name, Action: importAction(existing != nil, changes), Changes: changes, data: fields}
+	if item.Action != importActionUnchanged {
+		if _, err = b.updateConfigClientInfo(ctx, config); err != nil {
+			return item, warnings, err
+		}
+		if err = b.updateCredentialsClientInfo(ctx, config); err != nil {
+			return item, warnings, err
+		}
+	}
+	return item, warnings, saveConfig(ctx, *config, staging)
+}
+
+// planImportDeletes removes the role templates and roles that aren't in the document from the staging storage
+func planImportDeletes(ctx context.Context, staging logical.Storage, doc ExportDocument) (items []importItem, err error) {
+	for _, kind := range []string{PathRoleStorage, PathRoleTemplateStorage} {
+		var inDocument = doc.Roles
+		if kind == PathRoleTemplateStorage {
+			inDocument = doc.RoleTemplates
+		}
+		var names []string
+		if names, err = staging.List(ctx, fmt.Sprintf("%s/", kind)); err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if _, ok := inDocument[name]; ok {
+				continue
+			}
+			items = append(items, importItem{Kind: kind, Name: name, Action: importActionDelete, Changes: map[string]any{}})
+			if err = staging.Delete(ctx, fmt.Sprintf("%s/%s", kind, name)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return items, nil
+}
+
+// applyImport applies the changes with the same handlers as the writes and deletes, in the order of the items with
+// the deletes of roles before the deletes of the role templates they extend
+func (b *Backend) applyImport(ctx context.Context, req *logical.Request, items []importItem) (applied []string, err error) {
+	for _, item := range items {
+		if item.Action == importActionUnchanged {
+			continue
+		}
+
+		var resp *logical.Response
+		switch {
+		case item.Kind == PathConfigStorage && item.Action == importActionCreate:
+			resp, err = b.pathConfigWrite(ctx, req, &framework.FieldData{Raw: item.data, Schema: FieldSchemaConfig})
+		case item.Kind == PathConfigStorage:
+			resp, err = b.pathConfigPatch(ctx, req, &framework.FieldData{Raw: item.data, Schema: FieldSchemaConfig})
+		case item.Kind == PathRoleTemplateStorage && item.Action == importActionDelete:
+			resp, err = b.pathRoleTemplatesDelete(ctx, req, &framework.FieldData{Raw: map[string]any{"template_name": item.Name}, Schema: FieldSchemaRoleTemplates})
+		case item.Kind == PathRoleTemplateStorage:
+			resp, err = b.writeRoleTemplate(ctx, req, &framework.FieldData{Raw: item.data, Schema: FieldSchemaRoleTemplates}, false)
+		case item.Kind == PathRoleStorage && item.Action == importActionDelete:
+			resp, err = b.pathRolesDelete(ctx, req, &framework.FieldData{Raw: map[string]any{"role_name": item.Name}, Schema: FieldSchemaRoles})
+		case item.Kind == PathRoleStorage:
+			resp, err = b.pathRolesWrite(ctx, req, &framework.FieldData{Raw: item.data, Schema: FieldSchemaRoles})
+		}
+		if err == nil && resp.IsError() {
+			err = resp.Error()
+		}
+		if err != nil {
+			return applied, fmt.Errorf("%s/%s: %w", item.Kind, item.Name, err)
+		}
+		applied = append(applied, fmt.Sprintf("%s/%s", item.Kind, item.Name))
+	}
+	return applied, nil
+}
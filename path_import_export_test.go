@@ -0,0 +1,289 @@
+package gitlab_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathImportExport(t *testing.T) {
+	var client = newInMemoryClient(true)
+	ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+	b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+		"token":    "glpat-secret-random-token",
+		"base_url": "http://localhost:8080/",
+		"type":     gitlab.TypeSelfManaged.String(),
+	})
+	require.NoError(t, err)
+
+	var writeRole = func(t *testing.T, name string, accessLevel gitlab.AccessLevel) {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, name), Storage: l,
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         "{{ .role_name }}",
+				"token_type":   gitlab.TokenTypeProject.String(),
+				"access_level": accessLevel.String(),
+				"scopes":       gitlab.TokenScopeReadApi.String(),
+				"ttl":          "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	}
+
+	var export = func(t *testing.T, data map[string]any) gitlab.ExportDocument {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      gitlab.PathExport, Storage: l,
+			Data: data,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		doc, err := gitlab.ExportDocumentParse(resp.Data["document"].(string))
+		require.NoError(t, err)
+		return doc
+	}
+
+	var importDocument = func(t *testing.T, doc gitlab.ExportDocument, mode string) (*logical.Response, error) {
+		t.Helper()
+		out, err := doc.Marshal(gitlab.ExportFormatJSON)
+		require.NoError(t, err)
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      gitlab.PathImport, Storage: l,
+			Data: map[string]any{"document": string(out), "mode": mode},
+		})
+	}
+
+	var actions = func(resp *logical.Response) map[string]string {
+		var actions = map[string]string{}
+		for _, item := range resp.Data["items"].([]map[string]any) {
+			actions[fmt.Sprintf("%s/%s", item["kind"], item["name"])] = item["action"].(string)
+		}
+		return actions
+	}
+
+	writeRole(t, "first", gitlab.AccessLevelGuestPermissions)
+	writeRole(t, "second", gitlab.AccessLevelGuestPermissions)
+	events.resetEvents(t)
+
+	t.Run("export", func(t *testing.T) {
+		for _, format := range []string{gitlab.ExportFormatJSON, gitlab.ExportFormatYAML} {
+			var doc = export(t, map[string]any{"format": format})
+			require.Contains(t, doc.Configs, gitlab.DefaultConfigName)
+			require.NotContains(t, doc.Configs[gitlab.DefaultConfigName], "token")
+			require.NotContains(t, doc.Configs[gitlab.DefaultConfigName], "encrypted_credentials")
+			require.Len(t, doc.Roles, 2)
+			require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), doc.Roles["first"]["access_level"])
+		}
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      gitlab.PathExport, Storage: l,
+			Data: map[string]any{"format": "xml"},
+		})
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+	})
+
+	t.Run("export with encrypted credentials", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+
+		var doc = export(t, map[string]any{
+			"public_key": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+		})
+		var encrypted = doc.Configs[gitlab.DefaultConfigName]["encrypted_credentials"].(map[string]any)
+		ciphertext, err := base64.StdEncoding.DecodeString(encrypted["token"].(string))
+		require.NoError(t, err)
+		plaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, ciphertext, nil)
+		require.NoError(t, err)
+		require.EqualValues(t, "glpat-secret-random-token", string(plaintext))
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      gitlab.PathExport, Storage: l,
+			Data: map[string]any{"public_key": "invalid"},
+		})
+		require.ErrorIs(t, err, gitlab.ErrFieldInvalidValue)
+		require.Error(t, resp.Error())
+	})
+
+	t.Run("dry-run returns the changes", func(t *testing.T) {
+		var doc = export(t, nil)
+		doc.Roles["first"]["access_level"] = gitlab.AccessLevelDeveloperPermissions.String()
+		doc.Roles["third"] = doc.Roles["second"]
+
+		resp, err := importDocument(t, doc, gitlab.ImportModeDryRun)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 0, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"config/default": "unchanged",
+			"roles/first":    "update",
+			"roles/second":   "unchanged",
+			"roles/third":    "create",
+		}, actions(resp))
+
+		for _, item := range resp.Data["items"].([]map[string]any) {
+			if item["name"] == "first" {
+				require.Equal(t, map[string]any{
+					"access_level": map[string]any{
+						"old": gitlab.AccessLevelGuestPermissions.String(),
+						"new": gitlab.AccessLevelDeveloperPermissions.String(),
+					},
+				}, item["changes"])
+			}
+		}
+		require.Len(t, export(t, nil).Roles, 2)
+		events.expectEvents(t, nil)
+	})
+
+	t.Run("invalid items apply nothing", func(t *testing.T) {
+		var doc = export(t, nil)
+		doc.Roles["first"]["access_level"] = gitlab.AccessLevelDeveloperPermissions.String()
+		doc.Roles["invalid"] = map[string]any{
+			"path":       "example/example",
+			"token_type": gitlab.TokenTypeProject.String(),
+			"scopes":     "invalid",
+		}
+		doc.RoleTemplates["invalid"] = map[string]any{"access_level": "unknown"}
+
+		resp, err := importDocument(t, doc, gitlab.ImportModeMerge)
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+		require.ErrorContains(t, err, "roles/invalid")
+		require.ErrorContains(t, err, "role-templates/invalid")
+
+		var current = export(t, nil)
+		require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), current.Roles["first"]["access_level"])
+		require.Empty(t, current.RoleTemplates)
+		events.expectEvents(t, nil)
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      gitlab.PathImport, Storage: l,
+			Data: map[string]any{"document": "{"},
+		})
+		require.ErrorIs(t, err, gitlab.ErrInvalidDocument)
+		require.Error(t, resp.Error())
+	})
+
+	t.Run("config that fails the token check applies nothing", func(t *testing.T) {
+		var doc = export(t, nil)
+		doc.Configs[gitlab.DefaultConfigName]["base_url"] = "http://localhost:8081/"
+		doc.Roles["first"]["access_level"] = gitlab.AccessLevelDeveloperPermissions.String()
+
+		client.unavailable = true
+		resp, err := importDocument(t, doc, gitlab.ImportModeMerge)
+		client.unavailable = false
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+		require.ErrorContains(t, err, "config/default")
+
+		var current = export(t, nil)
+		require.EqualValues(t, "http://localhost:8080/", current.Configs[gitlab.DefaultConfigName]["base_url"])
+		require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), current.Roles["first"]["access_level"])
+		events.expectEvents(t, nil)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		var doc = export(t, nil)
+		doc.RoleTemplates["deploy"] = map[string]any{"ttl": 7200, "access_level": gitlab.AccessLevelReporterPermissions.String()}
+		doc.Roles["first"]["access_level"] = gitlab.AccessLevelDeveloperPermissions.String()
+		doc.Roles["third"] = map[string]any{
+			"path":       "example/example",
+			"name":       "{{ .role_name }}",
+			"token_type": gitlab.TokenTypeProject.String(),
+			"scopes":     []string{gitlab.TokenScopeReadApi.String()},
+			"extends":    "deploy",
+		}
+		delete(doc.Roles, "second")
+
+		resp, err := importDocument(t, doc, gitlab.ImportModeMerge)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 3, resp.Data["applied"])
+
+		var current = export(t, nil)
+		require.Len(t, current.Roles, 3)
+		require.EqualValues(t, gitlab.AccessLevelDeveloperPermissions.String(), current.Roles["first"]["access_level"])
+		require.EqualValues(t, "deploy", current.Roles["third"]["extends"])
+		require.NotContains(t, current.Roles["third"], "ttl")
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/role-template-write"},
+			{eventType: "gitlab/role-write"},
+			{eventType: "gitlab/role-write"},
+			{eventType: "gitlab/import"},
+		})
+		events.resetEvents(t)
+	})
+
+	t.Run("replace deletes roles not in the document", func(t *testing.T) {
+		var doc = export(t, nil)
+		delete(doc.Roles, "second")
+		delete(doc.Roles, "third")
+		delete(doc.RoleTemplates, "deploy")
+
+		resp, err := importDocument(t, doc, gitlab.ImportModeReplace)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 3, resp.Data["applied"])
+		require.Equal(t, map[string]string{
+			"config/default":        "unchanged",
+			"roles/first":           "unchanged",
+			"roles/second":          "delete",
+			"roles/third":           "delete",
+			"role-templates/deploy": "delete",
+		}, actions(resp))
+
+		var current = export(t, nil)
+		require.Len(t, current.Roles, 1)
+		require.Empty(t, current.RoleTemplates)
+		require.Contains(t, current.Configs, gitlab.DefaultConfigName)
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/role-delete"},
+			{eventType: "gitlab/role-delete"},
+			{eventType: "gitlab/role-template-delete"},
+			{eventType: "gitlab/import"},
+		})
+	})
+
+	t.Run("yaml document", func(t *testing.T) {
+		var doc = export(t, nil)
+		out, err := doc.Marshal(gitlab.ExportFormatYAML)
+		require.NoError(t, err)
+		require.False(t, json.Valid(out))
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      gitlab.PathImport, Storage: l,
+			Data: map[string]any{"document": string(out), "mode": gitlab.ImportModeDryRun},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Equal(t, map[string]string{
+			"config/default": "unchanged",
+			"roles/first":    "unchanged",
+		}, actions(resp))
+	})
+}
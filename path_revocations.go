@@ -0,0 +1,71 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRevocationsPending = "pending"
+
+	pathRevocationsPendingHelpSyn  = `Lists the revocations that failed and are retried`
+	pathRevocationsPendingHelpDesc = `
+This path lists the tokens that failed to be revoked when their lease ended, usually because GitLab was unavailable.
+The periodic function retries them with an exponential backoff until they are revoked, or abandons them after too many
+attempts, in which case the token only expires in GitLab.`
+)
+
+func pathRevocationsPending(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRevocationsPendingHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRevocationsPendingHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s/?$", PathRevocationStorage, PathRevocationsPending),
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "pending-revocations",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRevocationsPendingList,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "list",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathRevocationsPendingList(ctx context.Context, req *logical.Request, data *framework.FieldData) (lResp *logical.Response, err error) {
+	var configs []string
+	if configs, err = req.Storage.List(ctx, fmt.Sprintf("%s/", PathRevocationStorage)); err != nil {
+		return logical.ErrorResponse("Error listing pending revocations"), err
+	}
+
+	var keys []string
+	var keyInfo = make(map[string]any)
+	for _, name := range configs {
+		name = strings.TrimSuffix(name, "/")
+		var revocations []*EntryRevocation
+		if revocations, err = listRevocations(ctx, req.Storage, name); err != nil {
+			return logical.ErrorResponse("Error listing pending revocations"), err
+		}
+		for _, revocation := range revocations {
+			var key = fmt.Sprintf("%s/%d", name, revocation.Token.TokenID)
+			keys = append(keys, key)
+			keyInfo[key] = revocation.LogicalResponseData()
+		}
+	}
+
+	b.Logger().Debug("Pending", "revocations", keys)
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
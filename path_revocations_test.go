@@ -0,0 +1,133 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRevocationsPending(t *testing.T) {
+	var setup = func(t *testing.T) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient, *logical.Secret) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":                 "example/example",
+				"name":                 "project",
+				"token_type":           gitlab.TokenTypeProject.String(),
+				"access_level":         gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":               []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":                  "1h",
+				"gitlab_revokes_token": false,
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		client.projectAccessTokenRevokeError = true
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, resp.Secret.LeaseID), Storage: l,
+		})
+		require.Error(t, err)
+		events.resetEvents(t)
+		return ctx, b, l, events, client, resp.Secret
+	}
+
+	var listPending = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRevocationStorage, gitlab.PathRevocationsPending), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	t.Run("failed revocations are listed", func(t *testing.T) {
+		ctx, b, l, _, client, secret := setup(t)
+		resp := listPending(t, ctx, b, l)
+		var key = fmt.Sprintf("%s/%v", gitlab.DefaultConfigName, secret.InternalData["token_id"])
+		require.EqualValues(t, []string{key}, resp.Data["keys"])
+		var info = resp.Data["key_info"].(map[string]any)[key].(map[string]any)
+		require.EqualValues(t, 1, info["attempts"])
+		require.EqualValues(t, secret.LeaseID, info["lease_id"])
+		require.NotEmpty(t, info["last_error"])
+
+		// retried with a backoff
+		ctx = gitlab.WithStaticTime(ctx, time.Now().Add(gitlab.DefaultRevocationBackoffMin))
+		require.Error(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		info = listPending(t, ctx, b, l).Data["key_info"].(map[string]any)[key].(map[string]any)
+		require.EqualValues(t, 2, info["attempts"])
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.Len(t, client.accessTokens, 1)
+	})
+
+	t.Run("successful retry", func(t *testing.T) {
+		ctx, b, l, events, client, _ := setup(t)
+		client.projectAccessTokenRevokeError = false
+		ctx = gitlab.WithStaticTime(ctx, time.Now().Add(gitlab.DefaultRevocationBackoffMin))
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.Empty(t, client.accessTokens)
+		require.Empty(t, listPending(t, ctx, b, l).Data["keys"])
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/token-revoke-retry-succeeded"},
+		})
+	})
+
+	t.Run("vault retries the lease", func(t *testing.T) {
+		ctx, b, l, events, client, secret := setup(t)
+		client.projectAccessTokenRevokeError = false
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    secret,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, secret.LeaseID), Storage: l,
+		})
+		require.NoError(t, err)
+		require.Empty(t, listPending(t, ctx, b, l).Data["keys"])
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/token-revoke-retry-succeeded"},
+			{eventType: "gitlab/token-revoke"},
+		})
+	})
+
+	t.Run("abandoned after too many attempts", func(t *testing.T) {
+		ctx, b, l, events, client, _ := setup(t)
+		var now = time.Now()
+		for i := 1; i < gitlab.DefaultRevocationMaxAttempts; i++ {
+			now = now.Add(gitlab.DefaultRevocationBackoffMax)
+			require.Error(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, now), &logical.Request{Storage: l}))
+		}
+		require.Empty(t, listPending(t, ctx, b, l).Data["keys"])
+		require.Len(t, client.accessTokens, 1)
+		events.mu.Lock()
+		var last = events.eventsProcessed[len(events.eventsProcessed)-1]
+		events.mu.Unlock()
+		require.EqualValues(t, "gitlab/token-revoke-abandoned", last.EventType)
+	})
+}
@@ -3,9 +3,12 @@ package gitlab
 import (
 	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -91,6 +94,104 @@ var (
 				Name: "Gitlab revokes token.",
 			},
 		},
+		"test_interval": {
+			Type:        framework.TypeDurationSecond,
+			Required:    false,
+			Description: `How often the periodic function smoke tests the role by issuing and revoking a token, 0 disables it.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Test interval",
+			},
+		},
+		"enabled": {
+			Type:        framework.TypeBool,
+			Default:     true,
+			Required:    false,
+			Description: `Tokens can only be issued for an enabled role.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Enabled",
+			},
+		},
+		"not_before": {
+			Type:        framework.TypeTime,
+			Required:    false,
+			Description: `Tokens can't be issued for the role before this time, as RFC3339 or unix timestamp.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Not before",
+			},
+		},
+		"not_after": {
+			Type:        framework.TypeTime,
+			Required:    false,
+			Description: `Tokens can't be issued for the role after this time, as RFC3339 or unix timestamp.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Not after",
+			},
+		},
+		"availability_windows": {
+			Type:        framework.TypeCommaStringSlice,
+			Required:    false,
+			Description: `Recurring windows in which tokens can be issued, like 'mon-fri 08:00-18:00'. Empty means always.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Availability windows",
+			},
+		},
+		"availability_timezone": {
+			Type:        framework.TypeString,
+			Required:    false,
+			Description: `The timezone of the availability windows, defaults to UTC.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Availability timezone",
+			},
+		},
+		"description": {
+			Type:        framework.TypeString,
+			Required:    false,
+			Description: `What the role is used for.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Description",
+			},
+		},
+		"owner": {
+			Type:        framework.TypeString,
+			Required:    false,
+			Description: `Who owns the role, like a team or an email address.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Owner",
+			},
+		},
+		"tags": {
+			Type:        framework.TypeKVPairs,
+			Required:    false,
+			Description: `Arbitrary key=value tags of the role, available in the name template and the token events.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Tags",
+			},
+		},
+		"extends": {
+			Type:        framework.TypeString,
+			Required:    false,
+			Description: `The role template to inherit name, ttl, scopes, access_level and config_name from, when the role doesn't set them.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Extends",
+			},
+		},
+		"validate": {
+			Type:        framework.TypeBool,
+			Required:    false,
+			Description: `Validate the role against GitLab before it's written, defaults to validate_roles of the config.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Validate",
+			},
+		},
+		"check_path": {
+			Type:        framework.TypeBool,
+			Required:    false,
+			Default:     false,
+			Description: `Check on read if the group or project of the role was renamed or transferred, this calls GitLab.`,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Check path",
+			},
+		},
 		"config_name": {
 			Type:        framework.TypeString,
 			Default:     TypeConfigDefault,
@@ -108,8 +209,30 @@ func (b *Backend) pathRolesList(ctx context.Context, req *logical.Request, data
 	if err != nil {
 		return logical.ErrorResponse("Error listing roles"), err
 	}
-	b.Logger().Debug("Available", "roles", roles)
-	return logical.ListResponse(roles), nil
+
+	var tags = data.Get("tag").([]string)
+	var configName = data.Get("config_name").(string)
+	var tokenType = data.Get("token_type").(string)
+
+	var keys = make([]string, 0, len(roles))
+	var keyInfo = make(map[string]any)
+	for _, name := range roles {
+		var role *EntryRole
+		if role, _, err = getEffectiveRole(ctx, name, req.Storage); err != nil {
+			return logical.ErrorResponse("Error listing roles"), err
+		}
+		if role == nil ||
+			(configName != "" && cmp.Or(role.ConfigName, DefaultConfigName) != configName) ||
+			(tokenType != "" && role.TokenType.String() != tokenType) ||
+			!role.matchesTags(tags) {
+			continue
+		}
+		keys = append(keys, name)
+		keyInfo[name] = role.listInfo()
+	}
+
+	b.Logger().Debug("Available", "roles", keys)
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
 }
 
 func pathListRoles(b *Backend) *framework.Path {
@@ -121,6 +244,20 @@ func pathListRoles(b *Backend) *framework.Path {
 			OperationPrefix: operationPrefixGitlabAccessTokens,
 			OperationSuffix: "roles",
 		},
+		Fields: map[string]*framework.FieldSchema{
+			"tag": {
+				Type:        framework.TypeCommaStringSlice,
+				Description: "Only list the roles with all these tags, either key or key=value",
+			},
+			"config_name": {
+				Type:        framework.TypeString,
+				Description: "Only list the roles of this config",
+			},
+			"token_type": {
+				Type:        framework.TypeString,
+				Description: "Only list the roles of this token type",
+			},
+		},
 		Operations: map[logical.Operation]framework.OperationHandler{
 			logical.ListOperation: &framework.PathOperation{
 				Callback: b.pathRolesList,
@@ -150,14 +287,25 @@ func (b *Backend) pathRolesDelete(ctx context.Context, req *logical.Request, dat
 	}
 
 	lock := locksutil.LockForKey(b.roleLocks, roleName)
-	lock.RLock()
-	defer lock.RUnlock()
+	lock.Lock()
+	defer lock.Unlock()
+
+	b.lockClientMutex.RLock()
+	defer b.lockClientMutex.RUnlock()
 
-	_, err = getRole(ctx, roleName, req.Storage)
+	var role *EntryRole
+	role, err = getRole(ctx, roleName, req.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("error getting role: %w", err)
 	}
 
+	if role != nil {
+		// keep the deleted role in the history, so it can be restored with a rollback
+		if _, err = archiveRole(ctx, req.Storage, roleName, role, roleHistoryRetention(ctx, req.Storage, role.ConfigName)); err != nil {
+			return nil, fmt.Errorf("error archiving role: %w", err)
+		}
+	}
+
 	err = req.Storage.Delete(ctx, fmt.Sprintf("%s/%s", PathRoleStorage, roleName))
 	if err != nil {
 		return nil, fmt.Errorf("error deleting role: %w", err)
@@ -183,7 +331,7 @@ func (b *Backend) pathRolesRead(ctx context.Context, req *logical.Request, data
 	lock.RLock()
 	defer lock.RUnlock()
 
-	role, err := getRole(ctx, roleName, req.Storage)
+	role, inherited, err := getEffectiveRole(ctx, roleName, req.Storage)
 	if err != nil {
 		return logical.ErrorResponse("error reading role"), err
 	}
@@ -194,33 +342,112 @@ func (b *Backend) pathRolesRead(ctx context.Context, req *logical.Request, data
 
 	b.Logger().Debug("Role read", "role", roleName)
 
-	return &logical.Response{
-		Data: role.LogicalResponseData(),
-	}, nil
+	var resp = &logical.Response{Data: role.LogicalResponseData()}
+	resp.Data["inherited_fields"] = inherited
+	if role.PathId != 0 && data.Get("check_path").(bool) {
+		// check if the group or project was renamed or transferred since the role was written
+		b.lockClientMutex.RLock()
+		var config, er = getConfig(ctx, req.Storage, role.ConfigName)
+		b.lockClientMutex.RUnlock()
+		if er == nil && config == nil {
+			er = ErrBackendNotConfigured
+		}
+		var fullPath string
+		if er == nil {
+			_, fullPath, er = b.resolveRolePath(ctx, config, *role, strconv.Itoa(role.PathId))
+		}
+		if er != nil {
+			resp.AddWarning(fmt.Sprintf("unable to check the current full path of %s: %s", role.Path, er))
+		} else {
+			resp.Data["current_full_path"] = fullPath
+			resp.Data["path_moved"] = fullPath != role.FullPath
+			if fullPath != role.FullPath {
+				resp.AddWarning(fmt.Sprintf("the full path of %d changed from %s to %s, tokens are still issued for %d", role.PathId, role.FullPath, fullPath, role.PathId))
+			}
+		}
+	}
+
+	return resp, nil
 }
 
-func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+// resolveRolePath resolves the id or the path of the group or project of the role to the numeric id and full path.
+// Other token types don't have a path to resolve, and an id of 0 is returned.
+func (b *Backend) resolveRolePath(ctx context.Context, config *EntryConfig, role EntryRole, idOrPath string) (id int, fullPath string, err error) {
+	if role.TokenType != TokenTypeGroup && role.TokenType != TokenTypeProject {
+		return 0, "", nil
+	}
+
+	var client Client
+	if client, err = b.clientForConfig(ctx, config, capabilityForTokenType(role.TokenType)); err != nil {
+		return 0, "", err
+	}
+
+	if role.TokenType == TokenTypeGroup {
+		id, fullPath, err = client.ResolveGroup(ctx, idOrPath)
+	} else {
+		id, fullPath, err = client.ResolveProject(ctx, idOrPath)
+	}
+	return id, fullPath, mapGitlabError(err, fmt.Sprintf("resolve %s %s", role.TokenType, idOrPath), tokenPermissionHint(role.TokenType, idOrPath))
+}
+
+// roleWrite is a role write that passed all the checks and is ready to be stored
+type roleWrite struct {
+	config    *EntryConfig
+	role      EntryRole
+	stored    EntryRole
+	inherited []string
+	warnings  []string
+}
+
+// validateRoleWrite builds the role from the request and runs all the checks of a role write, the caller holds
+// lockClientMutex. The role is the effective role, merged with the template it extends, and stored is the role as it
+// is stored. When the write isn't valid the response or error to return is set instead.
+func (b *Backend) validateRoleWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (rw *roleWrite, resp *logical.Response, err error) {
+	if rw, resp, err = b.prepareRoleWrite(ctx, req, data); rw == nil {
+		return nil, resp, err
+	}
+	if resp, err = b.checkRoleWrite(ctx, rw, data); resp != nil || err != nil {
+		return nil, resp, err
+	}
+	return rw, nil, nil
+}
+
+// prepareRoleWrite builds the role from the request and runs the checks of a role write that don't need GitLab, the
+// caller holds lockClientMutex as the config and the template are read from the storage.
+func (b *Backend) prepareRoleWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (rw *roleWrite, resp *logical.Response, err error) {
 	var roleName string
 	if roleName = data.Get("role_name").(string); roleName == "" {
-		return logical.ErrorResponse("Unable to write, missing role name"), nil
+		return nil, logical.ErrorResponse("Unable to write, missing role name"), nil
 	}
 
 	var config *EntryConfig
-	var err error
 	var warnings []string
 	var tokenType TokenType
 	var accessLevel AccessLevel
 	var configName = cmp.Or(data.Get("config_name").(string), TypeConfigDefault)
 
-	b.lockClientMutex.RLock()
-	defer b.lockClientMutex.RUnlock()
+	var tmpl *EntryRoleTemplate
+	var extends = data.Get("extends").(string)
+	if extends != "" {
+		if tmpl, err = getRoleTemplate(ctx, extends, req.Storage); err != nil {
+			return nil, logical.ErrorResponse("error reading role template"), err
+		}
+		if tmpl == nil {
+			err = fmt.Errorf("extends='%s': %w", extends, ErrRoleTemplateNotFound)
+			return nil, logical.ErrorResponse(err.Error()), err
+		}
+		if _, ok := data.GetOk("config_name"); !ok {
+			configName = cmp.Or(tmpl.ConfigName, TypeConfigDefault)
+		}
+	}
+
 	config, err = getConfig(ctx, req.Storage, configName)
 	if err != nil {
-		return logical.ErrorResponse(fmt.Sprintf("missing %s configuration for gitlab", configName)), err
+		return nil, logical.ErrorResponse(fmt.Sprintf("missing %s configuration for gitlab", configName)), err
 	}
 
 	if config == nil {
-		return logical.ErrorResponse(ErrBackendNotConfigured.Error()), nil
+		return nil, logical.ErrorResponse(ErrBackendNotConfigured.Error()), nil
 	}
 
 	tokenType, _ = TokenTypeParse(data.Get("token_type").(string))
@@ -236,11 +463,41 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, data
 		TokenType:           tokenType,
 		GitlabRevokesTokens: data.Get("gitlab_revokes_token").(bool),
 		ConfigName:          configName,
+		TestInterval:        time.Duration(data.Get("test_interval").(int)) * time.Second,
+
+		Disabled:             !data.Get("enabled").(bool),
+		NotBefore:            data.Get("not_before").(time.Time),
+		NotAfter:             data.Get("not_after").(time.Time),
+		AvailabilityWindows:  data.Get("availability_windows").([]string),
+		AvailabilityTimezone: data.Get("availability_timezone").(string),
+
+		Description: data.Get("description").(string),
+		Owner:       data.Get("owner").(string),
+		Tags:        data.Get("tags").(map[string]string),
+		Extends:     extends,
+	}
+
+	// the fields the role doesn't set are inherited from the template, they are stored empty so changes to the
+	// template apply to the role when the tokens are issued
+	var stored = role
+	var inherited = []string{}
+	if tmpl != nil {
+		for _, field := range roleTemplateFields {
+			if _, ok := data.GetOk(field); !ok {
+				stored.clearField(field)
+			}
+		}
+		role = stored
+		inherited = append(inherited, tmpl.apply(&role)...)
+		accessLevel = role.AccessLevel
 	}
 
 	// validate name of the entry role
 	if _, e := template.New("name").Funcs(tplFuncMap).Parse(role.Name); e != nil {
 		err = multierror.Append(err, fmt.Errorf("invalid template %s for name: %w", role.Name, e))
+	} else if _, e = TokenNameWithData(&role, b.tokenNameData(req, &role)); e != nil {
+		// the request values differ per issuance, so a name that doesn't render now could still work later
+		warnings = append(warnings, fmt.Sprintf("name template %s doesn't render a valid token name for this request: %s", role.Name, e))
 	}
 
 	// validate token type
@@ -248,7 +505,8 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, data
 		err = multierror.Append(err, fmt.Errorf("token_type='%s', should be one of %v: %w", data.Get("token_type").(string), validTokenTypes, ErrFieldInvalidValue))
 	}
 
-	var skipFields = []string{"config_name"}
+	var skipFields = []string{"config_name", "validate", "check_path", "test_interval", "enabled", "not_before", "not_after", "availability_windows", "availability_timezone", "description", "owner", "tags", "extends"}
+	skipFields = append(skipFields, inherited...)
 
 	// validate access level
 	var validAccessLevels []string
@@ -289,16 +547,38 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, data
 		err = multierror.Append(err, fmt.Errorf("ttl = %s [ttl <= max_ttl = %s]: %w", role.TTL.String(), DefaultAccessTokenMaxPossibleTTL, ErrInvalidValue))
 	}
 
+	if maxLeaseTTL := b.System().MaxLeaseTTL(); maxLeaseTTL > 0 && role.TTL > maxLeaseTTL {
+		warnings = append(warnings, fmt.Sprintf("ttl = %s is above the max lease ttl of the mount %s, the tokens will be issued with a ttl of %s", role.TTL, maxLeaseTTL, maxLeaseTTL))
+	}
+
 	if role.GitlabRevokesTokens && role.TTL < 24*time.Hour {
 		err = multierror.Append(err, fmt.Errorf("ttl = %s [%s <= ttl <= %s]: %w", role.TTL, DefaultAccessTokenMinTTL, DefaultAccessTokenMaxPossibleTTL, ErrInvalidValue))
 	}
 
-	if !role.GitlabRevokesTokens && role.TTL < time.Hour {
-		err = multierror.Append(err, fmt.Errorf("ttl = %s [ttl >= 1h]: %w", role.TTL, ErrInvalidValue))
+	if minTTL := config.MinVaultRevokedTTL(); !role.GitlabRevokesTokens && role.TTL < minTTL {
+		err = multierror.Append(err, fmt.Errorf("ttl = %s [ttl >= %s]: %w", role.TTL, shortDuration(minTTL), ErrInvalidValue))
+	}
+
+	if role.TestInterval != 0 && role.TestInterval < DefaultRoleTestMinInterval {
+		err = multierror.Append(err, fmt.Errorf("test_interval = %s [test_interval = 0 or test_interval >= %s]: %w", role.TestInterval, shortDuration(DefaultRoleTestMinInterval), ErrInvalidValue))
+	}
+
+	if !role.NotBefore.IsZero() && !role.NotAfter.IsZero() && !role.NotAfter.After(role.NotBefore) {
+		err = multierror.Append(err, fmt.Errorf("not_after = %s [not_after > not_before = %s]: %w", role.NotAfter.Format(time.RFC3339), role.NotBefore.Format(time.RFC3339), ErrFieldInvalidValue))
+	}
+
+	for _, window := range role.AvailabilityWindows {
+		if _, e := AvailabilityWindowParse(window); e != nil {
+			err = multierror.Append(err, fmt.Errorf("availability_windows: %w", e))
+		}
+	}
+
+	if _, e := time.LoadLocation(role.AvailabilityTimezone); e != nil {
+		err = multierror.Append(err, fmt.Errorf("availability_timezone='%s': %w", role.AvailabilityTimezone, ErrFieldInvalidValue))
 	}
 
 	if !slices.Contains(validAccessLevels, accessLevel.String()) {
-		err = multierror.Append(err, fmt.Errorf("access_level='%s', should be one of %v: %w", data.Get("access_level").(string), validAccessLevels, ErrFieldInvalidValue))
+		err = multierror.Append(err, fmt.Errorf("access_level='%s', should be one of %v: %w", cmp.Or(data.Get("access_level").(string), accessLevel.String()), validAccessLevels, ErrFieldInvalidValue))
 	}
 
 	// validate scopes
@@ -327,34 +607,154 @@ func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, data
 		err = multierror.Append(err, fmt.Errorf("cannot create %s with %s: %w", tokenType, config.Type, ErrInvalidValue))
 	}
 
+	if !config.CanCreate(tokenType) {
+		err = multierror.Append(err, fmt.Errorf("cannot create %s with a %s access token: %w", tokenType, config.tokenKindFor(tokenType), ErrInvalidValue))
+	}
+
+	if er := config.CheckRole(role); er != nil {
+		err = multierror.Append(err, er)
+	}
+
 	if err != nil {
-		return logical.ErrorResponse(err.Error()), err
+		return nil, logical.ErrorResponse(err.Error()), err
+	}
+
+	return &roleWrite{config: config, role: role, stored: stored, inherited: inherited, warnings: warnings}, nil, nil
+}
+
+// checkRoleWrite pins the path of the prepared role and validates it against GitLab, it doesn't need lockClientMutex
+// so slow GitLab requests don't hold up config writes.
+func (b *Backend) checkRoleWrite(ctx context.Context, rw *roleWrite, data *framework.FieldData) (resp *logical.Response, err error) {
+	var validate = rw.config.ValidateRoles
+	if val, ok := data.GetOk("validate"); ok {
+		validate = val.(bool)
 	}
 
+	// pin the numeric id, so the role keeps working when the group or project is renamed or transferred
+	if pinned, ok := data.Raw[rawKeyPinnedPath].(pinnedPath); ok {
+		rw.role.PathId, rw.role.FullPath = pinned.id, pinned.fullPath
+	} else if rw.role.PathId, rw.role.FullPath, err = b.resolveRolePath(ctx, rw.config, rw.role, rw.role.Path); err != nil {
+		if validate || errors.Is(err, ErrGitlabNotFound) {
+			return logical.ErrorResponse(err.Error()), err
+		}
+		b.Logger().Warn("Failed to resolve the path of the role, the path is used as is", "role_name", rw.role.RoleName, "path", rw.role.Path, "error", err)
+		err = nil
+	}
+
+	if validate {
+		var w []string
+		w, err = b.validateRole(ctx, rw.config, rw.role)
+		rw.warnings = append(rw.warnings, w...)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), err
+		}
+	}
+	return nil, nil
+}
+
+func (b *Backend) pathRolesWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName string
+	if roleName = data.Get("role_name").(string); roleName == "" {
+		return logical.ErrorResponse("Unable to write, missing role name"), nil
+	}
+
+	// the role is locked while it's validated, so concurrent writes are checked one after the other
 	lock := locksutil.LockForKey(b.roleLocks, roleName)
 	lock.Lock()
 	defer lock.Unlock()
 
-	entry, err := logical.StorageEntryJSON(fmt.Sprintf("%s/%s", PathRoleStorage, role.RoleName), role)
+	return b.writeRole(ctx, req, roleName, data)
+}
+
+func (b *Backend) pathRolesPatch(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName string
+	if roleName = data.Get("role_name").(string); roleName == "" {
+		return logical.ErrorResponse("Unable to write, missing role name"), nil
+	}
+
+	// the role is locked while it's validated, so a concurrent write isn't lost by the patch
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := getRole(ctx, roleName, req.Storage)
 	if err != nil {
+		return nil, fmt.Errorf("error getting role: %w", err)
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %s not found", roleName)), nil
+	}
+
+	return b.writeRole(ctx, req, roleName, role.Merge(data))
+}
+
+// writeRole validates and saves the role, the caller holds the lock of the role. The config is read under
+// lockClientMutex, which is released while the role is checked against GitLab.
+func (b *Backend) writeRole(ctx context.Context, req *logical.Request, roleName string, data *framework.FieldData) (*logical.Response, error) {
+	b.lockClientMutex.RLock()
+	var rw, resp, err = b.prepareRoleWrite(ctx, req, data)
+	b.lockClientMutex.RUnlock()
+	if rw == nil {
+		return resp, err
+	}
+
+	if resp, err = b.checkRoleWrite(ctx, rw, data); resp != nil || err != nil {
+		return resp, err
+	}
+
+	b.lockClientMutex.RLock()
+	defer b.lockClientMutex.RUnlock()
+	return b.saveRoleWrite(ctx, req, roleName, rw)
+}
+
+// saveRoleWrite archives the previous version of the role and saves the validated role, the caller holds
+// lockClientMutex and the lock of the role
+func (b *Backend) saveRoleWrite(ctx context.Context, req *logical.Request, roleName string, rw *roleWrite) (resp *logical.Response, err error) {
+	var config, role, stored = rw.config, rw.role, rw.stored
+
+	var previous *EntryRole
+	if previous, err = getRole(ctx, roleName, req.Storage); err != nil {
+		return nil, fmt.Errorf("error getting role: %w", err)
+	}
+
+	var before map[string]any
+	if previous != nil {
+		before = previous.exportData()
+	}
+	var changes = diffExportData(before, stored.exportData())
+
+	if stored.Version, err = archiveRole(ctx, req.Storage, roleName, previous, config.RoleHistoryRetention); err != nil {
+		return nil, fmt.Errorf("error archiving role: %w", err)
+	}
+	stored.UpdatedAt, stored.UpdatedBy = TimeFromContext(ctx).UTC(), req.EntityID
+	stored.PathId, stored.FullPath = role.PathId, role.FullPath
+
+	if err = saveRole(ctx, stored, req.Storage); err != nil {
 		return nil, err
 	}
+	role.Version, role.UpdatedAt, role.UpdatedBy = stored.Version, stored.UpdatedAt, stored.UpdatedBy
 
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	var changeSet []byte
+	if changeSet, err = json.Marshal(changes); err != nil {
 		return nil, err
 	}
 
 	event(ctx, b.Backend, "role-write", map[string]string{
 		"path":      "roles",
 		"role_name": roleName,
+		"version":   strconv.Itoa(role.Version),
+		"changes":   string(changeSet),
 	})
 
-	b.Logger().Debug("Role written", "role", roleName)
+	b.Logger().Debug("Role written", "role", roleName, "changes", changes)
 
-	return &logical.Response{
+	resp = &logical.Response{
 		Data:     role.LogicalResponseData(),
-		Warnings: warnings,
-	}, nil
+		Warnings: rw.warnings,
+	}
+	resp.Data["inherited_fields"] = rw.inherited
+	resp.Data["changes"] = changes
+	return resp, nil
 }
 
 func (b *Backend) pathRoleExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
@@ -383,6 +783,15 @@ func pathRoles(b *Backend) *framework.Path {
 			OperationSuffix: "role",
 		},
 		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.PatchOperation: &framework.PathOperation{
+				Callback: b.pathRolesPatch,
+				Summary:  "Updates the fields of an existing role that are set",
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Fields: FieldSchemaRoles,
+					}},
+				},
+			},
 			logical.DeleteOperation: &framework.PathOperation{
 				Callback: b.pathRolesDelete,
 				Summary:  "Deletes a role",
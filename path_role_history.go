@@ -0,0 +1,244 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRoleRollback = "rollback"
+
+	pathRoleHistoryHelpSyn  = `Previous versions of a role`
+	pathRoleHistoryHelpDesc = `
+Every write, delete and rollback of a role keeps the previous version of the role, together with when and by which
+entity it was written. This path lists and reads the kept versions, the oldest are removed once there are more than
+role_history_retention of the config.`
+	pathRoleRollbackHelpSyn  = `Roll back a role to a previous version`
+	pathRoleRollbackHelpDesc = `
+This path restores a previous version of the role from the history as a new version. The current role, if any, is kept
+in the history so the rollback itself can be reverted. A deleted role can be restored the same way.`
+)
+
+var fieldSchemaRoleVersion = &framework.FieldSchema{
+	Type:        framework.TypeInt,
+	Required:    true,
+	Description: "Version of the role",
+	DisplayAttrs: &framework.DisplayAttributes{
+		Name: "Version",
+	},
+}
+
+func pathListRoleHistory(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRoleHistoryHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRoleHistoryHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s/?$", PathRoleHistoryStorage, framework.GenericNameRegex("role_name")),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": FieldSchemaRoles["role_name"],
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-history",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRoleHistoryList,
+				Summary:  "Lists the previous versions of a role",
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "list",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func pathRoleHistory(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRoleHistoryHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRoleHistoryHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s/(?P<version>\\d+)$", PathRoleHistoryStorage, framework.GenericNameRegex("role_name")),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": FieldSchemaRoles["role_name"],
+			"version":   fieldSchemaRoleVersion,
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-version",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRoleHistoryRead,
+				Summary:  "Reads a previous version of a role",
+				Responses: map[int][]framework.Response{
+					http.StatusNotFound: {{
+						Description: http.StatusText(http.StatusNotFound),
+					}},
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func pathRoleRollback(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRoleRollbackHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRoleRollbackHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s/%s$", PathRoleStorage, framework.GenericNameRegex("role_name"), PathRoleRollback),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": FieldSchemaRoles["role_name"],
+			"version":   fieldSchemaRoleVersion,
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-rollback",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRoleRollback,
+				Summary:  "Restores a previous version of a role",
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "rollback",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Fields: FieldSchemaRoles,
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathRoleHistoryList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName = data.Get("role_name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	versions, err := listRoleVersions(ctx, req.Storage, roleName)
+	if err != nil {
+		return logical.ErrorResponse("Error listing role versions"), err
+	}
+
+	var keys = make([]string, 0, len(versions))
+	var keyInfo = make(map[string]any)
+	for _, version := range versions {
+		var role *EntryRole
+		if role, err = getRoleVersion(ctx, req.Storage, roleName, version); err != nil {
+			return logical.ErrorResponse("Error listing role versions"), err
+		}
+		if role == nil {
+			continue
+		}
+		var key = strconv.Itoa(version)
+		keys = append(keys, key)
+		keyInfo[key] = map[string]any{
+			"updated_at":   formatOptionalTime(role.UpdatedAt),
+			"updated_by":   role.UpdatedBy,
+			"config_name":  role.ConfigName,
+			"token_type":   role.TokenType.String(),
+			"path":         role.Path,
+			"scopes":       role.Scopes,
+			"access_level": role.AccessLevel.String(),
+		}
+	}
+
+	b.Logger().Debug("Role history", "role", roleName, "versions", keys)
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *Backend) pathRoleHistoryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName = data.Get("role_name").(string)
+	var version = data.Get("version").(int)
+
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	role, err := getRoleVersion(ctx, req.Storage, roleName, version)
+	if err != nil {
+		return logical.ErrorResponse("error reading role version"), err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{Data: role.LogicalResponseData()}, nil
+}
+
+func (b *Backend) pathRoleRollback(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName = data.Get("role_name").(string)
+	var version = data.Get("version").(int)
+
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.Lock()
+	defer lock.Unlock()
+
+	role, err := getRoleVersion(ctx, req.Storage, roleName, version)
+	if err != nil {
+		return logical.ErrorResponse("error reading role version"), err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("version %d of role %s not found", version, roleName)), nil
+	}
+
+	// the version is checked like a write of its fields, the config, the template it extends and the checks may have
+	// changed since the version was written
+	var fields = role.Merge(&framework.FieldData{Raw: map[string]any{}, Schema: FieldSchemaRoles})
+	b.lockClientMutex.RLock()
+	var rw, resp, er = b.prepareRoleWrite(ctx, req, fields)
+	b.lockClientMutex.RUnlock()
+	if rw == nil {
+		return resp, er
+	}
+	if resp, err = b.checkRoleWrite(ctx, rw, fields); resp != nil || err != nil {
+		return resp, err
+	}
+
+	b.lockClientMutex.RLock()
+	defer b.lockClientMutex.RUnlock()
+
+	var current *EntryRole
+	if current, err = getRole(ctx, roleName, req.Storage); err != nil {
+		return nil, fmt.Errorf("error getting role: %w", err)
+	}
+
+	var stored, effective = rw.stored, rw.role
+	if stored.Version, err = archiveRole(ctx, req.Storage, roleName, current, rw.config.RoleHistoryRetention); err != nil {
+		return nil, fmt.Errorf("error archiving role: %w", err)
+	}
+	stored.UpdatedAt, stored.UpdatedBy = TimeFromContext(ctx).UTC(), req.EntityID
+	stored.PathId, stored.FullPath = effective.PathId, effective.FullPath
+
+	if err = saveRole(ctx, stored, req.Storage); err != nil {
+		return nil, err
+	}
+
+	event(ctx, b.Backend, "role-rollback", map[string]string{
+		"path":             "roles",
+		"role_name":        roleName,
+		"version":          strconv.Itoa(stored.Version),
+		"rollback_version": strconv.Itoa(version),
+	})
+
+	effective.Version, effective.UpdatedAt, effective.UpdatedBy = stored.Version, stored.UpdatedAt, stored.UpdatedBy
+
+	b.Logger().Debug("Role rolled back", "role", roleName, "version", version)
+	return &logical.Response{Data: effective.LogicalResponseData(), Warnings: rw.warnings}, nil
+}
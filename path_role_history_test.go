@@ -0,0 +1,166 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRolesHistory(t *testing.T) {
+	var now = time.Date(2024, 12, 9, 12, 0, 0, 0, time.UTC)
+
+	var setup = func(t *testing.T, retention int) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender) {
+		t.Helper()
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+		b, l, events, err := getBackendWithEventsAndConfig(gitlab.WithStaticTime(ctx, now), map[string]any{
+			"token":                  "glpat-secret-random-token",
+			"base_url":               "http://localhost:8080/",
+			"type":                   gitlab.TypeSelfManaged.String(),
+			"role_history_retention": retention,
+		})
+		require.NoError(t, err)
+		return gitlab.WithStaticTime(ctx, now), b, l, events
+	}
+
+	var writeRole = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage, accessLevel gitlab.AccessLevel) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			EntityID: "entity",
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         "{{ .role_name }}",
+				"token_type":   gitlab.TokenTypeProject.String(),
+				"access_level": accessLevel.String(),
+				"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":          "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	var listVersions = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) []string {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleHistoryStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		if resp.Data["keys"] == nil {
+			return nil
+		}
+		return resp.Data["keys"].([]string)
+	}
+
+	var rollback = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, version int) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/test/%s", gitlab.PathRoleStorage, gitlab.PathRoleRollback), Storage: l,
+			Data: map[string]any{"version": version},
+		})
+	}
+
+	t.Run("writes keep the previous versions", func(t *testing.T) {
+		ctx, b, l, events := setup(t, 0)
+		resp := writeRole(t, ctx, b, l, gitlab.AccessLevelGuestPermissions)
+		require.EqualValues(t, 1, resp.Data["version"])
+		require.EqualValues(t, "entity", resp.Data["updated_by"])
+		require.EqualValues(t, now.Format(time.RFC3339), resp.Data["updated_at"])
+		require.Empty(t, listVersions(t, ctx, b, l))
+
+		resp = writeRole(t, ctx, b, l, gitlab.AccessLevelOwnerPermissions)
+		require.EqualValues(t, 2, resp.Data["version"])
+		require.EqualValues(t, []string{"1"}, listVersions(t, ctx, b, l))
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test/1", gitlab.PathRoleHistoryStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), resp.Data["access_level"])
+		require.EqualValues(t, 1, resp.Data["version"])
+
+		events.resetEvents(t)
+		resp, err = rollback(ctx, b, l, 1)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 3, resp.Data["version"])
+		require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), resp.Data["access_level"])
+		require.EqualValues(t, []string{"1", "2"}, listVersions(t, ctx, b, l))
+		events.expectEvents(t, []expectedEvent{{eventType: "gitlab/role-rollback"}})
+
+		resp, err = rollback(ctx, b, l, 10)
+		require.NoError(t, err)
+		require.ErrorContains(t, resp.Error(), "version 10 of role test not found")
+	})
+
+	t.Run("retention", func(t *testing.T) {
+		ctx, b, l, _ := setup(t, 2)
+		for range 5 {
+			writeRole(t, ctx, b, l, gitlab.AccessLevelGuestPermissions)
+		}
+		require.EqualValues(t, []string{"3", "4"}, listVersions(t, ctx, b, l))
+	})
+
+	t.Run("deleted role can be restored", func(t *testing.T) {
+		ctx, b, l, _ := setup(t, 0)
+		writeRole(t, ctx, b, l, gitlab.AccessLevelGuestPermissions)
+		writeRole(t, ctx, b, l, gitlab.AccessLevelDeveloperPermissions)
+
+		_, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"1", "2"}, listVersions(t, ctx, b, l))
+
+		resp, err := rollback(ctx, b, l, 2)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 3, resp.Data["version"])
+		require.EqualValues(t, gitlab.AccessLevelDeveloperPermissions.String(), resp.Data["access_level"])
+	})
+
+	t.Run("rollback is checked like a write", func(t *testing.T) {
+		ctx, b, l, events := setup(t, 0)
+		writeRole(t, ctx, b, l, gitlab.AccessLevelGuestPermissions)
+		writeRole(t, ctx, b, l, gitlab.AccessLevelDeveloperPermissions)
+
+		// the ttl of the first version is below the new minimum of the config
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+			Data: map[string]any{"vault_revoked_min_ttl": "2h"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		events.resetEvents(t)
+
+		resp, err = rollback(ctx, b, l, 1)
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.Error(t, resp.Error())
+		require.EqualValues(t, []string{"1"}, listVersions(t, ctx, b, l))
+		events.expectEvents(t, nil)
+	})
+
+	t.Run("negative retention is rejected", func(t *testing.T) {
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+		_, _, err := getBackendWithConfig(ctx, map[string]any{
+			"token":                  "glpat-secret-random-token",
+			"base_url":               "http://localhost:8080/",
+			"type":                   gitlab.TypeSelfManaged.String(),
+			"role_history_retention": -1,
+		})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+	})
+}
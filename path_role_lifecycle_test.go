@@ -0,0 +1,141 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRolesLifecycle(t *testing.T) {
+	// 2024-12-09 is a monday
+	var now = time.Date(2024, 12, 9, 12, 0, 0, 0, time.UTC)
+
+	var setup = func(t *testing.T) (context.Context, *gitlab.Backend, logical.Storage) {
+		t.Helper()
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+		b, l, err := getBackendWithConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+		return ctx, b, l
+	}
+
+	var writeRole = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, data map[string]any) (*logical.Response, error) {
+		var role = map[string]any{
+			"path":         "example/example",
+			"name":         "project",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelGuestPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		}
+		for k, v := range data {
+			role[k] = v
+		}
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: role,
+		})
+	}
+
+	var issue = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, at time.Time) (*logical.Response, error) {
+		return b.HandleRequest(gitlab.WithStaticTime(ctx, at), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+	}
+
+	t.Run("roles are enabled by default", func(t *testing.T) {
+		ctx, b, l := setup(t)
+		resp, err := writeRole(ctx, b, l, nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.True(t, resp.Data["enabled"].(bool))
+		require.Empty(t, resp.Warnings)
+
+		resp, err = issue(ctx, b, l, now)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+	})
+
+	t.Run("disabled role", func(t *testing.T) {
+		ctx, b, l := setup(t)
+		resp, err := writeRole(ctx, b, l, map[string]any{"enabled": false})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = issue(ctx, b, l, now)
+		require.ErrorIs(t, err, gitlab.ErrRoleNotAvailable)
+		require.ErrorContains(t, resp.Error(), "role test is disabled")
+	})
+
+	t.Run("validity range", func(t *testing.T) {
+		ctx, b, l := setup(t)
+		resp, err := writeRole(ctx, b, l, map[string]any{
+			"not_before": now.Format(time.RFC3339),
+			"not_after":  now.Add(24 * time.Hour).Format(time.RFC3339),
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		_, err = issue(ctx, b, l, now.Add(-time.Minute))
+		require.ErrorIs(t, err, gitlab.ErrRoleNotAvailable)
+
+		resp, err = issue(ctx, b, l, now)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		resp, err = issue(ctx, b, l, now.Add(24*time.Hour))
+		require.ErrorIs(t, err, gitlab.ErrRoleNotAvailable)
+		require.ErrorContains(t, resp.Error(), "role test expired at 2024-12-10T12:00:00Z")
+	})
+
+	t.Run("availability windows", func(t *testing.T) {
+		ctx, b, l := setup(t)
+		resp, err := writeRole(ctx, b, l, map[string]any{
+			"availability_windows":  "mon-fri 08:00-18:00",
+			"availability_timezone": "Europe/Amsterdam",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		// 12:00 UTC is 13:00 in Amsterdam
+		resp, err = issue(ctx, b, l, now)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+
+		// 17:30 UTC is 18:30 in Amsterdam
+		_, err = issue(ctx, b, l, now.Add(5*time.Hour+30*time.Minute))
+		require.ErrorIs(t, err, gitlab.ErrRoleNotAvailable)
+
+		// saturday
+		resp, err = issue(ctx, b, l, now.AddDate(0, 0, 5))
+		require.ErrorIs(t, err, gitlab.ErrRoleNotAvailable)
+		require.ErrorContains(t, resp.Error(), "role test is only available mon-fri 08:00-18:00 in Europe/Amsterdam")
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		ctx, b, l := setup(t)
+		resp, err := writeRole(ctx, b, l, map[string]any{
+			"not_before":            now.Format(time.RFC3339),
+			"not_after":             now.Add(-time.Hour).Format(time.RFC3339),
+			"availability_windows":  "someday 08:00-18:00",
+			"availability_timezone": "Mars/Olympus_Mons",
+		})
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+		var merr *multierror.Error
+		require.ErrorAs(t, err, &merr)
+		require.Len(t, merr.Errors, 3)
+	})
+}
@@ -0,0 +1,136 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRolesMetadata(t *testing.T) {
+	ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+	b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+		"token":    "glpat-secret-random-token",
+		"base_url": "http://localhost:8080/",
+		"type":     gitlab.TypeSelfManaged.String(),
+	})
+	require.NoError(t, err)
+
+	var writeRole = func(ctx context.Context, name string, data map[string]any) {
+		t.Helper()
+		var role = map[string]any{
+			"path":         "example/example",
+			"name":         "{{ .role_name }}-{{ .tags.team }}",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelGuestPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		}
+		for k, v := range data {
+			role[k] = v
+		}
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, name), Storage: l,
+			Data: role,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Empty(t, resp.Warnings)
+	}
+
+	writeRole(ctx, "ci-deploy", map[string]any{
+		"description": "Deploys the api",
+		"owner":       "platform@example.com",
+		"tags":        map[string]string{"team": "platform", "env": "prod"},
+	})
+	writeRole(ctx, "ci-test", map[string]any{
+		"tags": map[string]string{"team": "qa", "env": "prod"},
+	})
+	writeRole(ctx, "personal", map[string]any{
+		"path":         "admin-user",
+		"name":         "{{ .role_name }}",
+		"token_type":   gitlab.TokenTypePersonal.String(),
+		"access_level": "",
+	})
+
+	t.Run("read role", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/ci-deploy", gitlab.PathRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, "Deploys the api", resp.Data["description"])
+		require.EqualValues(t, "platform@example.com", resp.Data["owner"])
+		require.EqualValues(t, map[string]string{"team": "platform", "env": "prod"}, resp.Data["tags"])
+	})
+
+	t.Run("tags in name and event", func(t *testing.T) {
+		events.resetEvents(t)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/ci-deploy", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "ci-deploy-platform", resp.Data["name"])
+
+		events.expectEvents(t, []expectedEvent{{eventType: "gitlab/token-write"}})
+		var metadata = events.eventsProcessed[0].Event.Metadata.AsMap()
+		require.EqualValues(t, "platform@example.com", metadata["owner"])
+		require.EqualValues(t, "env=prod,team=platform", metadata["tags"])
+	})
+
+	var list = func(t *testing.T, data map[string]any) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      gitlab.PathRoleStorage, Storage: l,
+			Data: data,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	t.Run("detailed list", func(t *testing.T) {
+		resp := list(t, nil)
+		require.ElementsMatch(t, []string{"ci-deploy", "ci-test", "personal"}, resp.Data["keys"])
+		var info = resp.Data["key_info"].(map[string]any)["ci-deploy"].(map[string]any)
+		require.EqualValues(t, "Deploys the api", info["description"])
+		require.EqualValues(t, "platform@example.com", info["owner"])
+		require.EqualValues(t, gitlab.TokenTypeProject.String(), info["token_type"])
+		require.EqualValues(t, gitlab.DefaultConfigName, info["config_name"])
+		require.EqualValues(t, "example/example", info["path"])
+		require.EqualValues(t, true, info["enabled"])
+	})
+
+	t.Run("filtered list", func(t *testing.T) {
+		var tests = []struct {
+			data     map[string]any
+			expected []string
+		}{
+			{map[string]any{"tag": "env=prod"}, []string{"ci-deploy", "ci-test"}},
+			{map[string]any{"tag": "env=prod,team=qa"}, []string{"ci-test"}},
+			{map[string]any{"tag": "team"}, []string{"ci-deploy", "ci-test"}},
+			{map[string]any{"tag": "env=dev"}, []string{}},
+			{map[string]any{"token_type": gitlab.TokenTypePersonal.String()}, []string{"personal"}},
+			{map[string]any{"config_name": gitlab.DefaultConfigName}, []string{"ci-deploy", "ci-test", "personal"}},
+			{map[string]any{"config_name": "other"}, []string{}},
+		}
+		for _, test := range tests {
+			t.Run(fmt.Sprint(test.data), func(t *testing.T) {
+				resp := list(t, test.data)
+				if len(test.expected) == 0 {
+					require.Empty(t, resp.Data["keys"])
+					return
+				}
+				require.ElementsMatch(t, test.expected, resp.Data["keys"])
+			})
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package gitlab_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRolesPatch(t *testing.T) {
+	var client = newInMemoryClient(true)
+	ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+	b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+		"token":    "glpat-secret-random-token",
+		"base_url": "http://localhost:8080/",
+		"type":     gitlab.TypeSelfManaged.String(),
+	})
+	require.NoError(t, err)
+
+	var patch = func(t *testing.T, name string, data map[string]any) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, name), Storage: l,
+			Data: data,
+		})
+	}
+
+	t.Run("missing role", func(t *testing.T) {
+		resp, err := patch(t, "missing", map[string]any{"ttl": "72h"})
+		require.NoError(t, err)
+		require.Error(t, resp.Error())
+	})
+
+	resp, err := b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.CreateOperation,
+		Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+		Data: map[string]any{
+			"path":                 "example/example",
+			"name":                 "{{ .role_name }}",
+			"token_type":           gitlab.TokenTypeProject.String(),
+			"access_level":         gitlab.AccessLevelGuestPermissions.String(),
+			"scopes":               gitlab.TokenScopeReadApi.String(),
+			"ttl":                  "48h",
+			"gitlab_revokes_token": true,
+			"description":          "deploys",
+			"tags":                 map[string]string{"team": "platform"},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Error())
+	events.resetEvents(t)
+
+	t.Run("keeps the fields that aren't set", func(t *testing.T) {
+		resp, err := patch(t, "test", map[string]any{
+			"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 2, resp.Data["version"])
+		require.EqualValues(t, gitlab.AccessLevelDeveloperPermissions.String(), resp.Data["access_level"])
+		require.EqualValues(t, true, resp.Data["gitlab_revokes_token"])
+		require.EqualValues(t, gitlab.DefaultConfigName, resp.Data["config_name"])
+		require.EqualValues(t, 172800, resp.Data["ttl"])
+		require.EqualValues(t, "deploys", resp.Data["description"])
+		require.EqualValues(t, map[string]any{
+			"access_level": map[string]any{
+				"old": gitlab.AccessLevelGuestPermissions.String(),
+				"new": gitlab.AccessLevelDeveloperPermissions.String(),
+			},
+		}, resp.Data["changes"])
+
+		events.expectEvents(t, []expectedEvent{{eventType: "gitlab/role-write"}})
+		var metadata = events.eventsProcessed[0].Event.Metadata.AsMap()
+		var changes map[string]any
+		require.NoError(t, json.Unmarshal([]byte(metadata["changes"].(string)), &changes))
+		require.Contains(t, changes, "access_level")
+		require.Len(t, changes, 1)
+		events.resetEvents(t)
+	})
+
+	t.Run("is validated like a write", func(t *testing.T) {
+		resp, err := patch(t, "test", map[string]any{
+			"scopes":       "invalid",
+			"access_level": "unknown",
+		})
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+
+		resp, err = patch(t, "test", map[string]any{"token_type": gitlab.TokenTypeUserServiceAccount.String()})
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, 2, resp.Data["version"])
+		require.EqualValues(t, gitlab.AccessLevelDeveloperPermissions.String(), resp.Data["access_level"])
+		events.expectEvents(t, nil)
+	})
+
+	t.Run("keeps the pinned path", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		var pathId = resp.Data["path_id"].(int)
+		require.NotZero(t, pathId)
+
+		client.fullPaths[pathId] = "moved/example"
+		t.Cleanup(func() { client.fullPaths[pathId] = "example/example" })
+
+		resp, err = patch(t, "test", map[string]any{"description": "deploys to production"})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, pathId, resp.Data["path_id"])
+		require.EqualValues(t, "example/example", resp.Data["full_path"])
+		events.resetEvents(t)
+	})
+
+	t.Run("clears fields set to empty values", func(t *testing.T) {
+		resp, err := patch(t, "test", map[string]any{"description": "", "ttl": "72h"})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "", resp.Data["description"])
+		require.EqualValues(t, 259200, resp.Data["ttl"])
+		require.EqualValues(t, map[string]string{"team": "platform"}, resp.Data["tags"])
+
+		var changes = resp.Data["changes"].(map[string]any)
+		require.Len(t, changes, 2)
+		require.Contains(t, changes, "description")
+		require.Contains(t, changes, "ttl")
+	})
+}
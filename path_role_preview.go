@@ -0,0 +1,158 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRolePreview = "preview"
+
+	pathRolePreviewHelpSyn  = `Preview the access token a role would create`
+	pathRolePreviewHelpDesc = `
+This path returns what generating a token for the role would send to GitLab, the rendered name, the resolved path,
+scopes, access level, the expiry date and the lease TTL, together with the validation failures of the role. No token
+is created, so it can be used to debug name templates and TTL rounding.`
+)
+
+func pathRolePreview(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRolePreviewHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRolePreviewHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s/%s$", PathRoleStorage, framework.GenericNameRegex("role_name"), PathRolePreview),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": FieldSchemaRoles["role_name"],
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-preview",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRolePreview,
+				Summary:  "Preview the access token a role would create without creating it",
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "read",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathRolePreview(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName = data.Get("role_name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, roleName)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	role, _, err := getEffectiveRole(ctx, roleName, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("error reading role"), err
+	}
+	if role == nil {
+		return nil, nil
+	}
+
+	var config *EntryConfig
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, req.Storage, role.ConfigName)
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var startTime = TimeFromContext(ctx).UTC()
+	var ttl, expiresAt, warnings = b.tokenExpiry(*role, startTime)
+
+	var failures = role.available(startTime)
+	var name string
+	if name, err = TokenNameWithData(role, b.tokenNameData(req, role)); err != nil {
+		failures = appendErr(failures, fmt.Errorf("error generating token name: %w", err))
+	}
+
+	var leaseTTL = ttl
+	if role.GitlabRevokesTokens {
+		leaseTTL, _ = clampToMaxLeaseTTL(expiresAt.Sub(startTime), b.System().MaxLeaseTTL())
+	}
+
+	var resp = &logical.Response{
+		Data: map[string]any{
+			"role_name":            role.RoleName,
+			"config_name":          role.ConfigName,
+			"token_type":           role.TokenType.String(),
+			"name":                 name,
+			"path":                 role.Path,
+			"gitlab_path":          role.gitlabPath(),
+			"path_id":              role.PathId,
+			"full_path":            role.FullPath,
+			"scopes":               role.Scopes,
+			"access_level":         role.AccessLevel.String(),
+			"gitlab_revokes_token": role.GitlabRevokesTokens,
+			"expires_at":           expiresAt.Format(time.DateOnly),
+			"ttl":                  leaseTTL.String(),
+		},
+	}
+
+	if config == nil {
+		failures = multierror.Append(failures, ErrBackendNotConfigured)
+	} else {
+		failures = appendErr(failures, config.CheckRole(*role))
+		var w, er = b.validateRole(ctx, config, *role)
+		warnings, failures = append(warnings, w...), appendErr(failures, er)
+		b.previewUserIds(ctx, req.Storage, *role, resp.Data)
+	}
+
+	resp.Data["validation_errors"] = errorMessages(failures)
+	resp.Warnings = warnings
+
+	b.Logger().Debug("Role preview", "role", roleName, "name", name, "expiresAt", expiresAt, "ttl", leaseTTL)
+	return resp, nil
+}
+
+// previewUserIds adds the ids of the user or service account the token would be created for
+func (b *Backend) previewUserIds(ctx context.Context, s logical.Storage, role EntryRole, data map[string]any) {
+	var username = role.Path
+	switch role.TokenType {
+	case TokenTypePersonal, TokenTypeUserServiceAccount:
+	case TokenTypeGroupServiceAccount:
+		data["group_id"], username, _ = strings.Cut(role.Path, "/")
+	default:
+		return
+	}
+	client, err := b.getClientFor(ctx, s, role.ConfigName, capabilityForTokenType(role.TokenType))
+	if err != nil {
+		return
+	}
+	if userId, err := client.GetUserIdByUsername(ctx, username); err == nil {
+		data["user_id"] = userId
+	}
+}
+
+// errorMessages flattens the error into the messages of the errors it is made of
+func errorMessages(err error) (messages []string) {
+	messages = []string{}
+	var merr *multierror.Error
+	if errors.As(err, &merr) {
+		for _, e := range merr.Errors {
+			messages = append(messages, errorMessages(e)...)
+		}
+	} else if err != nil {
+		messages = append(messages, err.Error())
+	}
+	return messages
+}
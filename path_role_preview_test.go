@@ -0,0 +1,133 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRolePreview(t *testing.T) {
+	var now = time.Date(2024, 12, 10, 15, 0, 0, 0, time.UTC)
+
+	var setup = func(t *testing.T) (context.Context, *gitlab.Backend, logical.Storage, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		client.admin = true
+		client.mainTokenInfo.Scopes = []string{gitlab.TokenScopeApi.String()}
+		ctx := gitlab.WithStaticTime(gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client), now)
+		b, l, err := getBackendWithConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+		return ctx, b, l, client
+	}
+
+	var writeRole = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage, data map[string]any) {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: data,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	}
+
+	var preview = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test/%s", gitlab.PathRoleStorage, gitlab.PathRolePreview), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	t.Run("unknown role", func(t *testing.T) {
+		ctx, b, l, _ := setup(t)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/unknown/%s", gitlab.PathRoleStorage, gitlab.PathRolePreview), Storage: l,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("project role", func(t *testing.T) {
+		ctx, b, l, client := setup(t)
+		writeRole(t, ctx, b, l, map[string]any{
+			"path":         "example/example",
+			"name":         "{{ .role_name }}-{{ .token_type }}",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "25h",
+		})
+
+		resp := preview(t, ctx, b, l)
+		require.EqualValues(t, "test-project", resp.Data["name"])
+		require.EqualValues(t, "1000", resp.Data["gitlab_path"])
+		require.EqualValues(t, 1000, resp.Data["path_id"])
+		require.EqualValues(t, "example/example", resp.Data["full_path"])
+		require.EqualValues(t, []string{gitlab.TokenScopeReadApi.String()}, resp.Data["scopes"])
+		require.EqualValues(t, gitlab.AccessLevelDeveloperPermissions.String(), resp.Data["access_level"])
+		require.EqualValues(t, "2024-12-12", resp.Data["expires_at"])
+		require.EqualValues(t, (25 * time.Hour).String(), resp.Data["ttl"])
+		require.Empty(t, resp.Data["validation_errors"])
+		require.Empty(t, client.accessTokens)
+	})
+
+	t.Run("gitlab revokes the token", func(t *testing.T) {
+		ctx, b, l, _ := setup(t)
+		writeRole(t, ctx, b, l, map[string]any{
+			"path":                 "user",
+			"name":                 "personal",
+			"token_type":           gitlab.TokenTypePersonal.String(),
+			"scopes":               []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":                  "48h",
+			"gitlab_revokes_token": true,
+		})
+
+		resp := preview(t, ctx, b, l)
+		require.EqualValues(t, "2024-12-13", resp.Data["expires_at"])
+		require.EqualValues(t, (57 * time.Hour).String(), resp.Data["ttl"])
+		require.Contains(t, resp.Data, "user_id")
+	})
+
+	t.Run("validation failures are listed", func(t *testing.T) {
+		ctx, b, l, client := setup(t)
+		writeRole(t, ctx, b, l, map[string]any{
+			"path":         "example/example",
+			"name":         "project",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelOwnerPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		})
+
+		// the config was tightened and the config token lost its admin rights after the role was written
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+			Data: map[string]any{"max_access_level": gitlab.AccessLevelDeveloperPermissions.String()},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		client.admin = false
+		client.memberAccess = map[string]gitlab.AccessLevel{"example/example": gitlab.AccessLevelMaintainerPermissions}
+
+		resp = preview(t, ctx, b, l)
+		require.Len(t, resp.Data["validation_errors"], 2)
+		require.Empty(t, client.accessTokens)
+	})
+}
@@ -0,0 +1,231 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathRoleTest = "test"
+
+	pathRoleTestHelpSyn  = `Smoke test a role by issuing and revoking an access token`
+	pathRoleTestHelpDesc = `
+This path issues a token for the role the same way the token path does, calls GitLab with the token to prove it works
+and revokes it right away. The result and the duration of every step are returned. Roles with a test_interval are
+tested by the periodic function as well, and a failure emits a role-test-failed event.`
+)
+
+// roleTestStep is the result of a single step of a role smoke test
+type roleTestStep struct {
+	Name     string
+	Duration time.Duration
+	Err      error
+}
+
+func (s roleTestStep) LogicalResponseData() map[string]any {
+	var errMessage = ""
+	if s.Err != nil {
+		errMessage = s.Err.Error()
+	}
+	return map[string]any{
+		"step":     s.Name,
+		"success":  s.Err == nil,
+		"duration": s.Duration.String(),
+		"error":    errMessage,
+	}
+}
+
+// roleTestResult is the result of a role smoke test
+type roleTestResult struct {
+	RoleName string
+	TokenId  int
+	Steps    []roleTestStep
+}
+
+// failed returns the step that failed, or nil if the test succeeded
+func (r roleTestResult) failed() *roleTestStep {
+	for _, step := range r.Steps {
+		if step.Err != nil {
+			return &step
+		}
+	}
+	return nil
+}
+
+func (r roleTestResult) LogicalResponseData() map[string]any {
+	var duration time.Duration
+	var steps = make([]map[string]any, 0, len(r.Steps))
+	for _, step := range r.Steps {
+		duration += step.Duration
+		steps = append(steps, step.LogicalResponseData())
+	}
+	return map[string]any{
+		"role_name": r.RoleName,
+		"token_id":  r.TokenId,
+		"success":   r.failed() == nil,
+		"duration":  duration.String(),
+		"steps":     steps,
+	}
+}
+
+func pathRoleTest(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRoleTestHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRoleTestHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s/%s$", PathRoleStorage, framework.GenericNameRegex("role_name"), PathRoleTest),
+		Fields: map[string]*framework.FieldSchema{
+			"role_name": FieldSchemaRoles["role_name"],
+		},
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-test",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRoleTest,
+				Summary:  "Issue a token for the role, use it and revoke it",
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "test",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func (b *Backend) pathRoleTest(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var roleName = data.Get("role_name").(string)
+	if role, _, err := getEffectiveRole(ctx, roleName, req.Storage); err != nil {
+		return logical.ErrorResponse("error reading role"), err
+	} else if role == nil {
+		return nil, nil
+	}
+
+	var result = b.testRole(ctx, req.Storage, roleName)
+	b.Logger().Debug("Role tested", "role", roleName, "success", result.failed() == nil)
+	return &logical.Response{Data: result.LogicalResponseData()}, nil
+}
+
+// testRole issues a token for the role through the token path, calls GitLab with the token and revokes it again
+func (b *Backend) testRole(ctx context.Context, s logical.Storage, roleName string) (result roleTestResult) {
+	result.RoleName = roleName
+	var runStep = func(name string, fn func() error) error {
+		var start = time.Now()
+		var err = fn()
+		result.Steps = append(result.Steps, roleTestStep{Name: name, Duration: time.Since(start), Err: err})
+		return err
+	}
+
+	var resp *logical.Response
+	if err := runStep("issue", func() (err error) {
+		resp, err = b.pathTokenRoleCreate(ctx, &logical.Request{Storage: s}, &framework.FieldData{
+			Raw:    map[string]any{"role_name": roleName},
+			Schema: FieldSchemaTokenRole,
+		})
+		if err == nil && (resp == nil || resp.Secret == nil) {
+			err = fmt.Errorf("%w: no token was issued", ErrNilValue)
+		}
+		return err
+	}); err != nil {
+		return result
+	}
+
+	result.TokenId, _ = convertToInt(resp.Secret.InternalData["token_id"])
+	var configName, _ = resp.Secret.InternalData["config_name"].(string)
+	var tokenType, _ = TokenTypeParse(resp.Secret.InternalData["token_type"].(string))
+
+	_ = runStep("use", func() (err error) {
+		var client Client
+		if client, err = b.getClientFor(ctx, s, configName, capabilityForTokenType(tokenType)); err != nil {
+			return err
+		}
+		var token, _ = resp.Secret.InternalData["token"].(string)
+		_, err = client.TokenInfo(ctx, token)
+		return mapGitlabError(err, "use the token", "the issued token can't authenticate")
+	})
+
+	_ = runStep("revoke", func() (err error) {
+		// the test token is revoked right away, even if the role leaves the revocation to gitlab
+		var secret = *resp.Secret
+		secret.InternalData = maps.Clone(resp.Secret.InternalData)
+		secret.InternalData["gitlab_revokes_token"] = strconv.FormatBool(false)
+		var revokeResp *logical.Response
+		if revokeResp, err = b.secretAccessTokenRevoke(ctx, &logical.Request{Storage: s, Secret: &secret}, nil); err == nil && revokeResp != nil && revokeResp.IsError() {
+			err = revokeResp.Error()
+		}
+		return err
+	})
+
+	return result
+}
+
+// periodicRoleTests smoke tests the roles with a test interval, a failure emits a role-test-failed event
+func (b *Backend) periodicRoleTests(ctx context.Context, req *logical.Request) (err error) {
+	var roles []string
+	if roles, err = req.Storage.List(ctx, fmt.Sprintf("%s/", PathRoleStorage)); err != nil {
+		return err
+	}
+
+	var now = TimeFromContext(ctx)
+	for _, roleName := range roles {
+		var role, _, er = getEffectiveRole(ctx, roleName, req.Storage)
+		if er != nil {
+			return errors.Join(err, er)
+		}
+		if role == nil || role.TestInterval <= 0 {
+			b.roleTestedAt.Delete(roleName)
+			continue
+		}
+
+		var config *EntryConfig
+		b.lockClientMutex.RLock()
+		config, er = getConfig(ctx, req.Storage, role.ConfigName)
+		b.lockClientMutex.RUnlock()
+		if er != nil {
+			err = errors.Join(err, er)
+			continue
+		}
+		if config == nil {
+			// the config of the role was deleted, there is nothing to issue the test token with
+			b.Logger().Debug("Skipping the role test, the config doesn't exist", "role_name", roleName, "config_name", role.ConfigName)
+			continue
+		}
+		if last, ok := b.roleTestedAt.Load(roleName); ok && now.Sub(last.(time.Time)) < role.TestInterval {
+			continue
+		}
+		if role.available(now) != nil {
+			// a role that can't issue tokens right now would only fail the test
+			continue
+		}
+		b.roleTestedAt.Store(roleName, now)
+
+		var result = b.testRole(ctx, req.Storage, roleName)
+		if step := result.failed(); step != nil {
+			b.Logger().Warn("Role test failed", "role_name", roleName, "step", step.Name, "error", step.Err)
+			event(ctx, b.Backend, "role-test-failed", map[string]string{
+				"path":        fmt.Sprintf("%s/%s/%s", PathRoleStorage, roleName, PathRoleTest),
+				"role_name":   roleName,
+				"config_name": role.ConfigName,
+				"token_id":    strconv.Itoa(result.TokenId),
+				"step":        step.Name,
+				"error":       step.Err.Error(),
+			})
+			err = errors.Join(err, fmt.Errorf("role %s: %s: %w", roleName, step.Name, step.Err))
+		}
+	}
+
+	return err
+}
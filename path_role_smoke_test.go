@@ -0,0 +1,195 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRoleTest(t *testing.T) {
+	var setup = func(t *testing.T, role map[string]any) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+
+		var data = map[string]any{
+			"path":         "example/example",
+			"name":         "project",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelGuestPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		}
+		for k, v := range role {
+			data[k] = v
+		}
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: data,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return ctx, b, l, events, client
+	}
+
+	var testRole = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/test/%s", gitlab.PathRoleStorage, gitlab.PathRoleTest), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	var countEvents = func(events *mockEventsSender, eventType string) (count int) {
+		for _, e := range events.eventsProcessed {
+			if e.EventType == eventType {
+				count++
+			}
+		}
+		return count
+	}
+
+	t.Run("token is issued, used and revoked", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t, nil)
+		resp := testRole(t, ctx, b, l)
+		require.True(t, resp.Data["success"].(bool))
+		require.Len(t, resp.Data["steps"], 3)
+		require.Empty(t, client.accessTokens)
+		require.Equal(t, 1, countEvents(events, "gitlab/token-write"))
+		require.Equal(t, 1, countEvents(events, "gitlab/token-revoke"))
+	})
+
+	t.Run("the token is revoked when it doesn't work", func(t *testing.T) {
+		ctx, b, l, _, client := setup(t, map[string]any{"gitlab_revokes_token": true, "ttl": "24h"})
+		client.tokenInfoError = newGitlabErrorResponse(http.StatusUnauthorized, "401 Unauthorized")
+		resp := testRole(t, ctx, b, l)
+		require.False(t, resp.Data["success"].(bool))
+		var steps = resp.Data["steps"].([]map[string]any)
+		require.Len(t, steps, 3)
+		require.False(t, steps[1]["success"].(bool))
+		require.True(t, steps[2]["success"].(bool))
+		require.Empty(t, client.accessTokens)
+	})
+
+	t.Run("nothing to revoke when the token isn't issued", func(t *testing.T) {
+		ctx, b, l, _, client := setup(t, nil)
+		client.projectAccessTokenCreateError = true
+		resp := testRole(t, ctx, b, l)
+		require.False(t, resp.Data["success"].(bool))
+		require.Len(t, resp.Data["steps"], 1)
+	})
+
+	t.Run("unknown role", func(t *testing.T) {
+		ctx, b, l, _, _ := setup(t, nil)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/unknown/%s", gitlab.PathRoleStorage, gitlab.PathRoleTest), Storage: l,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+	})
+
+	t.Run("test interval must be above the minimum", func(t *testing.T) {
+		ctx, b, l, _, _ := setup(t, nil)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/other", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":          "user",
+				"name":          "personal",
+				"token_type":    gitlab.TokenTypePersonal.String(),
+				"ttl":           "1h",
+				"test_interval": "1m",
+			},
+		})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "test_interval = 1m0s [test_interval = 0 or test_interval >= 15m]")
+	})
+
+	t.Run("periodic test emits role-test-failed", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t, map[string]any{"test_interval": "1h"})
+		var now = time.Now()
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, now), &logical.Request{Storage: l}))
+		require.Equal(t, 1, countEvents(events, "gitlab/token-write"))
+		require.Equal(t, 0, countEvents(events, "gitlab/role-test-failed"))
+
+		client.tokenInfoError = newGitlabErrorResponse(http.StatusUnauthorized, "401 Unauthorized")
+		require.NoError(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, now.Add(30*time.Minute)), &logical.Request{Storage: l}))
+		require.Equal(t, 1, countEvents(events, "gitlab/token-write"))
+
+		require.Error(t, b.PeriodicFunc(gitlab.WithStaticTime(ctx, now.Add(time.Hour)), &logical.Request{Storage: l}))
+		require.Equal(t, 2, countEvents(events, "gitlab/token-write"))
+		require.Equal(t, 1, countEvents(events, "gitlab/role-test-failed"))
+		require.Empty(t, client.accessTokens)
+	})
+
+	t.Run("periodic test skips roles without a config", func(t *testing.T) {
+		ctx, b, l, events, _ := setup(t, map[string]any{"test_interval": "1h"})
+		require.NoError(t, l.Delete(ctx, fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName)))
+
+		var done = make(chan error)
+		go func() { done <- b.PeriodicFunc(ctx, &logical.Request{Storage: l}) }()
+		select {
+		case err := <-done:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "the periodic function didn't finish")
+		}
+		require.Equal(t, 0, countEvents(events, "gitlab/token-write"))
+	})
+
+	t.Run("periodic test reports the inherited config", func(t *testing.T) {
+		ctx, b, l, events, client := setup(t, nil)
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/deploy", gitlab.PathRoleTemplateStorage), Storage: l,
+			Data: map[string]any{"config_name": gitlab.DefaultConfigName, "ttl": "1h"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/inherited", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":          "example/example",
+				"name":          "project",
+				"token_type":    gitlab.TokenTypeProject.String(),
+				"access_level":  gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":        []string{gitlab.TokenScopeReadApi.String()},
+				"extends":       "deploy",
+				"test_interval": "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		client.tokenInfoError = newGitlabErrorResponse(http.StatusUnauthorized, "401 Unauthorized")
+		require.Error(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.Equal(t, 1, countEvents(events, "gitlab/role-test-failed"))
+		for _, e := range events.eventsProcessed {
+			if e.EventType == "gitlab/role-test-failed" {
+				var metadata = e.Event.Metadata.AsMap()
+				require.EqualValues(t, "inherited", metadata["role_name"])
+				require.EqualValues(t, gitlab.DefaultConfigName, metadata["config_name"])
+			}
+		}
+	})
+}
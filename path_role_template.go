@@ -0,0 +1,379 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	pathRoleTemplatesHelpSyn  = `Create a role template with values shared by the roles that extend it.`
+	pathRoleTemplatesHelpDesc = `
+This path allows you to create a partial role with the name, ttl, scopes, access_level and config_name shared by many
+roles. A role that sets extends=<template> inherits the fields it doesn't set from the template, and changes to the
+template apply to the role the next time a token is issued. A template can't be deleted while roles extend it.`
+	pathListRoleTemplatesHelpSyn  = `Lists existing role templates`
+	pathListRoleTemplatesHelpDesc = `
+This path allows you to list all available role templates that have been created within the GitLab Access Tokens Backend.`
+)
+
+var (
+	FieldSchemaRoleTemplates = map[string]*framework.FieldSchema{
+		"template_name": {
+			Type:        framework.TypeString,
+			Description: "Role template name",
+			Required:    true,
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Template Name",
+			},
+		},
+		"name": {
+			Type:        framework.TypeString,
+			Description: "The name template of the access tokens",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Token Name",
+			},
+		},
+		"ttl": {
+			Type:        framework.TypeDurationSecond,
+			Description: "The TTL of the access tokens",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Token TTL",
+			},
+		},
+		"scopes": {
+			Type:        framework.TypeCommaStringSlice,
+			Description: "List of scopes",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Scopes",
+			},
+		},
+		"access_level": {
+			Type:        framework.TypeString,
+			Description: "Access level of the access tokens, only used by group and project roles",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Access Level",
+			},
+		},
+		"config_name": {
+			Type:        framework.TypeString,
+			Description: "The config of the roles that extend the template",
+			DisplayAttrs: &framework.DisplayAttributes{
+				Name: "Configuration",
+			},
+		},
+	}
+)
+
+func pathListRoleTemplates(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathListRoleTemplatesHelpSyn),
+		HelpDescription: strings.TrimSpace(pathListRoleTemplatesHelpDesc),
+		Pattern:         fmt.Sprintf("%s/?$", PathRoleTemplateStorage),
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-templates",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathRoleTemplatesList,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "list",
+				},
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
+
+func pathRoleTemplates(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:    strings.TrimSpace(pathRoleTemplatesHelpSyn),
+		HelpDescription: strings.TrimSpace(pathRoleTemplatesHelpDesc),
+		Pattern:         fmt.Sprintf("%s/%s$", PathRoleTemplateStorage, framework.GenericNameRegex("template_name")),
+		Fields:          FieldSchemaRoleTemplates,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "role-template",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathRoleTemplatesDelete,
+				Summary:  "Deletes a role template",
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: http.StatusText(http.StatusNoContent),
+					}},
+				},
+			},
+			logical.CreateOperation: &framework.PathOperation{
+				Callback: b.pathRoleTemplatesWrite,
+				Summary:  "Creates a new role template",
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: http.StatusText(http.StatusNoContent),
+					}},
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRoleTemplatesWrite,
+				Summary:  "Updates an existing role template",
+				Responses: map[int][]framework.Response{
+					http.StatusNoContent: {{
+						Description: http.StatusText(http.StatusNoContent),
+					}},
+				},
+			},
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathRoleTemplatesRead,
+				Summary:  "Reads an existing role template",
+				Responses: map[int][]framework.Response{
+					http.StatusNotFound: {{
+						Description: http.StatusText(http.StatusNotFound),
+					}},
+					http.StatusOK: {{
+						Fields: FieldSchemaRoleTemplates,
+					}},
+				},
+			},
+		},
+		ExistenceCheck: b.pathRoleTemplateExistenceCheck,
+	}
+}
+
+func roleTemplateLockKey(name string) string {
+	return fmt.Sprintf("%s/%s", PathRoleTemplateStorage, name)
+}
+
+func (b *Backend) pathRoleTemplatesList(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	templates, err := req.Storage.List(ctx, fmt.Sprintf("%s/", PathRoleTemplateStorage))
+	if err != nil {
+		return logical.ErrorResponse("Error listing role templates"), err
+	}
+	b.Logger().Debug("Available", "role templates", templates)
+	return logical.ListResponse(templates), nil
+}
+
+func (b *Backend) pathRoleTemplatesRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var name = data.Get("template_name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, roleTemplateLockKey(name))
+	lock.RLock()
+	defer lock.RUnlock()
+
+	tmpl, err := getRoleTemplate(ctx, name, req.Storage)
+	if err != nil {
+		return logical.ErrorResponse("error reading role template"), err
+	}
+	if tmpl == nil {
+		return nil, nil
+	}
+
+	var resp = &logical.Response{Data: tmpl.LogicalResponseData()}
+	if resp.Data["roles"], err = rolesExtending(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// roleTemplateFromFieldData builds the role template from the request and checks it, the caller holds lockClientMutex
+func roleTemplateFromFieldData(ctx context.Context, s logical.Storage, data *framework.FieldData) (tmpl EntryRoleTemplate, err error) {
+	tmpl = EntryRoleTemplate{
+		TemplateName: data.Get("template_name").(string),
+		TTL:          time.Duration(data.Get("ttl").(int)) * time.Second,
+		Name:         data.Get("name").(string),
+		Scopes:       data.Get("scopes").([]string),
+		ConfigName:   data.Get("config_name").(string),
+	}
+
+	if val := data.Get("access_level").(string); val != "" {
+		var er error
+		if tmpl.AccessLevel, er = AccessLevelParse(val); er != nil {
+			err = multierror.Append(err, fmt.Errorf("access_level: %w", er))
+		}
+	}
+
+	if _, e := template.New("name").Funcs(tplFuncMap).Parse(tmpl.Name); e != nil {
+		err = multierror.Append(err, fmt.Errorf("invalid template %s for name: %w", tmpl.Name, e))
+	}
+
+	if tmpl.TTL < 0 || tmpl.TTL > DefaultAccessTokenMaxPossibleTTL {
+		err = multierror.Append(err, fmt.Errorf("ttl = %s [0 <= ttl <= %s]: %w", tmpl.TTL, DefaultAccessTokenMaxPossibleTTL, ErrInvalidValue))
+	}
+
+	// the roles that extend the template check the scopes against their token type
+	var knownScopes = slices.Concat(validTokenScopes, ValidPersonalTokenScopes, ValidUserServiceAccountTokenScopes, ValidGroupServiceAccountTokenScopes)
+	for _, scope := range tmpl.Scopes {
+		if !slices.Contains(knownScopes, scope) {
+			err = multierror.Append(err, fmt.Errorf("scopes='%s': %w", scope, ErrFieldInvalidValue))
+		}
+	}
+
+	if tmpl.ConfigName != "" {
+		var config, er = getConfig(ctx, s, tmpl.ConfigName)
+		if er != nil {
+			return tmpl, er
+		}
+		if config == nil {
+			err = multierror.Append(err, fmt.Errorf("config_name='%s': %w", tmpl.ConfigName, ErrBackendNotConfigured))
+		}
+	}
+
+	return tmpl, err
+}
+
+func (b *Backend) pathRoleTemplatesWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.writeRoleTemplate(ctx, req, data, true)
+}
+
+// writeRoleTemplate checks and stores the role template, with checkRoles the roles that extend the template are
+// checked against it first. The import checks the roles of the document against its templates itself.
+func (b *Backend) writeRoleTemplate(ctx context.Context, req *logical.Request, data *framework.FieldData, checkRoles bool) (*logical.Response, error) {
+	var name = data.Get("template_name").(string)
+
+	b.lockClientMutex.RLock()
+	var tmpl, err = roleTemplateFromFieldData(ctx, req.Storage, data)
+	if err == nil && checkRoles {
+		err = b.validateExtendingRoles(ctx, req, tmpl)
+	}
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	lock := locksutil.LockForKey(b.roleLocks, roleTemplateLockKey(name))
+	lock.Lock()
+	defer lock.Unlock()
+
+	entry, err := logical.StorageEntryJSON(fmt.Sprintf("%s/%s", PathRoleTemplateStorage, name), tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if err = req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	event(ctx, b.Backend, "role-template-write", map[string]string{
+		"path":          PathRoleTemplateStorage,
+		"template_name": name,
+	})
+
+	b.Logger().Debug("Role template written", "template", name)
+	return &logical.Response{Data: tmpl.LogicalResponseData()}, nil
+}
+
+func (b *Backend) pathRoleTemplatesDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	var name = data.Get("template_name").(string)
+
+	lock := locksutil.LockForKey(b.roleLocks, roleTemplateLockKey(name))
+	lock.Lock()
+	defer lock.Unlock()
+
+	roles, err := rolesExtending(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(roles) > 0 {
+		err = fmt.Errorf("%s is extended by the roles %v: %w", name, roles, ErrRoleTemplateInUse)
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	if err = req.Storage.Delete(ctx, fmt.Sprintf("%s/%s", PathRoleTemplateStorage, name)); err != nil {
+		return nil, fmt.Errorf("error deleting role template: %w", err)
+	}
+
+	event(ctx, b.Backend, "role-template-delete", map[string]string{
+		"path":          PathRoleTemplateStorage,
+		"template_name": name,
+	})
+
+	b.Logger().Debug("Role template deleted", "template", name)
+	return nil, nil
+}
+
+func (b *Backend) pathRoleTemplateExistenceCheck(ctx context.Context, req *logical.Request, data *framework.FieldData) (bool, error) {
+	tmpl, err := getRoleTemplate(ctx, data.Get("template_name").(string), req.Storage)
+	if err != nil {
+		if strings.Contains(err.Error(), logical.ErrReadOnly.Error()) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error reading role template: %w", err)
+	}
+	return tmpl != nil, nil
+}
+
+// validateExtendingRoles runs the checks of a role write on every role that extends the template, as if the roles
+// were written again with the new template, the caller holds lockClientMutex
+func (b *Backend) validateExtendingRoles(ctx context.Context, req *logical.Request, tmpl EntryRoleTemplate) (err error) {
+	var roles []string
+	if roles, err = rolesExtending(ctx, req.Storage, tmpl.TemplateName); err != nil || len(roles) == 0 {
+		return err
+	}
+
+	var staging logical.Storage
+	if staging, err = stagingStorage(ctx, req.Storage); err != nil {
+		return err
+	}
+	var entry *logical.StorageEntry
+	if entry, err = logical.StorageEntryJSON(fmt.Sprintf("%s/%s", PathRoleTemplateStorage, tmpl.TemplateName), tmpl); err != nil {
+		return err
+	}
+	if err = staging.Put(ctx, entry); err != nil {
+		return err
+	}
+	var stagingReq = *req
+	stagingReq.Storage = staging
+
+	var broken []string
+	for _, name := range roles {
+		var role *EntryRole
+		if role, err = getRole(ctx, name, staging); err != nil {
+			return err
+		}
+		var rw, resp, er = b.validateRoleWrite(ctx, &stagingReq, role.Merge(&framework.FieldData{Raw: map[string]any{}, Schema: FieldSchemaRoles}))
+		if rw != nil {
+			continue
+		}
+		if er == nil {
+			er = resp.Error()
+		}
+		broken = append(broken, fmt.Sprintf("%s (%s)", name, er))
+	}
+
+	if len(broken) > 0 {
+		return fmt.Errorf("%s would break the roles %s: %w", tmpl.TemplateName, strings.Join(broken, ", "), ErrInvalidValue)
+	}
+	return nil
+}
+
+// rolesExtending returns the names of the roles that extend the template
+func rolesExtending(ctx context.Context, s logical.Storage, templateName string) (roles []string, err error) {
+	var names []string
+	if names, err = s.List(ctx, fmt.Sprintf("%s/", PathRoleStorage)); err != nil {
+		return nil, err
+	}
+	roles = []string{}
+	for _, name := range names {
+		var role *EntryRole
+		if role, err = getRole(ctx, name, s); err != nil {
+			return nil, err
+		}
+		if role != nil && role.Extends == templateName {
+			roles = append(roles, name)
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,188 @@
+package gitlab_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRoleTemplates(t *testing.T) {
+	ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), newInMemoryClient(true))
+	b, l, err := getBackendWithConfig(ctx, map[string]any{
+		"token":    "glpat-secret-random-token",
+		"base_url": "http://localhost:8080/",
+		"type":     gitlab.TypeSelfManaged.String(),
+	})
+	require.NoError(t, err)
+
+	var writeTemplate = func(t *testing.T, data map[string]any) (*logical.Response, error) {
+		t.Helper()
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      fmt.Sprintf("%s/deploy", gitlab.PathRoleTemplateStorage), Storage: l,
+			Data: data,
+		})
+	}
+
+	var writeRole = func(t *testing.T, name string, data map[string]any) (*logical.Response, error) {
+		t.Helper()
+		var role = map[string]any{
+			"path":       "example/example",
+			"token_type": gitlab.TokenTypeProject.String(),
+			"extends":    "deploy",
+		}
+		for k, v := range data {
+			role[k] = v
+		}
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, name), Storage: l,
+			Data: role,
+		})
+	}
+
+	var readRole = func(t *testing.T, name string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, name), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	t.Run("extends a missing template", func(t *testing.T) {
+		resp, err := writeRole(t, "missing", nil)
+		require.ErrorIs(t, err, gitlab.ErrRoleTemplateNotFound)
+		require.Error(t, resp.Error())
+	})
+
+	t.Run("invalid template", func(t *testing.T) {
+		resp, err := writeTemplate(t, map[string]any{
+			"name":         "{{ .role_name ",
+			"access_level": "unknown",
+			"scopes":       "invalid",
+			"config_name":  "missing",
+		})
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+	})
+
+	resp, err := writeTemplate(t, map[string]any{
+		"name":         "{{ .role_name }}-tpl",
+		"ttl":          "1h",
+		"scopes":       gitlab.TokenScopeReadApi.String(),
+		"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+	})
+	require.NoError(t, err)
+	require.NoError(t, resp.Error())
+
+	t.Run("roles inherit the fields they don't set", func(t *testing.T) {
+		resp, err := writeRole(t, "inherits", nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Empty(t, resp.Warnings)
+
+		resp = readRole(t, "inherits")
+		require.EqualValues(t, "deploy", resp.Data["extends"])
+		require.EqualValues(t, gitlab.AccessLevelDeveloperPermissions.String(), resp.Data["access_level"])
+		require.EqualValues(t, 3600, resp.Data["ttl"])
+		require.ElementsMatch(t, []string{"name", "ttl", "scopes", "access_level"}, resp.Data["inherited_fields"])
+	})
+
+	t.Run("roles override fields", func(t *testing.T) {
+		resp, err := writeRole(t, "overrides", map[string]any{
+			"access_level": gitlab.AccessLevelGuestPermissions.String(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp = readRole(t, "overrides")
+		require.EqualValues(t, gitlab.AccessLevelGuestPermissions.String(), resp.Data["access_level"])
+		require.ElementsMatch(t, []string{"name", "ttl", "scopes"}, resp.Data["inherited_fields"])
+	})
+
+	t.Run("template changes apply when issuing", func(t *testing.T) {
+		resp, err := writeTemplate(t, map[string]any{
+			"name":         "{{ .role_name }}-v2",
+			"ttl":          "2h",
+			"scopes":       gitlab.TokenScopeReadApi.String(),
+			"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/deploy", gitlab.PathRoleTemplateStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, []string{"inherits", "overrides"}, resp.Data["roles"])
+
+		resp = readRole(t, "inherits")
+		require.EqualValues(t, 7200, resp.Data["ttl"])
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/inherits", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, "inherits-v2", resp.Data["name"])
+	})
+
+	t.Run("template changes that break the roles are rejected", func(t *testing.T) {
+		resp, err := writeTemplate(t, map[string]any{
+			"name":         "{{ .role_name }}-v3",
+			"ttl":          "2h",
+			"scopes":       gitlab.TokenScopeReadUser.String(),
+			"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+		})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "inherits")
+		require.ErrorContains(t, resp.Error(), "overrides")
+
+		// the template is unchanged
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/deploy", gitlab.PathRoleTemplateStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, "{{ .role_name }}-v2", resp.Data["name"])
+	})
+
+	t.Run("template in use can't be deleted", func(t *testing.T) {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      fmt.Sprintf("%s/deploy", gitlab.PathRoleTemplateStorage), Storage: l,
+		})
+		require.ErrorIs(t, err, gitlab.ErrRoleTemplateInUse)
+		require.Error(t, resp.Error())
+
+		for _, name := range []string{"inherits", "overrides"} {
+			_, err = b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.DeleteOperation,
+				Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, name), Storage: l,
+			})
+			require.NoError(t, err)
+		}
+
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.DeleteOperation,
+			Path:      fmt.Sprintf("%s/deploy", gitlab.PathRoleTemplateStorage), Storage: l,
+		})
+		require.NoError(t, err)
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ListOperation,
+			Path:      gitlab.PathRoleTemplateStorage, Storage: l,
+		})
+		require.NoError(t, err)
+		require.Empty(t, resp.Data["keys"])
+	})
+}
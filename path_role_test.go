@@ -2,8 +2,11 @@ package gitlab_test
 
 import (
 	"cmp"
+	"context"
 	"fmt"
 	"os"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -504,3 +507,259 @@ func TestPathRoles(t *testing.T) {
 	})
 
 }
+
+func TestPathRolesConfigTokenKind(t *testing.T) {
+	var setup = func(t *testing.T, kind gitlab.TokenType, parentId string) (*gitlab.Backend, logical.Storage, context.Context) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		client.mainTokenKind, client.mainTokenParentId = kind, parentId
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, _, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSaaS.String(),
+		})
+		require.NoError(t, err)
+
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, kind.String(), resp.Data["token_kind"])
+		require.EqualValues(t, parentId, resp.Data["token_parent_id"])
+		return b, l, ctx
+	}
+
+	var writeRole = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, tokenType gitlab.TokenType) (*logical.Response, error) {
+		var accessLevel = gitlab.AccessLevelGuestPermissions.String()
+		if tokenType == gitlab.TokenTypePersonal || tokenType == gitlab.TokenTypeGroupServiceAccount {
+			accessLevel = ""
+		}
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathRoleStorage, tokenType), Storage: l,
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         tokenType.String(),
+				"token_type":   tokenType.String(),
+				"access_level": accessLevel,
+				"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":          "1h",
+			},
+		})
+	}
+
+	for _, tc := range []struct {
+		kind     gitlab.TokenType
+		parentId string
+		allowed  []gitlab.TokenType
+	}{
+		{gitlab.TokenTypePersonal, "", []gitlab.TokenType{gitlab.TokenTypePersonal, gitlab.TokenTypeGroup, gitlab.TokenTypeProject, gitlab.TokenTypeGroupServiceAccount}},
+		{gitlab.TokenTypeGroup, "42", []gitlab.TokenType{gitlab.TokenTypeGroup, gitlab.TokenTypeProject, gitlab.TokenTypeGroupServiceAccount}},
+		{gitlab.TokenTypeProject, "42", []gitlab.TokenType{gitlab.TokenTypeProject}},
+	} {
+		t.Run(tc.kind.String(), func(t *testing.T) {
+			b, l, ctx := setup(t, tc.kind, tc.parentId)
+			for _, tokenType := range []gitlab.TokenType{gitlab.TokenTypePersonal, gitlab.TokenTypeGroup, gitlab.TokenTypeProject, gitlab.TokenTypeGroupServiceAccount} {
+				resp, err := writeRole(ctx, b, l, tokenType)
+				if slices.Contains(tc.allowed, tokenType) {
+					require.NoError(t, err, tokenType)
+					require.NoError(t, resp.Error(), tokenType)
+				} else {
+					require.ErrorIs(t, err, gitlab.ErrInvalidValue, tokenType)
+				}
+			}
+		})
+	}
+
+	t.Run("credential of the capability", func(t *testing.T) {
+		b, l, ctx := setup(t, gitlab.TokenTypeGroup, "42")
+		var client, _ = gitlab.GitlabClientFromContext(ctx)
+
+		// a personal credential creates the personal tokens of a config with a group access token
+		client.(*inMemoryClient).mainTokenKind = gitlab.TokenTypePersonal
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+			Data: map[string]any{"personal_token": "glpat-personal-token"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		resp, err = writeRole(ctx, b, l, gitlab.TokenTypePersonal)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		// a project credential can't create group tokens, even though the config token is a group access token
+		client.(*inMemoryClient).mainTokenKind = gitlab.TokenTypeProject
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+			Data: map[string]any{"group_token": "glpat-project-token"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		_, err = writeRole(ctx, b, l, gitlab.TokenTypeGroup)
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, err, "with a project access token")
+	})
+}
+
+func TestPathRolesPinnedPath(t *testing.T) {
+	var setup = func(t *testing.T) (*gitlab.Backend, logical.Storage, context.Context, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, err := getBackendWithConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+		return b, l, ctx, client
+	}
+
+	var writeRole = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, path string) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":         path,
+				"name":         "project",
+				"token_type":   gitlab.TokenTypeProject.String(),
+				"access_level": gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":          "1h",
+			},
+		})
+	}
+
+	var readRole = func(t *testing.T, ctx context.Context, b *gitlab.Backend, l logical.Storage) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{"check_path": true},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		return resp
+	}
+
+	t.Run("the id is pinned and used to issue tokens", func(t *testing.T) {
+		b, l, ctx, client := setup(t)
+		resp, err := writeRole(ctx, b, l, "example/example")
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 1000, resp.Data["path_id"])
+		require.EqualValues(t, "example/example", resp.Data["full_path"])
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		require.EqualValues(t, "example/example", resp.Data["path"])
+		require.EqualValues(t, "1000", resp.Secret.InternalData["parent_id"])
+
+		resp = readRole(t, ctx, b, l)
+		require.False(t, resp.Data["path_moved"].(bool))
+		require.Empty(t, resp.Warnings)
+
+		// without check_path the read doesn't call gitlab
+		client.unavailable = true
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.NotContains(t, resp.Data, "path_moved")
+		require.Empty(t, resp.Warnings)
+		client.unavailable = false
+
+		// the project was transferred
+		client.fullPaths[1000] = "other/example"
+		resp = readRole(t, ctx, b, l)
+		require.True(t, resp.Data["path_moved"].(bool))
+		require.EqualValues(t, "other/example", resp.Data["current_full_path"])
+		require.Len(t, resp.Warnings, 1)
+	})
+
+	t.Run("the path must exist", func(t *testing.T) {
+		b, l, ctx, _ := setup(t)
+		resp, err := writeRole(ctx, b, l, "999")
+		require.ErrorIs(t, err, gitlab.ErrGitlabNotFound)
+		require.Error(t, resp.Error())
+	})
+
+	t.Run("the path is used as is when gitlab is unavailable", func(t *testing.T) {
+		b, l, ctx, client := setup(t)
+		client.unavailable = true
+		resp, err := writeRole(ctx, b, l, "example/example")
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.EqualValues(t, 0, resp.Data["path_id"])
+		require.NotContains(t, readRole(t, ctx, b, l).Data, "path_moved")
+	})
+}
+
+func TestPathRolesWriteReleasesConfigLock(t *testing.T) {
+	var client = newInMemoryClient(true)
+	ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+	b, l, _, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+		"token":    "glpat-secret-random-token",
+		"base_url": "http://localhost:8080/",
+		"type":     gitlab.TypeSelfManaged.String(),
+	})
+	require.NoError(t, err)
+
+	// the first resolve blocks until the config was written
+	var resolving, release = make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	client.resolveHook = func() {
+		once.Do(func() {
+			close(resolving)
+			<-release
+		})
+	}
+
+	var written = make(chan error)
+	go func() {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":         "example/example",
+				"name":         "{{ .role_name }}",
+				"token_type":   gitlab.TokenTypeProject.String(),
+				"access_level": gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":       gitlab.TokenScopeReadApi.String(),
+				"ttl":          "1h",
+			},
+		})
+		written <- cmp.Or(err, resp.Error())
+	}()
+	<-resolving
+
+	var done = make(chan error)
+	go func() {
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.PatchOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathConfigStorage, gitlab.DefaultConfigName), Storage: l,
+			Data: map[string]any{"validate_roles": false},
+		})
+		done <- cmp.Or(err, resp.Error())
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		close(release)
+		require.FailNow(t, "the config write waited for the role write")
+	}
+
+	close(release)
+	require.NoError(t, <-written)
+}
@@ -2,6 +2,7 @@ package gitlab_test
 
 import (
 	"cmp"
+	"context"
 	"fmt"
 	"maps"
 	"os"
@@ -206,3 +207,75 @@ func TestPathRolesTTL(t *testing.T) {
 	})
 
 }
+
+func TestPathRolesMaxLeaseTTL(t *testing.T) {
+	var startTime = time.Date(2024, 12, 12, 10, 0, 0, 0, time.UTC)
+	var setup = func(t *testing.T) (context.Context, *gitlab.Backend, logical.Storage, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(gitlab.WithStaticTime(getCtxGitlabClient(t), startTime), client)
+		b, l, _, err := getBackendWithSystemView(ctx, &logical.StaticSystemView{MaxLeaseTTLVal: 72 * time.Hour})
+		require.NoError(t, err)
+		require.NoError(t, writeBackendConfig(ctx, b, l, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		}))
+		return ctx, b, l, client
+	}
+
+	var role = func(gitlabRevokesToken bool) map[string]any {
+		return map[string]any{
+			"path":                 "example/example",
+			"name":                 "project",
+			"token_type":           gitlab.TokenTypeProject.String(),
+			"access_level":         gitlab.AccessLevelGuestPermissions.String(),
+			"scopes":               []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":                  "240h",
+			"gitlab_revokes_token": gitlabRevokesToken,
+		}
+	}
+
+	for _, tc := range []struct {
+		gitlabRevokesToken bool
+		gitlabExpiresAt    time.Time
+	}{
+		// vault revokes the token when the lease ends, gitlab must not expire it before that
+		{false, time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC)},
+		// gitlab revokes the token, so it expires on the last day the lease allows
+		{true, time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC)},
+	} {
+		var gitlabRevokesToken = tc.gitlabRevokesToken
+		t.Run(fmt.Sprintf("gitlab_revokes_token=%t", gitlabRevokesToken), func(t *testing.T) {
+			ctx, b, l, client := setup(t)
+			resp, err := b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.CreateOperation,
+				Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+				Data: role(gitlabRevokesToken),
+			})
+			require.NoError(t, err)
+			require.NoError(t, resp.Error())
+			require.Contains(t, resp.Warnings, "ttl = 240h0m0s is above the max lease ttl of the mount 72h0m0s, the tokens will be issued with a ttl of 72h0m0s")
+
+			resp, err = b.HandleRequest(ctx, &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+			})
+			require.NoError(t, err)
+			require.NotNil(t, resp.Secret)
+			require.NotEmpty(t, resp.Warnings)
+			require.Positive(t, resp.Secret.TTL)
+			require.LessOrEqual(t, resp.Secret.TTL, 72*time.Hour)
+			require.LessOrEqual(t, resp.Secret.MaxTTL, 72*time.Hour)
+
+			// the expiry gitlab received, the response has the lease expiry for tokens vault revokes
+			require.Len(t, client.accessTokens, 1)
+			for _, token := range client.accessTokens {
+				require.EqualValues(t, tc.gitlabExpiresAt, *token.ExpiresAt)
+				if !gitlabRevokesToken {
+					require.False(t, token.ExpiresAt.Before(startTime.Add(resp.Secret.TTL)))
+				}
+			}
+		})
+	}
+}
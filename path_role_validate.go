@@ -0,0 +1,128 @@
+package gitlab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// elevatedTokenScopes can only be granted by a credential that has the scope itself, all the other scopes can be
+// granted by a credential with the api scope.
+var elevatedTokenScopes = []string{
+	TokenScopeSudo.String(),
+	TokenScopeAdminMode.String(),
+}
+
+// credentialScopes returns the scopes of the credential used to create tokens of the token type
+func (e *EntryConfig) credentialScopes(tokenType TokenType) []string {
+	if credConfig := e.credentialConfig(capabilityForTokenType(tokenType)); credConfig != nil {
+		return credConfig.Scopes
+	}
+	return e.Scopes
+}
+
+// validateRole checks the role against GitLab, the path has to exist, the config token needs enough rights to mint
+// tokens with the access level of the role and the scopes of the role have to be grantable by the credential.
+// Problems that prevent the role from working are returned as errors, checks that could not be done as warnings.
+func (b *Backend) validateRole(ctx context.Context, config *EntryConfig, role EntryRole) (warnings []string, err error) {
+	var client Client
+	if client, err = b.clientForConfig(ctx, config, capabilityForTokenType(role.TokenType)); err != nil {
+		return nil, fmt.Errorf("unable to validate the role: %w", err)
+	}
+
+	var credScopes = config.credentialScopes(role.TokenType)
+	if len(credScopes) == 0 {
+		warnings = append(warnings, "unable to validate the scopes, the scopes of the config token are unknown")
+	} else {
+		var ungrantable []string
+		for _, scope := range role.Scopes {
+			if slices.Contains(credScopes, scope) {
+				continue
+			}
+			if !slices.Contains(elevatedTokenScopes, scope) && slices.Contains(credScopes, TokenScopeApi.String()) {
+				continue
+			}
+			ungrantable = append(ungrantable, scope)
+		}
+		if len(ungrantable) > 0 {
+			err = multierror.Append(err, fmt.Errorf("scopes='%v' can not be granted by a config token with scopes %v: %w", ungrantable, credScopes, ErrInvalidValue))
+		}
+	}
+
+	var userId, isAdmin, er = client.CurrentUser(ctx)
+	if er != nil {
+		return append(warnings, fmt.Sprintf("unable to validate the permissions of the config token: %s", mapGitlabError(er, "get current user", "config token lacks the read_user scope"))), err
+	}
+
+	switch role.TokenType {
+	case TokenTypePersonal, TokenTypeUserServiceAccount:
+		if !isAdmin {
+			err = multierror.Append(err, fmt.Errorf("%s: %w", tokenPermissionHint(role.TokenType, role.Path), ErrInvalidValue))
+		}
+		if _, er := client.GetUserIdByUsername(ctx, role.Path); er != nil {
+			err = multierror.Append(err, fmt.Errorf("user %s: %w", role.Path, mapGitlabError(er, "get user", tokenPermissionHint(role.TokenType, role.Path))))
+		}
+	case TokenTypeGroupServiceAccount:
+		var group, serviceAccount, _ = strings.Cut(role.Path, "/")
+		if _, er := client.GetUserIdByUsername(ctx, serviceAccount); er != nil {
+			err = multierror.Append(err, fmt.Errorf("service account %s: %w", serviceAccount, mapGitlabError(er, "get user", tokenPermissionHint(role.TokenType, role.Path))))
+		}
+		if !isAdmin {
+			w, er := b.validateMemberAccessLevel(ctx, client, role, TokenTypeGroup, group, userId, AccessLevelOwnerPermissions)
+			warnings, err = append(warnings, w...), appendErr(err, er)
+		}
+	case TokenTypeGroup, TokenTypeProject:
+		var idOrPath = role.Path
+		if role.PathId != 0 {
+			idOrPath = strconv.Itoa(role.PathId)
+		}
+		var required = AccessLevelOwnerPermissions
+		if role.TokenType == TokenTypeProject {
+			required = AccessLevelMaintainerPermissions
+		}
+		if !isAdmin {
+			w, er := b.validateMemberAccessLevel(ctx, client, role, role.TokenType, idOrPath, userId, required)
+			warnings, err = append(warnings, w...), appendErr(err, er)
+		}
+	}
+
+	return warnings, err
+}
+
+// validateMemberAccessLevel checks that the config token is a member of the group or project with at least the
+// required access level, and with at least the access level of the role as tokens can't have more than their creator.
+func (b *Backend) validateMemberAccessLevel(ctx context.Context, client Client, role EntryRole, kind TokenType, idOrPath string, userId int, required AccessLevel) (warnings []string, err error) {
+	var accessLevel AccessLevel
+	if kind == TokenTypeGroup {
+		accessLevel, err = client.GetGroupMemberAccessLevel(ctx, idOrPath, userId)
+	} else {
+		accessLevel, err = client.GetProjectMemberAccessLevel(ctx, idOrPath, userId)
+	}
+	if err != nil {
+		if err = mapGitlabError(err, fmt.Sprintf("get membership on %s %s", kind, role.Path), tokenPermissionHint(role.TokenType, role.Path)); errors.Is(err, ErrGitlabNotFound) {
+			return nil, fmt.Errorf("%s, the config token is not a member: %w", tokenPermissionHint(role.TokenType, role.Path), ErrInvalidValue)
+		}
+		return []string{fmt.Sprintf("unable to validate the membership of the config token: %s", err)}, nil
+	}
+
+	if accessLevel.Value() < required.Value() {
+		err = multierror.Append(err, fmt.Errorf("%s, the config token is %s: %w", tokenPermissionHint(role.TokenType, role.Path), accessLevel, ErrInvalidValue))
+	}
+	if role.AccessLevel.Value() > accessLevel.Value() {
+		err = multierror.Append(err, fmt.Errorf("access_level='%s' is higher than the %s access of the config token on %s: %w", role.AccessLevel, accessLevel, role.Path, ErrInvalidValue))
+	}
+	return nil, err
+}
+
+// appendErr appends the error when it is set, so a nil error doesn't turn into an empty multierror
+func appendErr(err error, er error) error {
+	if er == nil {
+		return err
+	}
+	return multierror.Append(err, er)
+}
@@ -0,0 +1,129 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathRolesValidate(t *testing.T) {
+	var setup = func(t *testing.T, validateRoles bool) (context.Context, *gitlab.Backend, logical.Storage, *inMemoryClient) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		client.mainTokenInfo.Scopes = []string{gitlab.TokenScopeApi.String(), gitlab.TokenScopeReadUser.String()}
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		b, l, err := getBackendWithConfig(ctx, map[string]any{
+			"token":          "glpat-secret-random-token",
+			"base_url":       "http://localhost:8080/",
+			"type":           gitlab.TypeSelfManaged.String(),
+			"validate_roles": validateRoles,
+		})
+		require.NoError(t, err)
+		return ctx, b, l, client
+	}
+
+	var writeRole = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, data map[string]any) (*logical.Response, error) {
+		var role = map[string]any{
+			"path":         "example/example",
+			"name":         "project",
+			"token_type":   gitlab.TokenTypeProject.String(),
+			"access_level": gitlab.AccessLevelDeveloperPermissions.String(),
+			"scopes":       []string{gitlab.TokenScopeReadApi.String()},
+			"ttl":          "1h",
+		}
+		for k, v := range data {
+			role[k] = v
+		}
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: role,
+		})
+	}
+
+	t.Run("not validated by default", func(t *testing.T) {
+		ctx, b, l, _ := setup(t, false)
+		resp, err := writeRole(ctx, b, l, nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	})
+
+	t.Run("config token is not a member", func(t *testing.T) {
+		ctx, b, l, _ := setup(t, false)
+		resp, err := writeRole(ctx, b, l, map[string]any{"validate": true})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "config token lacks Maintainer on project example/example")
+	})
+
+	t.Run("config token needs maintainer on the project", func(t *testing.T) {
+		ctx, b, l, client := setup(t, true)
+		client.memberAccess = map[string]gitlab.AccessLevel{"example/example": gitlab.AccessLevelDeveloperPermissions}
+		resp, err := writeRole(ctx, b, l, nil)
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "the config token is developer")
+	})
+
+	t.Run("access level can't be higher than the config token", func(t *testing.T) {
+		ctx, b, l, client := setup(t, true)
+		client.memberAccess = map[string]gitlab.AccessLevel{"example/example": gitlab.AccessLevelMaintainerPermissions}
+		resp, err := writeRole(ctx, b, l, map[string]any{"access_level": gitlab.AccessLevelOwnerPermissions.String()})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "access_level='owner' is higher than the maintainer access")
+
+		resp, err = writeRole(ctx, b, l, nil)
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	})
+
+	t.Run("validate overrides the config", func(t *testing.T) {
+		ctx, b, l, _ := setup(t, true)
+		resp, err := writeRole(ctx, b, l, map[string]any{"validate": false})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	})
+
+	t.Run("admin doesn't need to be a member", func(t *testing.T) {
+		ctx, b, l, client := setup(t, true)
+		client.admin = true
+		resp, err := writeRole(ctx, b, l, map[string]any{"access_level": gitlab.AccessLevelOwnerPermissions.String()})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+	})
+
+	t.Run("personal access tokens need an admin", func(t *testing.T) {
+		ctx, b, l, _ := setup(t, true)
+		resp, err := writeRole(ctx, b, l, map[string]any{
+			"path":         "user",
+			"token_type":   gitlab.TokenTypePersonal.String(),
+			"access_level": "",
+		})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "config token needs to be an admin token")
+	})
+
+	t.Run("scopes must be grantable by the config token", func(t *testing.T) {
+		ctx, b, l, client := setup(t, true)
+		client.admin = true
+		resp, err := writeRole(ctx, b, l, map[string]any{
+			"path":         "user",
+			"token_type":   gitlab.TokenTypePersonal.String(),
+			"access_level": "",
+			"scopes":       []string{gitlab.TokenScopeReadApi.String(), gitlab.TokenScopeSudo.String()},
+		})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "scopes='[sudo]' can not be granted")
+	})
+
+	t.Run("the path must be resolved", func(t *testing.T) {
+		ctx, b, l, client := setup(t, true)
+		client.unavailable = true
+		resp, err := writeRole(ctx, b, l, nil)
+		require.Error(t, err)
+		require.Error(t, resp.Error())
+	})
+}
@@ -45,7 +45,7 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 	lock.RLock()
 	defer lock.RUnlock()
 
-	role, err = getRole(ctx, roleName, req.Storage)
+	role, _, err = getEffectiveRole(ctx, roleName, req.Storage)
 	if err != nil {
 		return nil, fmt.Errorf("error getting role: %w", err)
 	}
@@ -53,15 +53,35 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 		return nil, fmt.Errorf("%s: %w", roleName, ErrRoleNotFound)
 	}
 
+	// the role could be disabled, expired or outside its availability windows
+	if err = role.available(TimeFromContext(ctx).UTC()); err != nil {
+		return logical.ErrorResponse(err.Error()), err
+	}
+
+	// the config could have been tightened after the role was written
+	var config *EntryConfig
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, req.Storage, role.ConfigName)
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		if err = config.CheckRole(*role); err != nil {
+			return logical.ErrorResponse(err.Error()), err
+		}
+	}
+
 	b.Logger().Debug("Creating token for role", "role_name", roleName, "token_type", role.TokenType.String())
 	defer b.Logger().Debug("Created token for role", "role_name", roleName, "token_type", role.TokenType.String())
 
 	var name string
 	var token *EntryToken
-	var expiresAt time.Time
 	var startTime = TimeFromContext(ctx).UTC()
+	var maxLeaseTTL = b.System().MaxLeaseTTL()
+	var ttl, expiresAt, warnings = b.tokenExpiry(*role, startTime)
 
-	name, err = TokenName(role)
+	name, err = TokenNameWithData(role, b.tokenNameData(req, role))
 	if err != nil {
 		return nil, fmt.Errorf("error generating token name: %w", err)
 	}
@@ -70,9 +90,7 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 	var gitlabRevokesTokens = role.GitlabRevokesTokens
 	var vaultRevokesTokens = !role.GitlabRevokesTokens
 
-	_, expiresAt, _ = calculateGitlabTTL(role.TTL, startTime)
-
-	client, err = b.getClient(ctx, req.Storage, role.ConfigName)
+	client, err = b.getClientFor(ctx, req.Storage, role.ConfigName, capabilityForTokenType(role.TokenType))
 	if err != nil {
 		return nil, err
 	}
@@ -80,10 +98,10 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 	switch role.TokenType {
 	case TokenTypeGroup:
 		b.Logger().Debug("Creating group access token for role", "path", role.Path, "name", name, "expiresAt", expiresAt, "scopes", role.Scopes, "accessLevel", role.AccessLevel)
-		token, err = client.CreateGroupAccessToken(ctx, role.Path, name, expiresAt, role.Scopes, role.AccessLevel)
+		token, err = client.CreateGroupAccessToken(ctx, role.gitlabPath(), name, expiresAt, role.Scopes, role.AccessLevel)
 	case TokenTypeProject:
 		b.Logger().Debug("Creating project access token for role", "path", role.Path, "name", name, "expiresAt", expiresAt, "scopes", role.Scopes, "accessLevel", role.AccessLevel)
-		token, err = client.CreateProjectAccessToken(ctx, role.Path, name, expiresAt, role.Scopes, role.AccessLevel)
+		token, err = client.CreateProjectAccessToken(ctx, role.gitlabPath(), name, expiresAt, role.Scopes, role.AccessLevel)
 	case TokenTypePersonal:
 		var userId int
 		userId, err = client.GetUserIdByUsername(ctx, role.Path)
@@ -113,10 +131,14 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse("invalid token type"), fmt.Errorf("%s: %w", role.TokenType.String(), ErrUnknownTokenType)
 	}
 
-	if err != nil || token == nil {
-		return nil, cmp.Or(err, fmt.Errorf("%w: token is nil", ErrNilValue))
+	if err != nil {
+		return nil, mapGitlabError(err, fmt.Sprintf("create %s for %s", role.TokenType, role.Path), tokenPermissionHint(role.TokenType, role.Path))
+	}
+	if token == nil {
+		return nil, fmt.Errorf("%w: token is nil", ErrNilValue)
 	}
 
+	token.Path = role.Path
 	token.ConfigName = cmp.Or(role.ConfigName, DefaultConfigName)
 	token.RoleName = role.RoleName
 	token.GitlabRevokesToken = role.GitlabRevokesTokens
@@ -124,18 +146,23 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 	if vaultRevokesTokens {
 		// since vault is controlling the expiry we need to override here
 		// and make the expiry time accurate
-		expiresAt = startTime.Add(role.TTL)
+		expiresAt = startTime.Add(ttl)
 		token.ExpiresAt = &expiresAt
 	}
 
+	if err = saveIssuedToken(ctx, *token, req.Storage); err != nil {
+		b.Logger().Error("Failed to store issued token", "role_name", roleName, "token_id", token.TokenID, "error", err)
+	}
+
 	var secretData, secretInternal = token.SecretResponse()
 	resp = b.Secret(SecretAccessTokenType).Response(secretData, secretInternal)
 
-	resp.Secret.MaxTTL = role.TTL
-	resp.Secret.TTL = role.TTL
+	resp.Secret.MaxTTL = ttl
+	resp.Secret.TTL = ttl
 	resp.Secret.IssueTime = startTime
+	resp.Warnings = warnings
 	if gitlabRevokesTokens {
-		resp.Secret.TTL = token.ExpiresAt.Sub(*token.CreatedAt)
+		resp.Secret.TTL, _ = clampToMaxLeaseTTL(token.ExpiresAt.Sub(*token.CreatedAt), maxLeaseTTL)
 	}
 
 	event(ctx, b.Backend, "token-write", map[string]string{
@@ -148,10 +175,58 @@ func (b *Backend) pathTokenRoleCreate(ctx context.Context, req *logical.Request,
 		"token_type":   role.TokenType.String(),
 		"scopes":       strings.Join(role.Scopes, ","),
 		"access_level": role.AccessLevel.String(),
+		"owner":        role.Owner,
+		"tags":         role.tagsMetadata(),
 	})
 	return resp, nil
 }
 
+// tokenNameData collects the information of the request and the requesting entity for the name template of the role
+func (b *Backend) tokenNameData(req *logical.Request, role *EntryRole) (data TokenNameData) {
+	data = TokenNameData{
+		EntityID:   req.EntityID,
+		RequestID:  req.ID,
+		MountPath:  req.MountPoint,
+		ConfigName: role.ConfigName,
+	}
+	if req.EntityID == "" {
+		return data
+	}
+	if entity, err := b.System().EntityInfo(req.EntityID); err != nil {
+		b.Logger().Debug("Unable to get the entity of the request", "entity_id", req.EntityID, "error", err)
+	} else if entity != nil {
+		data.EntityName = entity.Name
+		data.EntityMetadata = entity.Metadata
+	}
+	return data
+}
+
+// tokenExpiry calculates the lease ttl of the token of the role and the expiry date that is sent to GitLab, the ttl is
+// clamped to the max lease ttl of the mount.
+func (b *Backend) tokenExpiry(role EntryRole, startTime time.Time) (ttl time.Duration, expiresAt time.Time, warnings []string) {
+	var clamped bool
+	var maxLeaseTTL = b.System().MaxLeaseTTL()
+	if ttl, clamped = clampToMaxLeaseTTL(role.TTL, maxLeaseTTL); clamped {
+		b.Logger().Warn("Role TTL is above the max lease TTL of the mount", "role_name", role.RoleName, "ttl", role.TTL, "max_lease_ttl", maxLeaseTTL)
+		warnings = append(warnings, fmt.Sprintf("ttl = %s is above the max lease ttl of the mount, the token is issued with a ttl of %s", role.TTL, ttl))
+	}
+
+	_, expiresAt, _ = calculateGitlabTTL(ttl, startTime)
+	if maxLeaseTTL > 0 {
+		// gitlab only knows about days, a token gitlab revokes expires on the last day the lease allows, while a token
+		// vault revokes must not expire before its lease ends
+		var leaseEnd = startTime.Add(maxLeaseTTL)
+		var limit = leaseEnd.Truncate(24 * time.Hour)
+		if !role.GitlabRevokesTokens && limit.Before(leaseEnd) {
+			limit = limit.Add(24 * time.Hour)
+		}
+		if expiresAt.After(limit) && limit.After(startTime) {
+			expiresAt = limit
+		}
+	}
+	return ttl, expiresAt, warnings
+}
+
 func pathTokenRoles(b *Backend) *framework.Path {
 	return &framework.Path{
 		HelpSynopsis:    strings.TrimSpace(pathTokenRolesHelpSyn),
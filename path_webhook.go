@@ -0,0 +1,390 @@
+package gitlab
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	PathWebhook = "webhook"
+
+	webhookTokenHeader = "X-Gitlab-Token"
+
+	pathWebhookHelpSyn  = `Receive GitLab system hooks and group webhooks`
+	pathWebhookHelpDesc = `
+This path receives GitLab system hooks and group webhooks for the specified configuration, so the Backend can react
+to changes made directly in GitLab. Deliveries must carry the configured webhook_secret in the X-Gitlab-Token header,
+which requires the mount to be tuned with passthrough_request_headers=X-Gitlab-Token.
+
+Deleted or transferred projects and groups revoke or re-path the affected tokens, blocked users have their
+personal tokens revoked and expired or revoked tokens are marked so the lease revocation does not fail. The leases
+of the revoked tokens stay active in Vault until they expire or are revoked, the revocation doesn't call GitLab.`
+)
+
+var (
+	FieldSchemaWebhook = map[string]*framework.FieldSchema{
+		"config_name": {
+			Type:        framework.TypeString,
+			Description: "Config name",
+			Required:    true,
+		},
+	}
+)
+
+type webhookEvent struct {
+	ObjectKind           string `json:"object_kind"`
+	EventName            string `json:"event_name"`
+	PathWithNamespace    string `json:"path_with_namespace"`
+	OldPathWithNamespace string `json:"old_path_with_namespace"`
+	FullPath             string `json:"full_path"`
+	OldFullPath          string `json:"old_full_path"`
+	GroupId              int    `json:"group_id"`
+	UserId               int    `json:"user_id"`
+	Username             string `json:"username"`
+	State                string `json:"state"`
+	ObjectAttributes     struct {
+		Id        int    `json:"id"`
+		UserId    int    `json:"user_id"`
+		Name      string `json:"name"`
+		ExpiresAt string `json:"expires_at"`
+		Revoked   bool   `json:"revoked"`
+	} `json:"object_attributes"`
+}
+
+func (b *Backend) pathWebhookWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (lResp *logical.Response, err error) {
+	var name = data.Get("config_name").(string)
+	var config *EntryConfig
+
+	b.lockClientMutex.RLock()
+	config, err = getConfig(ctx, req.Storage, name)
+	b.lockClientMutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	// an unknown config is rejected the same way as an invalid token, so the config names can't be discovered
+	var token = http.Header(req.Headers).Get(webhookTokenHeader)
+	if config == nil || config.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(token), []byte(config.WebhookSecret)) != 1 {
+		b.Logger().Warn("Rejected webhook delivery with an invalid token", "config_name", name)
+		return nil, logical.ErrPermissionDenied
+	}
+
+	var hook webhookEvent
+	{
+		var buf []byte
+		if buf, err = json.Marshal(req.Data); err == nil {
+			err = json.Unmarshal(buf, &hook)
+		}
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid webhook payload: %s", err)), nil
+		}
+	}
+
+	b.Logger().Debug("Received webhook", "config_name", name, "object_kind", hook.ObjectKind, "event_name", hook.EventName)
+
+	var tokens []*EntryToken
+	var warnings []string
+	var revoked = true
+	switch {
+	case hook.EventName == "project_destroy":
+		tokens, warnings, err = b.webhookPathRemoved(ctx, req, config, hook, hook.PathWithNamespace, false)
+	case hook.EventName == "group_destroy" || hook.EventName == "subgroup_destroy":
+		tokens, warnings, err = b.webhookPathRemoved(ctx, req, config, hook, hook.FullPath, true)
+	case hook.EventName == "project_transfer" || hook.EventName == "project_rename":
+		revoked = false
+		tokens, warnings, err = b.webhookPathMoved(ctx, req, config, hook, hook.OldPathWithNamespace, hook.PathWithNamespace)
+	case hook.EventName == "group_rename":
+		revoked = false
+		tokens, warnings, err = b.webhookPathMoved(ctx, req, config, hook, hook.OldFullPath, hook.FullPath)
+	case hook.EventName == "user_destroy" || (hook.EventName == "user_failed_login" && hook.State == "blocked"):
+		tokens, err = b.webhookUserBlocked(ctx, req, config, hook)
+	case hook.ObjectKind == "access_token":
+		tokens, err = b.webhookTokenExpired(ctx, req, config, hook)
+	default:
+		b.Logger().Debug("Ignoring webhook event", "config_name", name, "object_kind", hook.ObjectKind, "event_name", hook.EventName)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var tokenIds = make([]int, 0, len(tokens))
+	for _, t := range tokens {
+		tokenIds = append(tokenIds, t.TokenID)
+	}
+
+	// a secrets engine can't revoke its own leases, they are only marked so revoking them doesn't call GitLab
+	if revoked && len(tokens) > 0 {
+		warnings = append(warnings, fmt.Sprintf("the leases of the tokens %s stay active until they expire or are revoked, revoking them doesn't call GitLab", joinTokenIds(tokens)))
+	}
+
+	return &logical.Response{
+		Data: map[string]any{
+			"config_name": name,
+			"event_name":  cmp.Or(hook.EventName, hook.ObjectKind),
+			"token_ids":   tokenIds,
+		},
+		Warnings: warnings,
+	}, nil
+}
+
+// webhookPathRemoved marks all tokens issued for a deleted project or group as revoked, GitLab removes
+// the tokens together with the resource so there is nothing left to revoke.
+func (b *Backend) webhookPathRemoved(ctx context.Context, req *logical.Request, config *EntryConfig, hook webhookEvent, path string, group bool) (affected []*EntryToken, warnings []string, err error) {
+	if path == "" {
+		return nil, nil, nil
+	}
+
+	var tokens []*EntryToken
+	if tokens, err = listIssuedTokens(ctx, req.Storage, config.Name); err != nil {
+		return nil, nil, err
+	}
+
+	for _, token := range tokens {
+		if token.Revoked || !webhookTokenMatchesPath(token, path, hook.GroupId, group) {
+			continue
+		}
+		token.Revoked = true
+		if err = saveIssuedToken(ctx, *token, req.Storage); err != nil {
+			return nil, nil, err
+		}
+		affected = append(affected, token)
+	}
+
+	var roles []string
+	if roles, err = b.webhookAffectedRoles(ctx, req.Storage, config.Name, path, group); err != nil {
+		return nil, nil, err
+	}
+	for _, role := range roles {
+		warnings = append(warnings, fmt.Sprintf("role '%s' references the removed path '%s'", role, path))
+	}
+
+	if len(affected) == 0 && len(roles) == 0 {
+		return affected, warnings, nil
+	}
+
+	event(ctx, b.Backend, "webhook-path-removed", map[string]string{
+		"config_name": config.Name,
+		"event_name":  hook.EventName,
+		"path":        path,
+		"token_ids":   joinTokenIds(affected),
+		"roles":       strings.Join(roles, ","),
+	})
+
+	return affected, warnings, nil
+}
+
+// webhookPathMoved updates the path of all tokens issued for a renamed or transferred project or group, so they
+// can still be revoked when the lease expires.
+func (b *Backend) webhookPathMoved(ctx context.Context, req *logical.Request, config *EntryConfig, hook webhookEvent, oldPath, newPath string) (affected []*EntryToken, warnings []string, err error) {
+	if oldPath == "" || newPath == "" || oldPath == newPath {
+		return nil, nil, nil
+	}
+
+	var group = strings.HasPrefix(hook.EventName, "group_")
+	var tokens []*EntryToken
+	if tokens, err = listIssuedTokens(ctx, req.Storage, config.Name); err != nil {
+		return nil, nil, err
+	}
+
+	for _, token := range tokens {
+		if token.Revoked || !webhookTokenMatchesPath(token, oldPath, 0, group) {
+			continue
+		}
+		token.Path = newPath + strings.TrimPrefix(token.Path, oldPath)
+		// tokens of roles with a pinned id are revoked by the id, which doesn't change
+		if pathMatches(token.ParentID, oldPath, group) {
+			token.ParentID = newPath + strings.TrimPrefix(token.ParentID, oldPath)
+		}
+		if err = saveIssuedToken(ctx, *token, req.Storage); err != nil {
+			return nil, nil, err
+		}
+		affected = append(affected, token)
+	}
+
+	var roles []string
+	if roles, err = b.webhookAffectedRoles(ctx, req.Storage, config.Name, oldPath, group); err != nil {
+		return nil, nil, err
+	}
+	for _, role := range roles {
+		warnings = append(warnings, fmt.Sprintf("role '%s' references the path '%s' which has moved to '%s'", role, oldPath, newPath))
+	}
+
+	if len(affected) == 0 && len(roles) == 0 {
+		return affected, warnings, nil
+	}
+
+	event(ctx, b.Backend, "webhook-path-moved", map[string]string{
+		"config_name": config.Name,
+		"event_name":  hook.EventName,
+		"old_path":    oldPath,
+		"path":        newPath,
+		"token_ids":   joinTokenIds(affected),
+		"roles":       strings.Join(roles, ","),
+	})
+
+	return affected, warnings, nil
+}
+
+// webhookUserBlocked revokes all personal tokens issued for a blocked or deleted user.
+func (b *Backend) webhookUserBlocked(ctx context.Context, req *logical.Request, config *EntryConfig, hook webhookEvent) (affected []*EntryToken, err error) {
+	var tokens []*EntryToken
+	if tokens, err = listIssuedTokens(ctx, req.Storage, config.Name); err != nil {
+		return nil, err
+	}
+
+	var client Client
+	for _, token := range tokens {
+		if token.Revoked ||
+			!slices.Contains([]TokenType{TokenTypePersonal, TokenTypeUserServiceAccount}, token.TokenType) ||
+			!((hook.UserId != 0 && token.UserID == hook.UserId) || (hook.Username != "" && token.Path == hook.Username)) {
+			continue
+		}
+
+		if client == nil {
+			if client, err = b.getClientFor(ctx, req.Storage, config.Name, CapabilityRevoke, CapabilityPersonal); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = revokeToken(ctx, client, *token); err != nil && !errors.Is(err, ErrAccessTokenNotFound) {
+			b.Logger().Error("Failed to revoke token for blocked user", "token_id", token.TokenID, "user_id", hook.UserId, "error", err)
+			continue
+		}
+
+		token.Revoked = true
+		if err = saveIssuedToken(ctx, *token, req.Storage); err != nil {
+			return nil, err
+		}
+		affected = append(affected, token)
+	}
+
+	if len(affected) == 0 {
+		return affected, nil
+	}
+
+	event(ctx, b.Backend, "webhook-user-blocked", map[string]string{
+		"config_name": config.Name,
+		"event_name":  hook.EventName,
+		"user_id":     strconv.Itoa(hook.UserId),
+		"username":    hook.Username,
+		"token_ids":   joinTokenIds(affected),
+	})
+
+	return affected, nil
+}
+
+// webhookTokenExpired marks a token issued by the plugin as revoked when GitLab reports it as revoked or expired.
+func (b *Backend) webhookTokenExpired(ctx context.Context, req *logical.Request, config *EntryConfig, hook webhookEvent) (affected []*EntryToken, err error) {
+	var attrs = hook.ObjectAttributes
+	var expired = attrs.Revoked || strings.Contains(hook.EventName, "revoke")
+	if expiresAt, e := time.Parse(time.DateOnly, attrs.ExpiresAt); e == nil {
+		expired = expired || !expiresAt.After(TimeFromContext(ctx).UTC())
+	}
+	if !expired || attrs.Id == 0 {
+		return nil, nil
+	}
+
+	var token *EntryToken
+	if token, err = getIssuedToken(ctx, req.Storage, config.Name, attrs.Id); err != nil || token == nil || token.Revoked {
+		return nil, err
+	}
+
+	token.Revoked = true
+	if err = saveIssuedToken(ctx, *token, req.Storage); err != nil {
+		return nil, err
+	}
+
+	event(ctx, b.Backend, "webhook-token-expired", map[string]string{
+		"config_name": config.Name,
+		"event_name":  hook.EventName,
+		"token_id":    strconv.Itoa(token.TokenID),
+		"role_name":   token.RoleName,
+		"path":        token.Path,
+		"token_type":  token.TokenType.String(),
+	})
+
+	return []*EntryToken{token}, nil
+}
+
+func (b *Backend) webhookAffectedRoles(ctx context.Context, s logical.Storage, configName, path string, group bool) (roles []string, err error) {
+	var names []string
+	if names, err = s.List(ctx, fmt.Sprintf("%s/", PathRoleStorage)); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		var role *EntryRole
+		if role, _, err = getEffectiveRole(ctx, name, s); err != nil {
+			return nil, err
+		}
+		if role == nil || role.ConfigName != configName ||
+			!slices.Contains([]TokenType{TokenTypeProject, TokenTypeGroup}, role.TokenType) {
+			continue
+		}
+		if role.Path == path || (group && strings.HasPrefix(role.Path, path+"/")) {
+			roles = append(roles, role.RoleName)
+		}
+	}
+	return roles, nil
+}
+
+// pathMatches checks if the value is the path, or is within the path for groups
+func pathMatches(value, path string, group bool) bool {
+	return value == path || (group && strings.HasPrefix(value, path+"/"))
+}
+
+func webhookTokenMatchesPath(token *EntryToken, path string, groupId int, group bool) bool {
+	switch token.TokenType {
+	case TokenTypeProject, TokenTypeGroup:
+		return pathMatches(token.ParentID, path, group) || pathMatches(token.Path, path, group)
+	case TokenTypeGroupServiceAccount:
+		return group && groupId != 0 && token.ParentID == strconv.Itoa(groupId)
+	}
+	return false
+}
+
+func joinTokenIds(tokens []*EntryToken) string {
+	var ids = make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		ids = append(ids, strconv.Itoa(t.TokenID))
+	}
+	return strings.Join(ids, ",")
+}
+
+func pathWebhook(b *Backend) *framework.Path {
+	return &framework.Path{
+		HelpSynopsis:        strings.TrimSpace(pathWebhookHelpSyn),
+		HelpDescription:     strings.TrimSpace(pathWebhookHelpDesc),
+		Pattern:             fmt.Sprintf("%s/%s$", PathWebhook, framework.GenericNameRegex("config_name")),
+		Fields:              FieldSchemaWebhook,
+		TakesArbitraryInput: true,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixGitlabAccessTokens,
+			OperationSuffix: "webhook",
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:     b.pathWebhookWrite,
+				DisplayAttrs: &framework.DisplayAttributes{OperationVerb: "receive"},
+				Summary:      "Receive a GitLab system hook or group webhook.",
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: http.StatusText(http.StatusOK),
+					}},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,224 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestPathWebhook(t *testing.T) {
+	var defaultConfig = map[string]any{
+		"token":          "glpat-secret-random-token",
+		"base_url":       "http://localhost:8080/",
+		"type":           gitlab.TypeSelfManaged.String(),
+		"webhook_secret": "webhook-secret",
+	}
+
+	var setup = func(t *testing.T, tokenType gitlab.TokenType, path string) (context.Context, *gitlab.Backend, logical.Storage, *mockEventsSender, *inMemoryClient, *logical.Secret) {
+		t.Helper()
+		ctx := getCtxGitlabClient(t)
+		client := newInMemoryClient(true)
+		ctx = gitlab.GitlabClientNewContext(ctx, client)
+		b, l, events, err := getBackendWithEventsAndConfig(ctx, defaultConfig)
+		require.NoError(t, err)
+
+		var accessLevel = gitlab.AccessLevelGuestPermissions.String()
+		if tokenType == gitlab.TokenTypePersonal {
+			accessLevel = ""
+		}
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":         path,
+				"name":         tokenType.String(),
+				"token_type":   tokenType.String(),
+				"access_level": accessLevel,
+				"ttl":          "1h",
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		events.resetEvents(t)
+		return ctx, b, l, events, client, resp.Secret
+	}
+
+	var webhook = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, token string, data map[string]any) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Headers:   map[string][]string{"X-Gitlab-Token": {token}},
+			Data:      data,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathWebhook, gitlab.DefaultConfigName), Storage: l,
+		})
+	}
+
+	t.Run("invalid webhook token", func(t *testing.T) {
+		ctx, b, l, events, _, _ := setup(t, gitlab.TokenTypeProject, "example/example")
+		resp, err := webhook(ctx, b, l, "invalid", map[string]any{"event_name": "project_destroy"})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Nil(t, resp)
+		events.expectEvents(t, []expectedEvent{})
+	})
+
+	t.Run("unknown config", func(t *testing.T) {
+		ctx, b, l, events, _, _ := setup(t, gitlab.TokenTypeProject, "example/example")
+		resp, err := b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.UpdateOperation,
+			Headers:   map[string][]string{"X-Gitlab-Token": {"webhook-secret"}},
+			Data:      map[string]any{"event_name": "project_destroy"},
+			Path:      fmt.Sprintf("%s/unknown", gitlab.PathWebhook), Storage: l,
+		})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Nil(t, resp)
+		events.expectEvents(t, []expectedEvent{})
+	})
+
+	t.Run("webhook secret not configured", func(t *testing.T) {
+		ctx := getCtxGitlabClient(t)
+		ctx = gitlab.GitlabClientNewContext(ctx, newInMemoryClient(true))
+		b, l, _, err := getBackendWithEventsAndConfig(ctx, map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		})
+		require.NoError(t, err)
+		resp, err := webhook(ctx, b, l, "", map[string]any{"event_name": "project_destroy"})
+		require.ErrorIs(t, err, logical.ErrPermissionDenied)
+		require.Nil(t, resp)
+	})
+
+	t.Run("project destroy marks the token as revoked", func(t *testing.T) {
+		ctx, b, l, events, client, secret := setup(t, gitlab.TokenTypeProject, "example/example")
+		resp, err := webhook(ctx, b, l, "webhook-secret", map[string]any{
+			"event_name":          "project_destroy",
+			"path_with_namespace": "example/example",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Len(t, resp.Data["token_ids"], 1)
+		require.Len(t, resp.Warnings, 2)
+
+		// gitlab deleted the token with the project, so the revocation must not call gitlab
+		client.projectAccessTokenRevokeError = true
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, secret.LeaseID), Storage: l,
+			Secret: secret,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/webhook-path-removed"},
+			{eventType: "gitlab/token-revoke"},
+		})
+	})
+
+	t.Run("group rename re-paths the token", func(t *testing.T) {
+		ctx, b, l, events, _, _ := setup(t, gitlab.TokenTypeProject, "example/example")
+		resp, err := webhook(ctx, b, l, "webhook-secret", map[string]any{
+			"event_name":    "group_rename",
+			"old_full_path": "example",
+			"full_path":     "renamed",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Len(t, resp.Data["token_ids"], 1)
+		require.Len(t, resp.Warnings, 1)
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/webhook-path-moved"},
+		})
+	})
+
+	t.Run("blocked user tokens are revoked", func(t *testing.T) {
+		ctx, b, l, events, client, secret := setup(t, gitlab.TokenTypePersonal, "admin-user")
+		require.Len(t, client.accessTokens, 1)
+		resp, err := webhook(ctx, b, l, "webhook-secret", map[string]any{
+			"event_name": "user_failed_login",
+			"username":   "admin-user",
+			"state":      "blocked",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Len(t, resp.Data["token_ids"], 1)
+		require.Empty(t, client.accessTokens)
+
+		client.personalAccessTokenRevokeError = true
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, secret.LeaseID), Storage: l,
+			Secret: secret,
+		})
+		require.NoError(t, err)
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/webhook-user-blocked"},
+			{eventType: "gitlab/token-revoke"},
+		})
+	})
+
+	t.Run("blocked user without tokens", func(t *testing.T) {
+		ctx, b, l, events, client, _ := setup(t, gitlab.TokenTypePersonal, "admin-user")
+		resp, err := webhook(ctx, b, l, "webhook-secret", map[string]any{
+			"event_name": "user_destroy",
+			"username":   "other-user",
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Empty(t, resp.Data["token_ids"])
+		require.Len(t, client.accessTokens, 1)
+		events.expectEvents(t, []expectedEvent{})
+	})
+
+	t.Run("revoked access token", func(t *testing.T) {
+		ctx, b, l, events, client, secret := setup(t, gitlab.TokenTypeProject, "example/example")
+		resp, err := webhook(ctx, b, l, "webhook-secret", map[string]any{
+			"object_kind": "access_token",
+			"event_name":  "revoked_access_token",
+			"object_attributes": map[string]any{
+				"id": secret.InternalData["token_id"],
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Len(t, resp.Data["token_ids"], 1)
+		// the lease stays active, the warning tells the operator to revoke it
+		require.Len(t, resp.Warnings, 1)
+		require.Contains(t, resp.Warnings[0], "stay active")
+
+		client.projectAccessTokenRevokeError = true
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, secret.LeaseID), Storage: l,
+			Secret: secret,
+		})
+		require.NoError(t, err)
+		require.Nil(t, resp)
+
+		events.expectEvents(t, []expectedEvent{
+			{eventType: "gitlab/webhook-token-expired"},
+			{eventType: "gitlab/token-revoke"},
+		})
+	})
+
+	t.Run("unknown events are ignored", func(t *testing.T) {
+		ctx, b, l, events, _, _ := setup(t, gitlab.TokenTypeProject, "example/example")
+		resp, err := webhook(ctx, b, l, "webhook-secret", map[string]any{"event_name": "project_create"})
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+		require.Empty(t, resp.Data["token_ids"])
+		events.expectEvents(t, []expectedEvent{})
+	})
+}
@@ -87,30 +87,52 @@ func (b *Backend) secretAccessTokenRevoke(ctx context.Context, req *logical.Requ
 	var tokenTypeValue = req.Secret.InternalData["token_type"].(string)
 	tokenType, _ = TokenTypeParse(tokenTypeValue)
 
-	if vaultRevokesToken {
+	// the issued token may have been re-pathed or already revoked by a webhook
+	var issuedToken *EntryToken
+	if issuedToken, err = getIssuedToken(ctx, req.Storage, configName, tokenId); err != nil {
+		return nil, fmt.Errorf("revoke token cannot get issued token: %w", err)
+	}
+	if issuedToken != nil {
+		parentId = issuedToken.ParentID
+	}
+
+	if vaultRevokesToken && (issuedToken == nil || !issuedToken.Revoked) {
 		var client Client
-		client, err = b.getClient(ctx, req.Storage, configName)
+		client, err = b.getClientFor(ctx, req.Storage, configName, CapabilityRevoke, capabilityForTokenType(tokenType))
 		if err != nil {
 			return nil, fmt.Errorf("revoke token cannot get client: %w", err)
 		}
 
-		switch tokenType {
-		case TokenTypePersonal:
-			err = client.RevokePersonalAccessToken(ctx, tokenId)
-		case TokenTypeProject:
-			err = client.RevokeProjectAccessToken(ctx, tokenId, parentId)
-		case TokenTypeGroup:
-			err = client.RevokeGroupAccessToken(ctx, tokenId, parentId)
-		case TokenTypeUserServiceAccount:
-			var token = req.Secret.InternalData["token"].(string)
-			err = client.RevokeUserServiceAccountAccessToken(ctx, token)
-		case TokenTypeGroupServiceAccount:
-			var token = req.Secret.InternalData["token"].(string)
-			err = client.RevokeGroupServiceAccountAccessToken(ctx, token)
+		var token, _ = req.Secret.InternalData["token"].(string)
+		var entryToken = EntryToken{
+			TokenID:    tokenId,
+			ParentID:   parentId,
+			Token:      token,
+			TokenType:  tokenType,
+			ConfigName: configName,
 		}
+		err = revokeToken(ctx, client, entryToken)
 
 		if err != nil && !errors.Is(err, ErrAccessTokenNotFound) {
-			return logical.ErrorResponse("failed to revoke token"), fmt.Errorf("revoke token: %w", err)
+			// keep retrying from the periodic function in case vault gives up on the lease
+			b.queueRevocation(ctx, req.Storage, entryToken, secret.LeaseID, err)
+			return logical.ErrorResponse("failed to revoke token"), fmt.Errorf("revoke token: %w", mapGitlabError(err, fmt.Sprintf("revoke %s %d", tokenType, tokenId), tokenPermissionHint(tokenType, parentId)))
+		}
+
+		// vault retried the lease and succeeded before the periodic function did
+		var revocation *EntryRevocation
+		if revocation, err = getRevocation(ctx, req.Storage, configName, tokenId); err == nil && revocation != nil {
+			err = deleteRevocation(ctx, req.Storage, configName, tokenId)
+			event(ctx, b.Backend, "token-revoke-retry-succeeded", revocation.metadata())
+		}
+		if err != nil {
+			b.Logger().Warn("Failed to delete queued revocation", "config_name", configName, "token_id", tokenId, "error", err)
+		}
+	}
+
+	if issuedToken != nil {
+		if err = deleteIssuedToken(ctx, req.Storage, configName, tokenId); err != nil {
+			b.Logger().Warn("Failed to delete issued token", "config_name", configName, "token_id", tokenId, "error", err)
 		}
 	}
 
@@ -125,3 +147,93 @@ func (b *Backend) secretAccessTokenRevoke(ctx context.Context, req *logical.Requ
 
 	return nil, nil
 }
+
+// queueRevocation stores the token that failed to be revoked, or updates the attempts if it's already queued
+func (b *Backend) queueRevocation(ctx context.Context, s logical.Storage, token EntryToken, leaseId string, revokeErr error) {
+	var revocation, err = getRevocation(ctx, s, token.ConfigName, token.TokenID)
+	if err != nil {
+		b.Logger().Error("Failed to get queued revocation", "config_name", token.ConfigName, "token_id", token.TokenID, "error", err)
+		return
+	}
+	if revocation == nil {
+		revocation = &EntryRevocation{Token: token, LeaseID: leaseId, QueuedAt: TimeFromContext(ctx).UTC()}
+	}
+	var now = TimeFromContext(ctx).UTC()
+	revocation.Attempts++
+	revocation.LastError = revokeErr.Error()
+	revocation.NextAttemptAt = now.Add(exponentialBackoff(revocation.Attempts, DefaultRevocationBackoffMin, DefaultRevocationBackoffMax))
+	if err = saveRevocation(ctx, *revocation, s); err != nil {
+		b.Logger().Error("Failed to queue revocation", "config_name", token.ConfigName, "token_id", token.TokenID, "error", err)
+		return
+	}
+	b.Logger().Warn("Queued revocation", "config_name", token.ConfigName, "token_id", token.TokenID, "attempts", revocation.Attempts, "error", revokeErr)
+}
+
+// retryRevocations tries to revoke the tokens that failed to be revoked before, once their backoff has passed.
+// Revocations that keep failing are abandoned after DefaultRevocationMaxAttempts, the token then only expires in GitLab.
+func (b *Backend) retryRevocations(ctx context.Context, req *logical.Request, configName string) (err error) {
+	var revocations []*EntryRevocation
+	if revocations, err = listRevocations(ctx, req.Storage, configName); err != nil || len(revocations) == 0 {
+		return err
+	}
+
+	var now = TimeFromContext(ctx).UTC()
+	for _, revocation := range revocations {
+		if now.Before(revocation.NextAttemptAt) {
+			continue
+		}
+
+		var token = revocation.Token
+		var client Client
+		var er error
+		if client, er = b.getClientFor(ctx, req.Storage, configName, CapabilityRevoke, capabilityForTokenType(token.TokenType)); er != nil {
+			return errors.Join(err, er)
+		}
+
+		if er = revokeToken(ctx, client, token); er != nil && !errors.Is(er, ErrAccessTokenNotFound) {
+			err = errors.Join(err, fmt.Errorf("revoke token %d: %w", token.TokenID, er))
+			if revocation.Attempts+1 < DefaultRevocationMaxAttempts {
+				b.queueRevocation(ctx, req.Storage, token, revocation.LeaseID, er)
+				continue
+			}
+
+			revocation.Attempts++
+			revocation.LastError = er.Error()
+			b.Logger().Error("Abandoned queued revocation", "config_name", configName, "token_id", token.TokenID, "attempts", revocation.Attempts, "error", er)
+			if er = deleteRevocation(ctx, req.Storage, configName, token.TokenID); er != nil {
+				err = errors.Join(err, er)
+			}
+			event(ctx, b.Backend, "token-revoke-abandoned", revocation.metadata())
+			continue
+		}
+
+		revocation.Attempts++
+		b.Logger().Info("Revoked queued token", "config_name", configName, "token_id", token.TokenID, "attempts", revocation.Attempts)
+		if er = deleteRevocation(ctx, req.Storage, configName, token.TokenID); er != nil {
+			err = errors.Join(err, er)
+		}
+		if er = deleteIssuedToken(ctx, req.Storage, configName, token.TokenID); er != nil {
+			err = errors.Join(err, er)
+		}
+		event(ctx, b.Backend, "token-revoke-retry-succeeded", revocation.metadata())
+	}
+
+	return err
+}
+
+// revokeToken revokes the token in Gitlab using the api matching the token type.
+func revokeToken(ctx context.Context, client Client, token EntryToken) (err error) {
+	switch token.TokenType {
+	case TokenTypePersonal:
+		err = client.RevokePersonalAccessToken(ctx, token.TokenID)
+	case TokenTypeProject:
+		err = client.RevokeProjectAccessToken(ctx, token.TokenID, token.ParentID)
+	case TokenTypeGroup:
+		err = client.RevokeGroupAccessToken(ctx, token.TokenID, token.ParentID)
+	case TokenTypeUserServiceAccount:
+		err = client.RevokeUserServiceAccountAccessToken(ctx, token.Token)
+	case TokenTypeGroupServiceAccount:
+		err = client.RevokeGroupServiceAccountAccessToken(ctx, token.Token)
+	}
+	return err
+}
@@ -0,0 +1,106 @@
+package gitlab_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestSecretAccessTokensShortTTL(t *testing.T) {
+	var setup = func(t *testing.T, config map[string]any) (context.Context, *gitlab.Backend, logical.Storage, *inMemoryClient, error) {
+		t.Helper()
+		var client = newInMemoryClient(true)
+		ctx := gitlab.GitlabClientNewContext(getCtxGitlabClient(t), client)
+		var data = map[string]any{
+			"token":    "glpat-secret-random-token",
+			"base_url": "http://localhost:8080/",
+			"type":     gitlab.TypeSelfManaged.String(),
+		}
+		for k, v := range config {
+			data[k] = v
+		}
+		b, l, _, err := getBackendWithEventsAndConfig(ctx, data)
+		return ctx, b, l, client, err
+	}
+
+	var writeRole = func(ctx context.Context, b *gitlab.Backend, l logical.Storage, ttl string) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.CreateOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathRoleStorage), Storage: l,
+			Data: map[string]any{
+				"path":                 "example/example",
+				"name":                 "ci",
+				"token_type":           gitlab.TokenTypeProject.String(),
+				"access_level":         gitlab.AccessLevelGuestPermissions.String(),
+				"scopes":               []string{gitlab.TokenScopeReadApi.String()},
+				"ttl":                  ttl,
+				"gitlab_revokes_token": false,
+			},
+		})
+	}
+
+	t.Run("default floor", func(t *testing.T) {
+		ctx, b, l, _, err := setup(t, nil)
+		require.NoError(t, err)
+		resp, err := writeRole(ctx, b, l, "10m")
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "ttl = 10m0s [ttl >= 1h]")
+	})
+
+	t.Run("invalid floor", func(t *testing.T) {
+		_, _, _, _, err := setup(t, map[string]any{"vault_revoked_min_ttl": "30s"})
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+	})
+
+	t.Run("lowered floor", func(t *testing.T) {
+		ctx, b, l, client, err := setup(t, map[string]any{"vault_revoked_min_ttl": "60s"})
+		require.NoError(t, err)
+
+		resp, err := writeRole(ctx, b, l, "30s")
+		require.ErrorIs(t, err, gitlab.ErrInvalidValue)
+		require.ErrorContains(t, resp.Error(), "ttl = 30s [ttl >= 1m]")
+
+		resp, err = writeRole(ctx, b, l, "10m")
+		require.NoError(t, err)
+		require.NoError(t, resp.Error())
+
+		var now = time.Now()
+		resp, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      fmt.Sprintf("%s/test", gitlab.PathTokenRoleStorage), Storage: l,
+		})
+		require.NoError(t, err)
+		require.NotNil(t, resp.Secret)
+		require.EqualValues(t, 10*time.Minute, resp.Secret.TTL)
+		require.Len(t, client.accessTokens, 1)
+		for _, token := range client.accessTokens {
+			// gitlab still expires the token the next day in case vault fails to revoke it
+			require.True(t, token.ExpiresAt.After(now.Add(10*time.Minute)))
+			require.EqualValues(t, token.ExpiresAt.Truncate(24*time.Hour), *token.ExpiresAt)
+		}
+
+		client.projectAccessTokenRevokeError = true
+		_, err = b.HandleRequest(ctx, &logical.Request{
+			Operation: logical.RevokeOperation,
+			Secret:    resp.Secret,
+			Path:      fmt.Sprintf("%s/%s", gitlab.PathTokenRoleStorage, resp.Secret.LeaseID), Storage: l,
+		})
+		require.Error(t, err)
+		require.Len(t, client.accessTokens, 1)
+
+		// the failed revocation is retried by the periodic function once the backoff has passed
+		client.projectAccessTokenRevokeError = false
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.Len(t, client.accessTokens, 1)
+
+		ctx = gitlab.WithStaticTime(ctx, time.Now().Add(gitlab.DefaultRevocationBackoffMin))
+		require.NoError(t, b.PeriodicFunc(ctx, &logical.Request{Storage: l}))
+		require.Empty(t, client.accessTokens)
+	})
+}
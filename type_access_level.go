@@ -84,6 +84,16 @@ func (i AccessLevel) Value() int {
 	return -1
 }
 
+// accessLevelFromValue converts the numeric gitlab access level to an AccessLevel.
+func accessLevelFromValue(value gitlab.AccessLevelValue) AccessLevel {
+	for _, level := range ValidAccessLevels {
+		if AccessLevel(level).Value() == int(value) {
+			return AccessLevel(level)
+		}
+	}
+	return AccessLevelUnknown
+}
+
 func AccessLevelParse(value string) (AccessLevel, error) {
 	if slices.Contains(ValidAccessLevels, value) {
 		return AccessLevel(value), nil
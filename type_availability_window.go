@@ -0,0 +1,80 @@
+package gitlab
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownAvailabilityWindow = errors.New("unknown availability window")
+
+	availabilityWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// AvailabilityWindow is a recurring window in which a role can issue tokens, like `mon-fri 08:00-18:00`.
+// A window that ends before it starts runs past midnight into the next day.
+type AvailabilityWindow struct {
+	Days  [7]bool
+	Start time.Duration
+	End   time.Duration
+}
+
+// AvailabilityWindowParse parses a window in the format `<day>[-<day>][,<day>...] HH:MM-HH:MM`
+func AvailabilityWindowParse(value string) (window AvailabilityWindow, err error) {
+	var days, hours, ok = strings.Cut(strings.TrimSpace(value), " ")
+	if !ok {
+		return window, fmt.Errorf("failed to parse '%s', expected '<days> HH:MM-HH:MM': %w", value, ErrUnknownAvailabilityWindow)
+	}
+
+	for _, part := range strings.Split(strings.ToLower(days), ",") {
+		var from, to, isRange = strings.Cut(part, "-")
+		var start, end = slices.Index(availabilityWeekdays, from), slices.Index(availabilityWeekdays, to)
+		if !isRange {
+			end = start
+		}
+		if start == -1 || end == -1 {
+			return window, fmt.Errorf("failed to parse '%s', days should be one of %v: %w", value, availabilityWeekdays, ErrUnknownAvailabilityWindow)
+		}
+		for day := start; ; day = (day + 1) % 7 {
+			window.Days[day] = true
+			if day == end {
+				break
+			}
+		}
+	}
+
+	var startHour, endHour, _ = strings.Cut(strings.TrimSpace(hours), "-")
+	if window.Start, err = parseTimeOfDay(startHour); err == nil {
+		window.End, err = parseTimeOfDay(endHour)
+	}
+	if err != nil || window.Start == window.End || window.Start == 24*time.Hour {
+		return window, fmt.Errorf("failed to parse '%s', expected hours as HH:MM-HH:MM: %w", value, ErrUnknownAvailabilityWindow)
+	}
+	return window, nil
+}
+
+// parseTimeOfDay parses HH:MM to the duration since midnight, 24:00 is the end of the day
+func parseTimeOfDay(value string) (time.Duration, error) {
+	var hour, minute int
+	if n, err := fmt.Sscanf(value, "%02d:%02d", &hour, &minute); err != nil || n != 2 || len(value) != 5 {
+		return 0, ErrUnknownAvailabilityWindow
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, ErrUnknownAvailabilityWindow
+	}
+	return time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute, nil
+}
+
+// Contains checks if the time, in the timezone of the window, is inside the window
+func (w AvailabilityWindow) Contains(t time.Time) bool {
+	var sinceMidnight = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	if w.End > w.Start {
+		return w.Days[t.Weekday()] && sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	// the window runs past midnight, so it started either today or yesterday
+	var yesterday = (t.Weekday() + 6) % 7
+	return (w.Days[t.Weekday()] && sinceMidnight >= w.Start) || (w.Days[yesterday] && sinceMidnight < w.End)
+}
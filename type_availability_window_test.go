@@ -0,0 +1,70 @@
+package gitlab_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	gitlab "github.com/ilijamt/vault-plugin-secrets-gitlab"
+)
+
+func TestAvailabilityWindowParse(t *testing.T) {
+	var tests = []struct {
+		input string
+		err   bool
+	}{
+		{input: "mon-fri 08:00-18:00"},
+		{input: "sat,sun 10:00-12:00"},
+		{input: "fri-mon 22:00-06:00"},
+		{input: "mon 00:00-24:00"},
+		{input: "mon-fri", err: true},
+		{input: "someday 08:00-18:00", err: true},
+		{input: "mon 8:00-18:00", err: true},
+		{input: "mon 08:00-25:00", err: true},
+		{input: "mon 08:00-08:00", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := gitlab.AvailabilityWindowParse(test.input)
+			if test.err {
+				assert.ErrorIs(t, err, gitlab.ErrUnknownAvailabilityWindow)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAvailabilityWindowContains(t *testing.T) {
+	// 2024-12-09 is a monday
+	var monday = func(hour, minute int) time.Time { return time.Date(2024, 12, 9, hour, minute, 0, 0, time.UTC) }
+
+	var tests = []struct {
+		window   string
+		at       time.Time
+		expected bool
+	}{
+		{"mon-fri 08:00-18:00", monday(8, 0), true},
+		{"mon-fri 08:00-18:00", monday(17, 59), true},
+		{"mon-fri 08:00-18:00", monday(18, 0), false},
+		{"mon-fri 08:00-18:00", monday(7, 59), false},
+		{"mon-fri 08:00-18:00", monday(12, 0).AddDate(0, 0, -1), false},
+		{"sat,sun 10:00-12:00", monday(11, 0).AddDate(0, 0, -1), true},
+		{"fri-sun 22:00-06:00", monday(5, 59), true},
+		{"fri-sun 22:00-06:00", monday(6, 0), false},
+		{"fri-sun 22:00-06:00", monday(23, 0), false},
+		{"sun 22:00-06:00", monday(23, 0).AddDate(0, 0, -1), true},
+		{"mon 00:00-24:00", monday(23, 59), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.window+" "+test.at.Format(time.RFC3339), func(t *testing.T) {
+			window, err := gitlab.AvailabilityWindowParse(test.window)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, window.Contains(test.at))
+		})
+	}
+}
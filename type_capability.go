@@ -0,0 +1,35 @@
+package gitlab
+
+// Capability is what a credential of the config is used for.
+type Capability string
+
+const (
+	// CapabilityPersonal creates personal and user service account tokens, it usually requires an admin token
+	CapabilityPersonal = Capability("personal")
+	// CapabilityGroup creates group, project and group service account tokens, it requires a group owner token
+	CapabilityGroup = Capability("group")
+	// CapabilityRevoke is only used to revoke tokens
+	CapabilityRevoke = Capability("revoke")
+)
+
+var (
+	validCapabilities = []Capability{
+		CapabilityPersonal,
+		CapabilityGroup,
+		CapabilityRevoke,
+	}
+)
+
+func (i Capability) String() string {
+	return string(i)
+}
+
+// capabilityForTokenType returns the capability needed to create tokens of the token type
+func capabilityForTokenType(tokenType TokenType) Capability {
+	switch tokenType {
+	case TokenTypePersonal, TokenTypeUserServiceAccount:
+		return CapabilityPersonal
+	default:
+		return CapabilityGroup
+	}
+}
@@ -2,6 +2,7 @@ package gitlab
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -32,6 +33,26 @@ func convertToInt(num any) (int, error) {
 	return 0, fmt.Errorf("%v: %w", num, ErrInvalidValue)
 }
 
+// shortDuration formats the duration without the trailing zero units, 1h0m0s is formatted as 1h
+func shortDuration(d time.Duration) string {
+	var value = d.String()
+	if strings.HasSuffix(value, "m0s") {
+		value = strings.TrimSuffix(value, "0s")
+	}
+	if strings.HasSuffix(value, "h0m") {
+		value = strings.TrimSuffix(value, "0m")
+	}
+	return value
+}
+
+// clampToMaxLeaseTTL limits the ttl to the max lease ttl of the mount, a max lease ttl of 0 means there is no limit
+func clampToMaxLeaseTTL(ttl, maxLeaseTTL time.Duration) (time.Duration, bool) {
+	if maxLeaseTTL > 0 && ttl > maxLeaseTTL {
+		return maxLeaseTTL, true
+	}
+	return ttl, false
+}
+
 func calculateGitlabTTL(duration time.Duration, start time.Time) (ttl time.Duration, exp time.Time, err error) {
 	start = start.UTC()
 	const D = 24 * time.Hour